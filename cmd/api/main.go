@@ -2,11 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/gorilla/mux"
 	"github.com/grokkos/go-isa-retail-service/internal/api/handler"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/config"
+	"github.com/grokkos/go-isa-retail-service/internal/health"
+	"github.com/grokkos/go-isa-retail-service/internal/logging"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
 	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/scheduler"
 	"github.com/grokkos/go-isa-retail-service/internal/service"
-	"log"
+	"github.com/grokkos/go-isa-retail-service/internal/tracing"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,47 +25,230 @@ import (
 )
 
 func main() {
-	// Initialize repositories
-	customerRepo := repository.NewInMemoryCustomerRepository()
-	fundRepo := repository.NewInMemoryFundRepository()
-	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	// Configuration comes from the JSON file given with -config or CONFIG_FILE,
+	// when there is one, overridden by environment variables
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON config file")
+	flag.Parse()
+	cfg, err := config.Load(*configPath, os.Getenv)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// Logs are written as JSON to stdout
+	logger := logging.New(os.Stdout, cfg.Logging.Level)
+	slog.SetDefault(logger)
 
-	// Initialize services
-	fundService := service.NewFundService(fundRepo)
-	investmentService := service.NewInvestmentService(investmentRepo, customerRepo, fundRepo)
+	// Metrics are served on /metrics for Prometheus to scrape
+	m := metrics.New()
 
-	// Initialize handlers
-	fundHandler := handler.NewFundHandler(fundService)
-	investmentHandler := handler.NewInvestmentHandler(investmentService, fundService)
+	// Traces are exported with the configured exporter, "otlp" or "console",
+	// and not recorded at all by default
+	tp, shutdownTracing, err := tracing.NewProvider(context.Background(), cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		fatal("Error setting up tracing", err)
+	}
 
-	// Set up router
+	// Initialize repositories, timing and tracing every operation
+	customerRepo := repository.NewTracedCustomerRepository(repository.NewMeteredCustomerRepository(repository.NewInMemoryCustomerRepository(), m), tp)
+	fundRepo := repository.NewTracedFundRepository(repository.NewMeteredFundRepository(repository.NewInMemoryFundRepository(), m), tp)
+	investmentRepo := repository.NewTracedInvestmentRepository(repository.NewMeteredInvestmentRepository(repository.NewInMemoryInvestmentRepository(), m), tp)
+	investmentEventRepo := repository.NewTracedInvestmentEventRepository(repository.NewMeteredInvestmentEventRepository(repository.NewInMemoryInvestmentEventRepository(), m), tp)
+	switchRepo := repository.NewTracedSwitchRepository(repository.NewMeteredSwitchRepository(repository.NewInMemorySwitchRepository(), m), tp)
+	planRepo := repository.NewTracedPlanRepository(repository.NewMeteredPlanRepository(repository.NewInMemoryPlanRepository(), m), tp)
+	ledgerRepo := repository.NewTracedLedgerRepository(repository.NewMeteredLedgerRepository(repository.NewInMemoryLedgerRepository(), m), tp)
+	approvalRepo := repository.NewTracedApprovalRepository(repository.NewMeteredApprovalRepository(repository.NewInMemoryApprovalRepository(), m), tp)
+	apiKeyRepo := repository.NewTracedAPIKeyRepository(repository.NewMeteredAPIKeyRepository(repository.NewInMemoryAPIKeyRepository(), m), tp)
+
+	// The audit log is kept on disk so it can be verified with cmd/auditverify
+	fileAuditRepo, err := repository.NewFileAuditRepository(cfg.Storage.AuditLogPath)
+	if err != nil {
+		fatal("Error opening audit log", err)
+	}
+	auditRepo := repository.NewTracedAuditRepository(repository.NewMeteredAuditRepository(fileAuditRepo, m), tp)
+
+	// Initialize services, logging and auditing every change they make, counting
+	// subscriptions, and tracing investment, fund, switch and plan calls. Only the
+	// investment service is metered: subscriptions from plans are made through
+	// it, and switches neither subscribe nor use the allowance.
+	auditService := service.NewAuditService(auditRepo)
+	customerService := service.NewAuditedCustomerService(
+		service.NewLoggedCustomerService(service.NewCustomerService(customerRepo), logger),
+		auditService,
+	)
+	fundService := service.NewTracedFundService(
+		service.NewAuditedFundService(service.NewLoggedFundService(service.NewFundService(fundRepo), logger), auditService),
+		tp,
+	)
+	ledgerService := service.NewAuditedLedgerService(
+		service.NewLoggedLedgerService(service.NewLedgerService(ledgerRepo, customerRepo), logger),
+		auditService,
+	)
+	investmentService := service.NewTracedInvestmentService(
+		service.NewAuditedInvestmentService(
+			service.NewMeteredInvestmentService(
+				service.NewLoggedInvestmentService(
+					service.NewInvestmentService(investmentRepo, investmentEventRepo, customerRepo, fundRepo, ledgerService, cfg.Allowance.Rules()),
+					logger,
+				),
+				m,
+			),
+			auditService,
+		),
+		tp,
+	)
+	switchService := service.NewTracedSwitchService(
+		service.NewAuditedSwitchService(
+			service.NewLoggedSwitchService(
+				service.NewSwitchService(switchRepo, investmentRepo, investmentEventRepo, customerRepo, fundRepo, ledgerService),
+				logger,
+			),
+			auditService,
+		),
+		tp,
+	)
+	planService := service.NewTracedPlanService(
+		service.NewAuditedPlanService(
+			service.NewLoggedPlanService(service.NewPlanService(planRepo, customerRepo, fundRepo, investmentService, cfg.Allowance.Rules()), logger),
+			auditService,
+		),
+		tp,
+	)
+	recommendationService := service.NewRecommendationService(customerRepo, investmentRepo, fundService)
+	approvalService := service.NewAuditedApprovalService(
+		service.NewLoggedApprovalService(service.NewApprovalService(approvalRepo, investmentService, customerService), logger),
+		auditService,
+	)
+	apiKeyService := service.NewAuditedAPIKeyService(
+		service.NewLoggedAPIKeyService(service.NewAPIKeyService(apiKeyRepo, customerRepo), logger),
+		auditService,
+	)
+
+	// Handlers are given services that check the caller's permissions.
+	// The services above use each other directly, once the call has been authorized.
+	authorizedFundService := service.NewAuthorizedFundService(fundService)
+	authorizedPlanService := service.NewAuthorizedPlanService(planService)
+
+	// Requests to the API are checked against its OpenAPI specification
+	spec, err := openapi.Load()
+	if err != nil {
+		fatal("Error loading OpenAPI specification", err)
+	}
+
+	// Set up router, tracing, counting and timing requests by route
 	r := mux.NewRouter()
+	r.Use(middleware.Trace(tp), middleware.Instrument(m))
+	r.NotFoundHandler = middleware.Trace(tp)(middleware.Instrument(m)(http.NotFoundHandler()))
+
+	// Liveness and readiness probes. Readiness checks storage, the audit log,
+	// that prices are arriving and that plans are being collected, and fails
+	// once shutdown starts so load balancers drain traffic.
+	checker := health.NewChecker(cfg.Health.CheckTimeout.Duration)
+	checker.Add("repository", health.RepositoryCheck(fundRepo))
+	checker.Add("audit", health.AuditCheck(auditService))
+
+	// Prices not arriving would fail every instance together, so by default the
+	// prices check is only reported, and alerted on through its metric
+	priceCheck := health.PriceCheck(investmentRepo, cfg.Health.PriceMaxAge.Duration)
+	if cfg.Health.PricesCritical {
+		checker.Add("prices", priceCheck)
+	} else {
+		checker.AddAdvisory("prices", priceCheck)
+	}
+	m.ObserveOldestUnpriced(func() (time.Duration, error) {
+		return service.OldestUnpricedAge(context.Background(), investmentRepo, time.Now())
+	})
+
+	// Partners call the API with an API key, optionally signing their requests
+	var apiMiddleware []mux.MiddlewareFunc
+	if cfg.Features.PartnerAPIKeys {
+		apiMiddleware = append(apiMiddleware, middleware.NewAPIKeyAuthenticator(apiKeyService).Middleware)
+	}
+
+	// Every other API request needs a bearer token signed by a key in the JWKS
+	// file, unless authentication is explicitly disabled for local development
+	if cfg.Auth.Disabled {
+		logger.Warn("Authentication is disabled, every caller has full access")
+		apiMiddleware = append(apiMiddleware, middleware.AuthDisabled)
+	} else {
+		keys, err := auth.LoadKeySet(cfg.Auth.JWKSPath)
+		if err != nil {
+			fatal("Error loading JWKS", err)
+		}
+		authenticator := middleware.NewAuthenticator(keys, cfg.Auth.Issuer, cfg.Auth.Audience)
+		apiMiddleware = append(apiMiddleware, authenticator.Middleware)
+	}
 
-	// API version prefix
-	api := r.PathPrefix("/api/v1").Subrouter()
+	// Requests are validated once the caller is authenticated, so only callers
+	// learn what the API expects
+	apiMiddleware = append(apiMiddleware, middleware.ValidateRequest(spec))
+	if cfg.OpenAPI.ValidateResponses {
+		logger.Warn("Response validation is on, every response is buffered")
+		apiMiddleware = append(apiMiddleware, middleware.ValidateResponse(spec, logger))
+	}
 
-	// Fund routes
-	api.HandleFunc("/funds", fundHandler.ListFunds).Methods("GET")
-	api.HandleFunc("/funds/{id}", fundHandler.GetFund).Methods("GET")
+	registerRoutes(r, routeHandlers{
+		metrics:         m.Handler(),
+		openAPI:         handler.NewOpenAPIHandler(spec),
+		health:          handler.NewHealthHandler(checker),
+		customers:       handler.NewCustomerHandler(service.NewAuthorizedCustomerService(customerService)),
+		funds:           handler.NewFundHandler(authorizedFundService),
+		investments:     handler.NewInvestmentHandler(service.NewAuthorizedInvestmentService(investmentService), authorizedFundService),
+		switches:        handler.NewSwitchHandler(service.NewAuthorizedSwitchService(switchService)),
+		plans:           handler.NewPlanHandler(authorizedPlanService),
+		recommendations: handler.NewRecommendationHandler(service.NewAuthorizedRecommendationService(recommendationService)),
+		ledger:          handler.NewLedgerHandler(service.NewAuthorizedLedgerService(ledgerService)),
+		approvals:       handler.NewApprovalHandler(service.NewAuthorizedApprovalService(approvalService)),
+		apiKeys:         handler.NewAPIKeyHandler(service.NewAuthorizedAPIKeyService(apiKeyService)),
+	}, cfg.Features, apiMiddleware...)
 
-	// Investment routes
-	api.HandleFunc("/investments", investmentHandler.CreateInvestment).Methods("POST")
-	api.HandleFunc("/investments/{id}", investmentHandler.GetInvestment).Methods("GET")
-	api.HandleFunc("/customers/{id}/investments", investmentHandler.GetCustomerInvestments).Methods("GET")
+	// Start executing regular contribution plans as they fall due
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	var planScheduler *scheduler.PlanScheduler
+	if cfg.Features.PlanScheduler {
+		planScheduler = scheduler.NewPlanScheduler(authorizedPlanService, time.Minute, logger)
+		planScheduler.Start(auth.WithPrincipal(schedulerCtx, &auth.Principal{Subject: "plan-scheduler", Roles: []auth.Role{auth.RoleOperations}}))
+		checker.Add("scheduler", planScheduler.Check)
+	}
 
-	// Configure server
+	// Keep retrying audit entries that could not be written, as readiness only
+	// reports them
+	auditCtx, stopAuditFlush := context.WithCancel(context.Background())
+	auditFlushDone := make(chan struct{})
+	go func() {
+		defer close(auditFlushDone)
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-auditCtx.Done():
+				return
+			case <-ticker.C:
+				_ = auditService.Flush(auditCtx)
+			}
+		}
+	}()
+
+	// Configure server. Every request gets an ID that its logs carry, is logged
+	// once served, and is given up on after the request timeout, before the write timeout.
 	srv := &http.Server{
-		Handler:      r,
-		Addr:         ":8080",
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+		Handler:      middleware.RequestID(middleware.AccessLog(logger)(middleware.Timeout(cfg.Server.RequestTimeout.Duration)(r))),
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		Addr:         cfg.Server.Addr,
+		WriteTimeout: cfg.Server.WriteTimeout.Duration,
+		ReadTimeout:  cfg.Server.ReadTimeout.Duration,
 	}
 
-	// Start server in a goroutine
+	// Start server in a goroutine, over HTTPS when TLS is configured
 	go func() {
-		log.Println("Retail ISA API starting on port 8080")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Error starting server: %s", err)
+		logger.Info("Retail ISA API starting", "addr", srv.Addr, "tls", cfg.Server.TLS.Enabled())
+		var err error
+		if cfg.Server.TLS.Enabled() {
+			err = srv.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			fatal("Error starting server", err)
 		}
 	}()
 
@@ -63,15 +256,44 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
 
-	// Create context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Fail readiness and keep serving while load balancers notice and stop sending traffic
+	logger.Info("Draining before shutdown", "delay", cfg.Server.DrainDelay.String())
+	checker.Drain()
+	time.Sleep(cfg.Server.DrainDelay.Duration)
+	logger.Info("Shutting down server")
+
+	// Stop collecting plans before the server goes away
+	stopScheduler()
+	if planScheduler != nil {
+		<-planScheduler.Done()
+	}
+
+	// Give in-flight requests until the shutdown timeout to finish
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %s", err)
+		fatal("Server forced to shutdown", err)
+	}
+
+	// Write any audit entries still queued
+	stopAuditFlush()
+	<-auditFlushDone
+	if err := auditService.Flush(ctx); err != nil {
+		logger.Error("Error writing audit log", "error", err)
 	}
 
-	log.Println("Server gracefully stopped")
+	// Export the spans of the last requests
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Error flushing traces", "error", err)
+	}
+
+	logger.Info("Server gracefully stopped")
+}
+
+// fatal logs an error the server cannot run with and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/handler"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/config"
+	"net/http"
+)
+
+// routeHandlers are the handlers requests are routed to
+type routeHandlers struct {
+	metrics         http.Handler
+	openAPI         *handler.OpenAPIHandler
+	health          *handler.HealthHandler
+	customers       *handler.CustomerHandler
+	funds           *handler.FundHandler
+	investments     *handler.InvestmentHandler
+	switches        *handler.SwitchHandler
+	plans           *handler.PlanHandler
+	recommendations *handler.RecommendationHandler
+	ledger          *handler.LedgerHandler
+	approvals       *handler.ApprovalHandler
+	apiKeys         *handler.APIKeyHandler
+}
+
+// registerRoutes adds every route to r, leaving out those of features that are
+// turned off. Routes under /api/v1 are served behind apiMiddleware, which
+// authenticates the caller. Every route the OpenAPI specification describes,
+// and no other, must be registered here.
+func registerRoutes(r *mux.Router, h routeHandlers, features config.Features, apiMiddleware ...mux.MiddlewareFunc) {
+	if features.Metrics {
+		r.Handle("/metrics", h.metrics).Methods("GET")
+	}
+	r.HandleFunc("/healthz", h.health.Live).Methods("GET")
+	r.HandleFunc("/readyz", h.health.Ready).Methods("GET")
+
+	// The specification is public, so it is routed before the API's middleware applies
+	r.HandleFunc("/api/v1/openapi.json", h.openAPI.GetSpec).Methods("GET")
+
+	// API version prefix
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(apiMiddleware...)
+
+	// Every route needs a permission from the matrix in package auth, and
+	// customers may only reach routes for their own customer ID
+	allow := func(permission auth.Permission, h http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(permission)(h)
+	}
+	customer := func(permission auth.Permission, h http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(permission)(middleware.RequireCustomerAccess(h))
+	}
+
+	// Fund routes
+	api.Handle("/funds", allow(auth.PermFundsRead, h.funds.ListFunds)).Methods("GET")
+	api.Handle("/funds/{id}", allow(auth.PermFundsRead, h.funds.GetFund)).Methods("GET")
+
+	// Fund catalogue administration routes
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Handle("/funds", allow(auth.PermFundsManage, h.funds.CreateFund)).Methods("POST")
+	admin.Handle("/funds/{id}", allow(auth.PermFundsManage, h.funds.UpdateFund)).Methods("PUT")
+	admin.Handle("/funds/{id}/soft-close", allow(auth.PermFundsManage, h.funds.SoftCloseFund)).Methods("POST")
+	admin.Handle("/funds/{id}/suspend", allow(auth.PermFundsManage, h.funds.SuspendFund)).Methods("POST")
+	admin.Handle("/funds/{id}/close", allow(auth.PermFundsManage, h.funds.CloseFund)).Methods("POST")
+	admin.Handle("/funds/{id}/reopen", allow(auth.PermFundsManage, h.funds.ReopenFund)).Methods("POST")
+	admin.Handle("/customers/{id}/fees", allow(auth.PermLedgerPost, h.ledger.RecordFee)).Methods("POST")
+	admin.Handle("/customers/{id}/dividends", allow(auth.PermLedgerPost, h.ledger.RecordDividend)).Methods("POST")
+
+	// Four-eyes approval routes for changes a second user must approve
+	admin.Handle("/approvals", allow(auth.PermApprovalsRequest, h.approvals.RequestApproval)).Methods("POST")
+	admin.Handle("/approvals", allow(auth.PermApprovalsRead, h.approvals.ListApprovals)).Methods("GET")
+	admin.Handle("/approvals/{id}", allow(auth.PermApprovalsRead, h.approvals.GetApproval)).Methods("GET")
+	admin.Handle("/approvals/{id}/approve", allow(auth.PermApprovalsDecide, h.approvals.Approve)).Methods("POST")
+	admin.Handle("/approvals/{id}/reject", allow(auth.PermApprovalsDecide, h.approvals.Reject)).Methods("POST")
+
+	// Partner API key routes
+	if features.PartnerAPIKeys {
+		admin.Handle("/partners/{id}/api-keys", allow(auth.PermAPIKeysManage, h.apiKeys.IssueAPIKey)).Methods("POST")
+		admin.Handle("/partners/{id}/api-keys", allow(auth.PermAPIKeysManage, h.apiKeys.GetPartnerAPIKeys)).Methods("GET")
+		admin.Handle("/api-keys/{id}", allow(auth.PermAPIKeysManage, h.apiKeys.GetAPIKey)).Methods("GET")
+		admin.Handle("/api-keys/{id}/rotate", allow(auth.PermAPIKeysManage, h.apiKeys.RotateAPIKey)).Methods("POST")
+		admin.Handle("/api-keys/{id}/revoke", allow(auth.PermAPIKeysManage, h.apiKeys.RevokeAPIKey)).Methods("POST")
+	}
+
+	// Customer routes
+	api.Handle("/risk-questionnaire", allow(auth.PermCustomersRead, h.customers.GetRiskQuestionnaire)).Methods("GET")
+	api.Handle("/customers/{id}", customer(auth.PermCustomersRead, h.customers.GetCustomer)).Methods("GET")
+	api.Handle("/customers/{id}/risk-profile", customer(auth.PermRiskProfileSubmit, h.customers.SubmitRiskProfile)).Methods("POST")
+	if features.Recommendations {
+		api.Handle("/customers/{id}/recommendations", customer(auth.PermRecommendationsRead, h.recommendations.GetRecommendations)).Methods("GET")
+	}
+
+	// Investment routes
+	api.Handle("/investments", allow(auth.PermInvestmentsCreate, h.investments.CreateInvestment)).Methods("POST")
+	api.Handle("/investments/{id}", allow(auth.PermInvestmentsRead, h.investments.GetInvestment)).Methods("GET")
+	api.Handle("/investments/{id}/events", allow(auth.PermInvestmentsRead, h.investments.GetInvestmentEvents)).Methods("GET")
+	api.Handle("/investments/{id}/price", allow(auth.PermInvestmentsDeal, h.investments.PriceInvestment)).Methods("POST")
+	api.Handle("/investments/{id}/process", allow(auth.PermInvestmentsDeal, h.investments.ProcessInvestment)).Methods("POST")
+	api.Handle("/investments/{id}/withdraw", allow(auth.PermInvestmentsWithdraw, h.investments.WithdrawInvestment)).Methods("POST")
+	api.Handle("/investments/{id}/cancel", allow(auth.PermInvestmentsCancel, h.investments.CancelInvestment)).Methods("POST")
+	api.Handle("/customers/{id}/investments", customer(auth.PermInvestmentsRead, h.investments.GetCustomerInvestments)).Methods("GET")
+
+	// Ledger routes
+	api.Handle("/customers/{id}/balances", customer(auth.PermLedgerRead, h.ledger.GetCustomerBalances)).Methods("GET")
+	api.Handle("/customers/{id}/journals", customer(auth.PermLedgerRead, h.ledger.GetCustomerJournals)).Methods("GET")
+
+	// Switch routes
+	api.Handle("/switches", allow(auth.PermSwitchesCreate, h.switches.CreateSwitch)).Methods("POST")
+	api.Handle("/switches/{id}", allow(auth.PermSwitchesRead, h.switches.GetSwitch)).Methods("GET")
+
+	// Regular contribution plan routes
+	api.Handle("/customers/{id}/plans", customer(auth.PermPlansRead, h.plans.ListPlans)).Methods("GET")
+	api.Handle("/customers/{id}/plans", customer(auth.PermPlansManage, h.plans.CreatePlan)).Methods("POST")
+	api.Handle("/customers/{id}/plans/{planID}", customer(auth.PermPlansRead, h.plans.GetPlan)).Methods("GET")
+	api.Handle("/customers/{id}/plans/{planID}", customer(auth.PermPlansManage, h.plans.UpdatePlan)).Methods("PUT")
+	api.Handle("/customers/{id}/plans/{planID}", customer(auth.PermPlansManage, h.plans.DeletePlan)).Methods("DELETE")
+}
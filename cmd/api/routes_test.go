@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/handler"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/config"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/health"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allFeatures turns every optional route on
+var allFeatures = config.Features{PlanScheduler: true, PartnerAPIKeys: true, Recommendations: true, Metrics: true}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	registerRoutes(r, routeHandlers{}, allFeatures)
+
+	var routed []string
+	err = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Path prefixes of subrouters
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routed = append(routed, method+" "+template)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	var described []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			described = append(described, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routed)
+	sort.Strings(described)
+	assert.Equal(t, described, routed, "every route must be described in internal/api/openapi/openapi.json")
+}
+
+// specServer serves the API with in-memory storage and authentication
+// disabled, failing the test when a response does not match the specification
+type specServer struct {
+	t      *testing.T
+	spec   *openapi.Spec
+	router *mux.Router
+}
+
+func newSpecServer(t *testing.T) *specServer {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	customerRepo := repository.NewInMemoryCustomerRepository()
+	fundRepo := repository.NewInMemoryFundRepository()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	investmentEventRepo := repository.NewInMemoryInvestmentEventRepository()
+	apiKeyRepo := repository.NewInMemoryAPIKeyRepository()
+
+	customerService := service.NewCustomerService(customerRepo)
+	fundService := service.NewFundService(fundRepo)
+	ledgerService := service.NewLedgerService(repository.NewInMemoryLedgerRepository(), customerRepo)
+	investmentService := service.NewInvestmentService(investmentRepo, investmentEventRepo, customerRepo, fundRepo, ledgerService, domain.DefaultAllowanceRules)
+	switchService := service.NewSwitchService(repository.NewInMemorySwitchRepository(), investmentRepo, investmentEventRepo, customerRepo, fundRepo, ledgerService)
+	planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), customerRepo, fundRepo, investmentService, domain.DefaultAllowanceRules)
+	approvalService := service.NewApprovalService(repository.NewInMemoryApprovalRepository(), investmentService, customerService)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, customerRepo)
+
+	checker := health.NewChecker(time.Second)
+	checker.Add("repository", health.RepositoryCheck(fundRepo))
+
+	r := mux.NewRouter()
+	registerRoutes(r, routeHandlers{
+		metrics:         metrics.New().Handler(),
+		openAPI:         handler.NewOpenAPIHandler(spec),
+		health:          handler.NewHealthHandler(checker),
+		customers:       handler.NewCustomerHandler(customerService),
+		funds:           handler.NewFundHandler(fundService),
+		investments:     handler.NewInvestmentHandler(investmentService, fundService),
+		switches:        handler.NewSwitchHandler(switchService),
+		plans:           handler.NewPlanHandler(planService),
+		recommendations: handler.NewRecommendationHandler(service.NewRecommendationService(customerRepo, investmentRepo, fundService)),
+		ledger:          handler.NewLedgerHandler(ledgerService),
+		approvals:       handler.NewApprovalHandler(approvalService),
+		apiKeys:         handler.NewAPIKeyHandler(apiKeyService),
+	}, allFeatures, middleware.AuthDisabled, middleware.ValidateRequest(spec))
+
+	return &specServer{t: t, spec: spec, router: r}
+}
+
+// call makes a request, checks it is answered with status and that the
+// response matches the specification, and returns the response
+func (s *specServer) call(status int, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	return s.callAs(context.Background(), status, method, target, body, header)
+}
+
+// callAs is like call with the request's context, which may carry the caller
+func (s *specServer) callAs(ctx context.Context, status int, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if !assert.Equal(s.t, status, rec.Code, "%s %s: %s", method, target, rec.Body.String()) {
+		return rec
+	}
+
+	var match mux.RouteMatch
+	require.True(s.t, s.router.Match(req, &match), "%s %s is not routed", method, target)
+	template, err := match.Route.GetPathTemplate()
+	require.NoError(s.t, err)
+	op := s.spec.Operation(method, template)
+	require.NotNil(s.t, op, "%s %s is not described", method, template)
+
+	assert.NoError(s.t, s.spec.ValidateResponse(op, rec.Code, rec.Header(), rec.Body.Bytes()), "%s %s", method, target)
+	return rec
+}
+
+// idOf is the ID of the entity in a response
+func idOf(t *testing.T, rec *httptest.ResponseRecorder) string {
+	var entity struct {
+		ID string `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entity))
+	return entity.ID
+}
+
+// ifMatch is the If-Match header for a response's ETag
+func ifMatch(rec *httptest.ResponseRecorder) map[string]string {
+	return map[string]string{"If-Match": rec.Header().Get("ETag")}
+}
+
+// processedInvestment subscribes customer-1 to a fund, prices and processes
+// the investment, and returns the processed investment
+func (s *specServer) processedInvestment(t *testing.T, fundID, amount string) *httptest.ResponseRecorder {
+	created := s.call(http.StatusCreated, "POST", "/api/v1/investments", `{"customer_id":"customer-1","fund_id":"`+fundID+`","amount":"`+amount+`"}`, nil)
+	path := "/api/v1/investments/" + idOf(t, created)
+	got := s.call(http.StatusOK, "GET", path, "", nil)
+	priced := s.call(http.StatusOK, "POST", path+"/price", `{"unit_price":"1.25"}`, ifMatch(got))
+	return s.call(http.StatusOK, "POST", path+"/process", "", ifMatch(priced))
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	s := newSpecServer(t)
+
+	t.Run("health", func(t *testing.T) {
+		s.call(http.StatusOK, "GET", "/healthz", "", nil)
+		s.call(http.StatusOK, "GET", "/readyz", "", nil)
+		s.call(http.StatusOK, "GET", "/metrics", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/openapi.json", "", nil)
+	})
+
+	t.Run("funds", func(t *testing.T) {
+		s.call(http.StatusOK, "GET", "/api/v1/funds?risk_level=high,medium&sort=-name&limit=2", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/funds/fund-1", "", nil)
+		s.call(http.StatusNotFound, "GET", "/api/v1/funds/missing", "", nil)
+		s.call(http.StatusBadRequest, "GET", "/api/v1/funds?limit=many", "", nil)
+
+		fund := `{"name":"Global Bond","description":"Government bonds","risk_level":"low","asset_class":"fixed_income","ongoing_charge_bps":15}`
+		created := s.call(http.StatusCreated, "POST", "/api/v1/admin/funds", fund, nil)
+		id := idOf(t, created)
+		updated := s.call(http.StatusOK, "PUT", "/api/v1/admin/funds/"+id, fund, ifMatch(created))
+		s.call(http.StatusPreconditionRequired, "POST", "/api/v1/admin/funds/"+id+"/suspend", "", nil)
+		suspended := s.call(http.StatusOK, "POST", "/api/v1/admin/funds/"+id+"/suspend", "", ifMatch(updated))
+		s.call(http.StatusConflict, "POST", "/api/v1/admin/funds/"+id+"/suspend", "", ifMatch(suspended))
+		reopened := s.call(http.StatusOK, "POST", "/api/v1/admin/funds/"+id+"/reopen", "", ifMatch(suspended))
+		softClosed := s.call(http.StatusOK, "POST", "/api/v1/admin/funds/"+id+"/soft-close", "", ifMatch(reopened))
+		s.call(http.StatusPreconditionFailed, "POST", "/api/v1/admin/funds/"+id+"/close", "", ifMatch(reopened))
+		s.call(http.StatusOK, "POST", "/api/v1/admin/funds/"+id+"/close", "", ifMatch(softClosed))
+	})
+
+	t.Run("customers", func(t *testing.T) {
+		s.call(http.StatusOK, "GET", "/api/v1/risk-questionnaire", "", nil)
+		support := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "support-1", Roles: []auth.Role{auth.RoleSupport}})
+		s.callAs(support, http.StatusOK, "GET", "/api/v1/risk-questionnaire", "", nil)
+		s.call(http.StatusUnprocessableEntity, "GET", "/api/v1/customers/customer-1/recommendations", "", nil)
+		s.call(http.StatusOK, "POST", "/api/v1/customers/customer-1/risk-profile", `{"answers":{"horizon":3,"experience":3,"reaction":3,"goal":3}}`, nil)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1/recommendations?horizon_years=10", "", nil)
+	})
+
+	t.Run("investments", func(t *testing.T) {
+		s.call(http.StatusBadRequest, "POST", "/api/v1/investments", `{"customer_id":"customer-1","fund_id":"fund-1","amount":"ten pounds"}`, nil)
+		processed := s.processedInvestment(t, "fund-1", "1000.00")
+		id := idOf(t, processed)
+		s.call(http.StatusConflict, "POST", "/api/v1/investments/"+id+"/price", `{"unit_price":"1.30"}`, ifMatch(processed))
+		s.call(http.StatusOK, "GET", "/api/v1/investments/"+id+"/events", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1/investments?status=processed&limit=1", "", nil)
+
+		s.call(http.StatusCreated, "POST", "/api/v1/admin/customers/customer-1/dividends", `{"fund_id":"fund-1","amount":"5.00"}`, nil)
+		s.call(http.StatusCreated, "POST", "/api/v1/admin/customers/customer-1/fees", `{"amount":"2.50"}`, nil)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1/balances", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1/journals", "", nil)
+
+		switched := s.call(http.StatusCreated, "POST", "/api/v1/switches", `{"customer_id":"customer-1","from_fund_id":"fund-1","to_fund_id":"fund-2","amount":"100.00"}`, nil)
+		s.call(http.StatusOK, "GET", "/api/v1/switches/"+idOf(t, switched), "", nil)
+
+		withdrawn := s.processedInvestment(t, "fund-3", "50.00")
+		s.call(http.StatusOK, "POST", "/api/v1/investments/"+idOf(t, withdrawn)+"/withdraw", "", ifMatch(withdrawn))
+
+		created := s.call(http.StatusCreated, "POST", "/api/v1/investments", `{"customer_id":"customer-1","fund_id":"fund-3","amount":"10.00"}`, nil)
+		s.call(http.StatusOK, "POST", "/api/v1/investments/"+idOf(t, created)+"/cancel", "", map[string]string{"If-Match": `"1"`})
+	})
+
+	t.Run("plans", func(t *testing.T) {
+		created := s.call(http.StatusCreated, "POST", "/api/v1/customers/customer-1/plans", `{"fund_id":"fund-2","amount":"100.00","day_of_month":28}`, nil)
+		path := "/api/v1/customers/customer-1/plans/" + idOf(t, created)
+		s.call(http.StatusOK, "GET", "/api/v1/customers/customer-1/plans", "", nil)
+		s.call(http.StatusOK, "GET", path, "", nil)
+		s.call(http.StatusPreconditionRequired, "PUT", path, `{"amount":"150.00","day_of_month":1,"status":"paused"}`, nil)
+		updated := s.call(http.StatusOK, "PUT", path, `{"amount":"150.00","day_of_month":1,"status":"paused"}`, ifMatch(created))
+		s.call(http.StatusPreconditionRequired, "DELETE", path, "", nil)
+		s.call(http.StatusPreconditionFailed, "DELETE", path, "", ifMatch(created))
+		s.call(http.StatusNoContent, "DELETE", path, "", ifMatch(updated))
+		s.call(http.StatusNotFound, "GET", "/api/v1/customers/customer-1/plans/missing", "", nil)
+
+		split := s.call(http.StatusCreated, "POST", "/api/v1/customers/customer-1/plans", `{"allocations":[{"fund_id":"fund-2","amount":"60.00"},{"fund_id":"fund-3","amount":"40.00"}],"day_of_month":1}`, nil)
+		s.call(http.StatusBadRequest, "PUT", "/api/v1/customers/customer-1/plans/"+idOf(t, split), `{"amount":"150.00","day_of_month":1}`, ifMatch(split))
+		s.call(http.StatusBadRequest, "POST", "/api/v1/customers/customer-1/plans", `{"fund_id":"fund-2","amount":"60.00","allocations":[{"fund_id":"fund-3","amount":"40.00"}],"day_of_month":1}`, nil)
+	})
+
+	t.Run("approvals", func(t *testing.T) {
+		processed := s.processedInvestment(t, "fund-3", "20.00")
+		body := `{"action":"investment.cancel","entity_id":"` + idOf(t, processed) + `","reason":"Customer request"}`
+
+		requested := s.call(http.StatusAccepted, "POST", "/api/v1/admin/approvals", body, ifMatch(processed))
+		approvalID := idOf(t, requested)
+		s.call(http.StatusOK, "GET", "/api/v1/admin/approvals?status=pending", "", nil)
+		s.call(http.StatusOK, "GET", "/api/v1/admin/approvals/"+approvalID, "", nil)
+		s.call(http.StatusForbidden, "POST", "/api/v1/admin/approvals/"+approvalID+"/approve", "", nil)
+
+		reviewer := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "reviewer", Roles: []auth.Role{auth.RoleAdmin}})
+		s.callAs(reviewer, http.StatusOK, "POST", "/api/v1/admin/approvals/"+approvalID+"/approve", "", nil)
+		s.callAs(reviewer, http.StatusConflict, "POST", "/api/v1/admin/approvals/"+approvalID+"/reject", `{"note":"too late"}`, nil)
+	})
+
+	t.Run("api keys", func(t *testing.T) {
+		issued := s.call(http.StatusCreated, "POST", "/api/v1/admin/partners/partner-1/api-keys", `{"name":"Partner","scopes":["funds:read"],"customer_ids":["customer-1"]}`, nil)
+		id := idOf(t, issued)
+		s.call(http.StatusBadRequest, "POST", "/api/v1/admin/partners/partner-1/api-keys", `{"name":"Partner","scopes":["funds:manage"]}`, nil)
+		s.call(http.StatusOK, "GET", "/api/v1/admin/partners/partner-1/api-keys", "", nil)
+		got := s.call(http.StatusOK, "GET", "/api/v1/admin/api-keys/"+id, "", nil)
+		rotated := s.call(http.StatusCreated, "POST", "/api/v1/admin/api-keys/"+id+"/rotate", "", ifMatch(got))
+		newID := idOf(t, rotated)
+		s.call(http.StatusOK, "POST", "/api/v1/admin/api-keys/"+newID+"/revoke", "", ifMatch(rotated))
+	})
+}
@@ -0,0 +1,31 @@
+// Command auditverify checks an audit log file written by the API has not been
+// tampered with, exiting with status 1 if its hash chain is broken.
+//
+//	go run ./cmd/auditverify -file audit.log
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"os"
+)
+
+func main() {
+	path := flag.String("file", "audit.log", "path to the audit log")
+	flag.Parse()
+
+	entries, err := repository.ReadAuditLog(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading audit log: %s\n", err)
+		os.Exit(2)
+	}
+
+	if err := domain.VerifyAuditChain(entries); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Audit log intact: %d entries verified\n", len(entries))
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+// authorizeCustomer writes a 403 and returns false when the caller may not act
+// for the customer, for requests whose customer is in the body or the entity
+// rather than the path
+func authorizeCustomer(w http.ResponseWriter, r *http.Request, customerID string) bool {
+	if !auth.CanAccessCustomer(r.Context(), customerID) {
+		http.Error(w, "access to this customer is forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// serviceError writes an error returned by a service with the given status,
+// 403 when the service refused the caller or the change needs approval, or 503
+// when the request was cancelled or ran out of time before the service finished
+func serviceError(w http.ResponseWriter, err error, status int) {
+	switch {
+	case errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrApprovalRequired):
+		status = http.StatusForbidden
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
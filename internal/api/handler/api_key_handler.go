@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+// APIKeyHandler handles HTTP requests for managing partners' API keys
+type APIKeyHandler struct {
+	APIKeyService domain.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(ks domain.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyService: ks,
+	}
+}
+
+// IssueAPIKey handles POST /admin/partners/{id}/api-keys. The response holds the
+// key's secrets, which cannot be retrieved again.
+func (h *APIKeyHandler) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req domain.APIKeySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	issued, err := h.APIKeyService.IssueAPIKey(r.Context(), vars["id"], req)
+	if err != nil {
+		serviceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeIssuedAPIKey(w, issued)
+}
+
+// GetPartnerAPIKeys handles GET /admin/partners/{id}/api-keys
+func (h *APIKeyHandler) GetPartnerAPIKeys(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	keys, err := h.APIKeyService.GetPartnerAPIKeys(r.Context(), vars["id"])
+	if err != nil {
+		serviceError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// GetAPIKey handles GET /admin/api-keys/{id}
+func (h *APIKeyHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	key, err := h.APIKeyService.GetAPIKey(r.Context(), vars["id"])
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	setETag(w, key.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// RotateAPIKey handles POST /admin/api-keys/{id}/rotate, which requires an
+// If-Match header. The response is the replacement key with its secrets.
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	issued, err := h.APIKeyService.RotateAPIKey(r.Context(), vars["id"], version)
+	if err != nil {
+		serviceError(w, err, statusForAPIKeyError(err))
+		return
+	}
+
+	writeIssuedAPIKey(w, issued)
+}
+
+// RevokeAPIKey handles POST /admin/api-keys/{id}/revoke, which requires an If-Match header
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.APIKeyService.RevokeAPIKey(r.Context(), vars["id"], version)
+	if err != nil {
+		serviceError(w, err, statusForAPIKeyError(err))
+		return
+	}
+
+	setETag(w, key.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// writeIssuedAPIKey writes a newly issued key, which must not be cached
+func writeIssuedAPIKey(w http.ResponseWriter, issued *domain.IssuedAPIKey) {
+	setETag(w, issued.Version)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issued)
+}
+
+// statusForAPIKeyError maps errors from rotating or revoking a key to an HTTP status
+func statusForAPIKeyError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrAPIKeyNotActive):
+		return http.StatusConflict
+	default:
+		return http.StatusNotFound
+	}
+}
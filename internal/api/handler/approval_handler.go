@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+// ApprovalHandler handles HTTP requests for the four-eyes approval of sensitive changes
+type ApprovalHandler struct {
+	ApprovalService domain.ApprovalService
+}
+
+// NewApprovalHandler creates a new approval handler
+func NewApprovalHandler(as domain.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{
+		ApprovalService: as,
+	}
+}
+
+// ApprovalRequest is the request for a change that needs approval
+type ApprovalRequest struct {
+	Action   string `json:"action"`    // investment.cancel or customer.update
+	EntityID string `json:"entity_id"` // The investment or customer to change
+	Reason   string `json:"reason"`
+	// CustomerDetails are the new details for customer.update
+	CustomerDetails *domain.CustomerDetails `json:"customer_details,omitempty"`
+}
+
+// DecisionRequest is the request for rejecting an approval
+type DecisionRequest struct {
+	Note string `json:"note"`
+}
+
+// RequestApproval handles POST /admin/approvals, which requires an If-Match
+// header with the ETag of the entity to change
+func (h *ApprovalHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
+	var req ApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var approval *domain.Approval
+	var err error
+	switch domain.ApprovalAction(req.Action) {
+	case domain.ApprovalActionCancelInvestment:
+		approval, err = h.ApprovalService.RequestInvestmentCancellation(r.Context(), req.EntityID, version, req.Reason)
+	case domain.ApprovalActionUpdateCustomer:
+		if req.CustomerDetails == nil {
+			http.Error(w, "customer_details are required", http.StatusBadRequest)
+			return
+		}
+		approval, err = h.ApprovalService.RequestCustomerUpdate(r.Context(), req.EntityID, *req.CustomerDetails, version, req.Reason)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrConflict):
+			status = http.StatusPreconditionFailed
+		case errors.Is(err, domain.ErrInvestmentNotCancellable):
+			status = http.StatusConflict
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	// The change is accepted but not made until a second user approves it
+	setETag(w, approval.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(approval)
+}
+
+// ListApprovals handles GET /admin/approvals, optionally filtered by ?status=
+func (h *ApprovalHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	status := domain.ApprovalStatus(r.URL.Query().Get("status"))
+
+	approvals, err := h.ApprovalService.ListApprovals(r.Context(), status)
+	if err != nil {
+		serviceError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
+
+// GetApproval handles GET /admin/approvals/{id}
+func (h *ApprovalHandler) GetApproval(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	approval, err := h.ApprovalService.GetApproval(r.Context(), vars["id"])
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	setETag(w, approval.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// Approve handles POST /admin/approvals/{id}/approve. An approval whose change
+// could not be made is returned with a 409.
+func (h *ApprovalHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	approval, err := h.ApprovalService.Approve(r.Context(), vars["id"])
+	if err != nil {
+		serviceError(w, err, statusForDecisionError(err))
+		return
+	}
+
+	setETag(w, approval.Version)
+	w.Header().Set("Content-Type", "application/json")
+	if approval.Status == domain.ApprovalStatusFailed {
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(approval)
+}
+
+// Reject handles POST /admin/approvals/{id}/reject
+func (h *ApprovalHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req DecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	approval, err := h.ApprovalService.Reject(r.Context(), vars["id"], req.Note)
+	if err != nil {
+		serviceError(w, err, statusForDecisionError(err))
+		return
+	}
+
+	setETag(w, approval.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
+
+// statusForDecisionError maps errors from approving or rejecting to an HTTP status
+func statusForDecisionError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrSelfApproval):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrApprovalNotPending), errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusNotFound
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+// CustomerHandler handles HTTP requests related to customers
+type CustomerHandler struct {
+	CustomerService domain.CustomerService
+}
+
+// NewCustomerHandler creates a new customer handler
+func NewCustomerHandler(cs domain.CustomerService) *CustomerHandler {
+	return &CustomerHandler{
+		CustomerService: cs,
+	}
+}
+
+// RiskProfileRequest is the request for submitting the risk questionnaire
+type RiskProfileRequest struct {
+	Answers map[string]int `json:"answers"` // Question ID to chosen option index
+}
+
+// RiskProfileResponse is the response describing a customer's risk profile
+type RiskProfileResponse struct {
+	CustomerID    string `json:"customer_id"`
+	RiskScore     int    `json:"risk_score"`
+	RiskTolerance string `json:"risk_tolerance"`
+	ProfiledAt    string `json:"profiled_at"`
+}
+
+// GetCustomer handles GET /customers/{id}
+func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	customer, err := h.CustomerService.GetCustomer(r.Context(), id)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	setETag(w, customer.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+// GetRiskQuestionnaire handles GET /risk-questionnaire
+func (h *CustomerHandler) GetRiskQuestionnaire(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.CustomerService.GetRiskQuestionnaire(r.Context()))
+}
+
+// SubmitRiskProfile handles POST /customers/{id}/risk-profile
+func (h *CustomerHandler) SubmitRiskProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req RiskProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	customer, err := h.CustomerService.SubmitRiskQuestionnaire(r.Context(), id, req.Answers)
+	if err != nil {
+		serviceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	response := RiskProfileResponse{
+		CustomerID:    customer.ID,
+		RiskScore:     customer.RiskScore,
+		RiskTolerance: string(customer.RiskTolerance),
+		ProfiledAt:    customer.RiskProfiledAt.Format("2006-01-02 15:04:05"),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
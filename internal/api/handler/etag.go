@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMissingIfMatch = errors.New("If-Match header with the entity's ETag is required")
+	errInvalidIfMatch = errors.New("invalid If-Match header")
+)
+
+// setETag sets the ETag header from an entity version
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatchVersion reads the entity version from the If-Match header. It returns
+// 0 when the header is absent or "*", meaning the version is not checked.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(v, "W/"), `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errInvalidIfMatch
+	}
+	return version, nil
+}
+
+// requireIfMatchVersion is like ifMatchVersion but writes a 428 or 400 response
+// and returns false when the request does not carry a usable If-Match header
+func requireIfMatchVersion(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if version == 0 {
+		http.Error(w, errMissingIfMatch.Error(), http.StatusPreconditionRequired)
+		return 0, false
+	}
+	return version, true
+}
@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gorilla/mux"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // FundHandler handles HTTP requests related to funds
@@ -24,24 +28,197 @@ func (h *FundHandler) GetFund(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	fund, err := h.FundUseCase.GetFund(id)
+	fund, err := h.FundUseCase.GetFund(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		serviceError(w, err, http.StatusNotFound)
 		return
 	}
 
+	setETag(w, fund.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(fund)
 }
 
 // ListFunds handles GET /funds
+//
+// Supported query parameters:
+//   - q: text to search for in the fund name and description
+//   - status, risk_level, asset_class: comma separated values to filter by
+//   - max_charge_bps: maximum ongoing charge in basis points
+//   - sort: name, risk_level, ongoing_charge or created_at, prefixed with - for descending
+//   - limit, offset: pagination, with the total number of matches in X-Total-Count
 func (h *FundHandler) ListFunds(w http.ResponseWriter, r *http.Request) {
-	funds, err := h.FundUseCase.ListFunds()
+	query := r.URL.Query()
+
+	filter := domain.FundFilter{
+		Query: query.Get("q"),
+	}
+	for _, status := range splitQueryList(query.Get("status")) {
+		filter.Statuses = append(filter.Statuses, domain.FundStatus(status))
+	}
+	for _, riskLevel := range splitQueryList(query.Get("risk_level")) {
+		filter.RiskLevels = append(filter.RiskLevels, domain.RiskLevel(riskLevel))
+	}
+	for _, assetClass := range splitQueryList(query.Get("asset_class")) {
+		filter.AssetClasses = append(filter.AssetClasses, domain.AssetClass(assetClass))
+	}
+	if sort := query.Get("sort"); sort != "" {
+		filter.SortDesc = strings.HasPrefix(sort, "-")
+		filter.SortBy = domain.FundSortField(strings.TrimPrefix(sort, "-"))
+	}
+
+	var err error
+	for name, target := range map[string]*int{
+		"max_charge_bps": &filter.MaxOngoingChargeBps,
+		"limit":          &filter.Limit,
+		"offset":         &filter.Offset,
+	} {
+		if v := query.Get(name); v != "" {
+			if *target, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	funds, total, err := h.FundUseCase.ListFunds(r.Context(), filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serviceError(w, err, http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(funds)
 }
+
+// splitQueryList splits a comma separated query parameter, ignoring empty values
+func splitQueryList(v string) []string {
+	var values []string
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// FundRequest is the request for creating or updating a fund
+type FundRequest struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	RiskLevel        string `json:"risk_level"`
+	AssetClass       string `json:"asset_class"`
+	OngoingChargeBps int    `json:"ongoing_charge_bps"`
+}
+
+// details converts the request into the fund fields it sets
+func (req FundRequest) details() domain.FundDetails {
+	return domain.FundDetails{
+		Name:             req.Name,
+		Description:      req.Description,
+		RiskLevel:        domain.RiskLevel(req.RiskLevel),
+		AssetClass:       domain.AssetClass(req.AssetClass),
+		OngoingChargeBps: req.OngoingChargeBps,
+	}
+}
+
+// CreateFund handles POST /admin/funds
+func (h *FundHandler) CreateFund(w http.ResponseWriter, r *http.Request) {
+	var req FundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.FundUseCase.CreateFund(r.Context(), req.details())
+	if err != nil {
+		serviceError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	setETag(w, fund.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(fund)
+}
+
+// UpdateFund handles PUT /admin/funds/{id}, which requires an If-Match header
+func (h *FundHandler) UpdateFund(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var req FundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fund, err := h.FundUseCase.UpdateFund(r.Context(), id, req.details(), version)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	setETag(w, fund.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fund)
+}
+
+// SoftCloseFund handles POST /admin/funds/{id}/soft-close
+func (h *FundHandler) SoftCloseFund(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.FundUseCase.SoftCloseFund)
+}
+
+// SuspendFund handles POST /admin/funds/{id}/suspend
+func (h *FundHandler) SuspendFund(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.FundUseCase.SuspendFund)
+}
+
+// CloseFund handles POST /admin/funds/{id}/close
+func (h *FundHandler) CloseFund(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.FundUseCase.CloseFund)
+}
+
+// ReopenFund handles POST /admin/funds/{id}/reopen
+func (h *FundHandler) ReopenFund(w http.ResponseWriter, r *http.Request) {
+	h.changeStatus(w, r, h.FundUseCase.ReopenFund)
+}
+
+// changeStatus applies a fund status change to the fund in the path.
+// Status changes require an If-Match header.
+func (h *FundHandler) changeStatus(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error)) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	fund, err := change(r.Context(), id, version)
+	if err != nil {
+		status := http.StatusNotFound
+		switch {
+		case errors.Is(err, domain.ErrConflict):
+			status = http.StatusPreconditionFailed
+		case errors.Is(err, domain.ErrInvalidFundStatusChange):
+			status = http.StatusConflict
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	setETag(w, fund.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fund)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/grokkos/go-isa-retail-service/internal/health"
+	"net/http"
+)
+
+// HealthHandler handles the liveness and readiness probes
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(c *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		Checker: c,
+	}
+}
+
+// Live handles GET /healthz. It only shows the process is serving requests, so
+// a failing dependency never gets a healthy instance restarted.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready handles GET /readyz, answering 503 when a check fails or the server
+// is draining before shutdown
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.Checker.Ready(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
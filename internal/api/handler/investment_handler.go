@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // InvestmentHandler handles HTTP requests related to investments
@@ -27,6 +31,8 @@ type CreateInvestmentRequest struct {
 	CustomerID string `json:"customer_id"`
 	FundID     string `json:"fund_id"`
 	Amount     string `json:"amount"` // Amount as string (e.g., "25000.00")
+	// RiskAcknowledged confirms the customer accepts a fund riskier than their risk tolerance
+	RiskAcknowledged bool `json:"risk_acknowledged"`
 }
 
 // CreateInvestmentResponse is the response for creating an investment
@@ -37,6 +43,7 @@ type CreateInvestmentResponse struct {
 	FundName    string  `json:"fund_name"`
 	Amount      string  `json:"amount"`
 	AmountValue float64 `json:"amount_value"`
+	RiskWarning string  `json:"risk_warning,omitempty"`
 	Status      string  `json:"status"`
 	CreatedAt   string  `json:"created_at"`
 }
@@ -49,23 +56,26 @@ func (h *InvestmentHandler) CreateInvestment(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if !authorizeCustomer(w, r, req.CustomerID) {
+		return
+	}
+
 	// Convert amount to pence (int64)
-	amountFloat, err := strconv.ParseFloat(req.Amount, 64)
+	amountPence, err := domain.ParsePence(req.Amount)
 	if err != nil {
 		http.Error(w, "Invalid amount format", http.StatusBadRequest)
 		return
 	}
-	amountPence := int64(amountFloat * 100)
 
-	investment, err := h.InvestmentService.CreateInvestment(req.CustomerID, req.FundID, amountPence)
+	investment, err := h.InvestmentService.CreateInvestment(r.Context(), req.CustomerID, req.FundID, amountPence, req.RiskAcknowledged)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		serviceError(w, err, statusForCreateError(err))
 		return
 	}
 
 	// Properly look up the fund name using the FundService
 	var fundName string
-	fund, err := h.FundService.GetFund(investment.FundID)
+	fund, err := h.FundService.GetFund(r.Context(), investment.FundID)
 	if err != nil {
 		// If we can't find the fund, use a placeholder but don't fail the request
 		fundName = "Unknown Fund"
@@ -80,6 +90,7 @@ func (h *InvestmentHandler) CreateInvestment(w http.ResponseWriter, r *http.Requ
 		FundName:    fundName,
 		Amount:      req.Amount,
 		AmountValue: float64(investment.Amount) / 100.0,
+		RiskWarning: riskWarning(investment.RiskAcknowledged),
 		Status:      string(investment.Status),
 		CreatedAt:   investment.CreatedAt.Format("2006-01-02 15:04:05"),
 	}
@@ -89,19 +100,32 @@ func (h *InvestmentHandler) CreateInvestment(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(response)
 }
 
+// InvestmentDetailResponse is an investment with its fund's name
+type InvestmentDetailResponse struct {
+	ID         string  `json:"id"`
+	CustomerID string  `json:"customer_id"`
+	FundID     string  `json:"fund_id"`
+	FundName   string  `json:"fund_name"`
+	Amount     float64 `json:"amount"`
+	Type       string  `json:"type"`
+	SwitchID   string  `json:"switch_id,omitempty"`
+	UnitPrice  float64 `json:"unit_price,omitempty"`
+	Units      float64 `json:"units,omitempty"`
+	Status     string  `json:"status"`
+	Version    int64   `json:"version"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
 // GetInvestment handles GET /investments/{id}
 func (h *InvestmentHandler) GetInvestment(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	investment, err := h.InvestmentService.GetInvestment(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	investment, ok := h.customerInvestment(w, r)
+	if !ok {
 		return
 	}
 
 	// Enrich the response with fund information
-	fund, err := h.FundService.GetFund(investment.FundID)
+	fund, err := h.FundService.GetFund(r.Context(), investment.FundID)
 	var fundName string
 	if err != nil {
 		fundName = "Unknown Fund"
@@ -110,56 +134,232 @@ func (h *InvestmentHandler) GetInvestment(w http.ResponseWriter, r *http.Request
 	}
 
 	// Create a more detailed response
-	response := struct {
-		ID         string  `json:"id"`
-		CustomerID string  `json:"customer_id"`
-		FundID     string  `json:"fund_id"`
-		FundName   string  `json:"fund_name"`
-		Amount     float64 `json:"amount"`
-		Status     string  `json:"status"`
-		CreatedAt  string  `json:"created_at"`
-		UpdatedAt  string  `json:"updated_at"`
-	}{
+	response := InvestmentDetailResponse{
 		ID:         investment.ID,
 		CustomerID: investment.CustomerID,
 		FundID:     investment.FundID,
 		FundName:   fundName,
 		Amount:     float64(investment.Amount) / 100.0,
+		Type:       string(investment.Type),
+		SwitchID:   investment.SwitchID,
+		UnitPrice:  float64(investment.UnitPrice) / 100.0,
+		Units:      float64(investment.Units) / 1000.0,
 		Status:     string(investment.Status),
+		Version:    investment.Version,
 		CreatedAt:  investment.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:  investment.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
 
+	setETag(w, investment.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// InvestmentEventResponse is one entry in an investment's history
+type InvestmentEventResponse struct {
+	Sequence         int64   `json:"sequence"`
+	Type             string  `json:"type"`
+	CustomerID       string  `json:"customer_id,omitempty"`
+	FundID           string  `json:"fund_id,omitempty"`
+	Amount           float64 `json:"amount,omitempty"`
+	InvestmentType   string  `json:"investment_type,omitempty"`
+	SwitchID         string  `json:"switch_id,omitempty"`
+	RiskAcknowledged bool    `json:"risk_acknowledged,omitempty"`
+	UnitPrice        float64 `json:"unit_price,omitempty"`
+	Units            float64 `json:"units,omitempty"`
+	OccurredAt       string  `json:"occurred_at"`
+}
+
+// GetInvestmentEvents handles GET /investments/{id}/events
+func (h *InvestmentHandler) GetInvestmentEvents(w http.ResponseWriter, r *http.Request) {
+	investment, ok := h.customerInvestment(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.InvestmentService.GetInvestmentEvents(r.Context(), investment.ID)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	response := make([]InvestmentEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, InvestmentEventResponse{
+			Sequence:         event.Sequence,
+			Type:             string(event.Type),
+			CustomerID:       event.CustomerID,
+			FundID:           event.FundID,
+			Amount:           float64(event.Amount) / 100.0,
+			InvestmentType:   string(event.InvestmentType),
+			SwitchID:         event.SwitchID,
+			RiskAcknowledged: event.RiskAcknowledged,
+			UnitPrice:        float64(event.UnitPrice) / 100.0,
+			Units:            float64(event.Units) / 1000.0,
+			OccurredAt:       event.OccurredAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// PriceInvestmentRequest is the request for pricing an investment
+type PriceInvestmentRequest struct {
+	UnitPrice string `json:"unit_price"` // Price per unit as string (e.g., "1.25")
+}
+
+// PriceInvestment handles POST /investments/{id}/price, which requires an If-Match header
+func (h *InvestmentHandler) PriceInvestment(w http.ResponseWriter, r *http.Request) {
+	var req PriceInvestmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Convert unit price to pence (int64)
+	pricePence, err := domain.ParsePence(req.UnitPrice)
+	if err != nil || pricePence <= 0 {
+		http.Error(w, "Invalid unit price format", http.StatusBadRequest)
+		return
+	}
+
+	h.changeInvestment(w, r, func(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+		return h.InvestmentService.PriceInvestment(ctx, id, pricePence, expectedVersion)
+	})
+}
+
+// ProcessInvestment handles POST /investments/{id}/process, which requires an If-Match header
+func (h *InvestmentHandler) ProcessInvestment(w http.ResponseWriter, r *http.Request) {
+	h.changeInvestment(w, r, h.InvestmentService.ProcessInvestment)
+}
+
+// WithdrawInvestment handles POST /investments/{id}/withdraw, which requires an If-Match header
+func (h *InvestmentHandler) WithdrawInvestment(w http.ResponseWriter, r *http.Request) {
+	h.changeInvestment(w, r, h.InvestmentService.WithdrawInvestment)
+}
+
+// CancelInvestment handles POST /investments/{id}/cancel, which requires an If-Match header
+func (h *InvestmentHandler) CancelInvestment(w http.ResponseWriter, r *http.Request) {
+	h.changeInvestment(w, r, h.InvestmentService.CancelInvestment)
+}
+
+// changeInvestment applies a change to the investment in the path.
+// Changes require an If-Match header.
+func (h *InvestmentHandler) changeInvestment(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error)) {
+	investment, ok := h.customerInvestment(w, r)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	investment, err := change(r.Context(), investment.ID, version)
+	if err != nil {
+		status := http.StatusNotFound
+		switch {
+		case errors.Is(err, domain.ErrConflict):
+			status = http.StatusPreconditionFailed
+		case errors.Is(err, domain.ErrInvestmentNotCancellable),
+			errors.Is(err, domain.ErrInvalidInvestmentStatusChange),
+			errors.Is(err, domain.ErrInsufficientHoldings),
+			errors.Is(err, domain.ErrInsufficientCash):
+			status = http.StatusConflict
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	setETag(w, investment.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(investment)
+}
+
+// customerInvestment loads the investment in the path, writing a 404 if there is
+// no such investment or a 403 if it belongs to a customer the caller cannot act for
+func (h *InvestmentHandler) customerInvestment(w http.ResponseWriter, r *http.Request) (*domain.Investment, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	investment, err := h.InvestmentService.GetInvestment(r.Context(), id)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return nil, false
+	}
+	if !authorizeCustomer(w, r, investment.CustomerID) {
+		return nil, false
+	}
+
+	return investment, true
+}
+
+// EnrichedInvestment is an investment in a customer's list, with its fund's name
+type EnrichedInvestment struct {
+	ID         string  `json:"id"`
+	CustomerID string  `json:"customer_id"`
+	FundID     string  `json:"fund_id"`
+	FundName   string  `json:"fund_name"`
+	Amount     float64 `json:"amount"`
+	Type       string  `json:"type"`
+	SwitchID   string  `json:"switch_id,omitempty"`
+	Status     string  `json:"status"`
+	CreatedAt  string  `json:"created_at"`
+}
+
 // GetCustomerInvestments handles GET /customers/{id}/investments
+//
+// Investments are returned oldest first, one page at a time. Supported query parameters:
+//   - status: comma separated statuses to filter by
+//   - fund_id: only investments in this fund
+//   - from, to: creation date range as YYYY-MM-DD or RFC 3339, from inclusive and to exclusive
+//   - limit: page size
+//   - cursor: the cursor of the next page
+//
+// When there is another page its cursor is returned in X-Next-Cursor and a Link header with rel="next".
 func (h *InvestmentHandler) GetCustomerInvestments(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	customerID := vars["id"]
+	params := r.URL.Query()
 
-	investments, err := h.InvestmentService.GetCustomerInvestments(customerID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	query := domain.InvestmentQuery{
+		CustomerID: vars["id"],
+		FundID:     params.Get("fund_id"),
+		Cursor:     params.Get("cursor"),
+	}
+	for _, status := range splitQueryList(params.Get("status")) {
+		query.Statuses = append(query.Statuses, domain.InvestmentStatus(status))
+	}
+
+	var err error
+	if query.CreatedFrom, err = parseQueryTime(params.Get("from")); err != nil {
+		http.Error(w, "Invalid from date", http.StatusBadRequest)
 		return
 	}
+	if query.CreatedTo, err = parseQueryTime(params.Get("to")); err != nil {
+		http.Error(w, "Invalid to date", http.StatusBadRequest)
+		return
+	}
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Enrich the responses with fund information
-	type EnrichedInvestment struct {
-		ID         string  `json:"id"`
-		CustomerID string  `json:"customer_id"`
-		FundID     string  `json:"fund_id"`
-		FundName   string  `json:"fund_name"`
-		Amount     float64 `json:"amount"`
-		Status     string  `json:"status"`
-		CreatedAt  string  `json:"created_at"`
+	page, err := h.InvestmentService.ListCustomerInvestments(r.Context(), query)
+	if err != nil {
+		serviceError(w, err, http.StatusBadRequest)
+		return
 	}
+	investments := page.Investments
 
+	// Enrich the responses with fund information
 	enrichedInvestments := make([]EnrichedInvestment, 0, len(investments))
 	for _, investment := range investments {
 		// Get fund name from FundService
-		fund, err := h.FundService.GetFund(investment.FundID)
+		fund, err := h.FundService.GetFund(r.Context(), investment.FundID)
 		fundName := "Unknown Fund"
 		if err == nil {
 			fundName = fund.Name
@@ -171,12 +371,53 @@ func (h *InvestmentHandler) GetCustomerInvestments(w http.ResponseWriter, r *htt
 			FundID:     investment.FundID,
 			FundName:   fundName,
 			Amount:     float64(investment.Amount) / 100.0,
+			Type:       string(investment.Type),
+			SwitchID:   investment.SwitchID,
 			Status:     string(investment.Status),
 			CreatedAt:  investment.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
 		enrichedInvestments = append(enrichedInvestments, enriched)
 	}
 
+	if page.NextCursor != "" {
+		next := *r.URL
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(enrichedInvestments)
 }
+
+// parseQueryTime parses a date (YYYY-MM-DD) or RFC 3339 timestamp, returning the zero time when empty
+func parseQueryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// riskWarning describes the risk the customer accepted, if any
+func riskWarning(acknowledged bool) string {
+	if !acknowledged {
+		return ""
+	}
+	return "This fund is riskier than your risk profile suggests. You have confirmed you accept this risk."
+}
+
+// statusForCreateError maps errors from creating an investment, switch or plan to an HTTP status
+func statusForCreateError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrRiskNotAcknowledged):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrFundNotOpen), errors.Is(err, domain.ErrFundSuspended):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
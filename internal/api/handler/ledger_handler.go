@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+	"time"
+)
+
+// LedgerHandler handles HTTP requests related to the cash and unit ledger
+type LedgerHandler struct {
+	LedgerService domain.LedgerService
+}
+
+// NewLedgerHandler creates a new ledger handler
+func NewLedgerHandler(ls domain.LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		LedgerService: ls,
+	}
+}
+
+// BalancesResponse is a customer's cash and units at a point in time
+type BalancesResponse struct {
+	CustomerID string             `json:"customer_id"`
+	Cash       float64            `json:"cash"`
+	Units      map[string]float64 `json:"units"`
+	At         string             `json:"at"`
+}
+
+// GetCustomerBalances handles GET /customers/{id}/balances, optionally as at a date or RFC 3339 time given in ?at=
+func (h *LedgerHandler) GetCustomerBalances(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	at, err := parseQueryTime(r.URL.Query().Get("at"))
+	if err != nil {
+		http.Error(w, "Invalid at", http.StatusBadRequest)
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	balances, err := h.LedgerService.GetCustomerBalances(r.Context(), customerID, at)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	response := BalancesResponse{
+		CustomerID: balances.CustomerID,
+		Cash:       float64(balances.Cash) / 100.0,
+		Units:      make(map[string]float64),
+		At:         balances.At.Format("2006-01-02 15:04:05"),
+	}
+	for fundID, units := range balances.Units {
+		if units != 0 {
+			response.Units[fundID] = float64(units) / 1000.0
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetCustomerJournals handles GET /customers/{id}/journals
+func (h *LedgerHandler) GetCustomerJournals(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	journals, err := h.LedgerService.GetCustomerJournals(r.Context(), customerID)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(journals)
+}
+
+// LedgerAmountRequest is the request for posting a fee or dividend
+type LedgerAmountRequest struct {
+	FundID string `json:"fund_id,omitempty"` // Paying fund, for dividends
+	Amount string `json:"amount"`            // Amount as string (e.g., "12.50")
+}
+
+// RecordFee handles POST /admin/customers/{id}/fees
+func (h *LedgerHandler) RecordFee(w http.ResponseWriter, r *http.Request) {
+	h.record(w, r, func(customerID string, req LedgerAmountRequest, amount int64) (*domain.Journal, error) {
+		return h.LedgerService.RecordFee(r.Context(), customerID, amount)
+	})
+}
+
+// RecordDividend handles POST /admin/customers/{id}/dividends
+func (h *LedgerHandler) RecordDividend(w http.ResponseWriter, r *http.Request) {
+	h.record(w, r, func(customerID string, req LedgerAmountRequest, amount int64) (*domain.Journal, error) {
+		return h.LedgerService.RecordDividend(r.Context(), customerID, req.FundID, amount)
+	})
+}
+
+// record decodes a fee or dividend request and posts it for the customer in the path
+func (h *LedgerHandler) record(w http.ResponseWriter, r *http.Request, post func(customerID string, req LedgerAmountRequest, amount int64) (*domain.Journal, error)) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	var req LedgerAmountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Convert amount to pence (int64)
+	amountPence, err := domain.ParsePence(req.Amount)
+	if err != nil {
+		http.Error(w, "Invalid amount format", http.StatusBadRequest)
+		return
+	}
+
+	journal, err := post(customerID, req, amountPence)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrInsufficientCash) {
+			status = http.StatusConflict
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(journal)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"net/http"
+)
+
+// OpenAPIHandler serves the API's OpenAPI specification
+type OpenAPIHandler struct {
+	Spec *openapi.Spec
+}
+
+// NewOpenAPIHandler creates a new OpenAPI handler
+func NewOpenAPIHandler(s *openapi.Spec) *OpenAPIHandler {
+	return &OpenAPIHandler{
+		Spec: s,
+	}
+}
+
+// GetSpec handles GET /openapi.json
+func (h *OpenAPIHandler) GetSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.Spec.Document())
+}
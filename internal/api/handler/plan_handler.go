@@ -0,0 +1,277 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+var (
+	errInvalidPlanAmount        = errors.New("Invalid amount format")
+	errPlanAmountAndAllocations = errors.New("Give either a fund and amount, or allocations")
+)
+
+// PlanHandler handles HTTP requests related to regular contribution plans
+type PlanHandler struct {
+	PlanService domain.PlanService
+}
+
+// NewPlanHandler creates a new plan handler
+func NewPlanHandler(ps domain.PlanService) *PlanHandler {
+	return &PlanHandler{
+		PlanService: ps,
+	}
+}
+
+// PlanAllocationRequest is the part of a plan's monthly contribution invested in one fund
+type PlanAllocationRequest struct {
+	FundID string `json:"fund_id"`
+	Amount string `json:"amount"` // Amount as string (e.g., "150.00")
+}
+
+// PlanRequest is the request for creating or updating a plan. A plan investing
+// in a single fund can give its fund and amount instead of allocations.
+type PlanRequest struct {
+	FundID      string                  `json:"fund_id"`
+	Amount      string                  `json:"amount"` // Amount as string (e.g., "250.00")
+	Allocations []PlanAllocationRequest `json:"allocations"`
+	DayOfMonth  int                     `json:"day_of_month"`
+	Status      string                  `json:"status,omitempty"`
+	// RiskAcknowledged confirms the customer accepts a fund riskier than their risk tolerance
+	RiskAcknowledged bool `json:"risk_acknowledged"`
+}
+
+// PlanAllocationResponse is the part of a plan's monthly contribution invested in one fund
+type PlanAllocationResponse struct {
+	FundID string  `json:"fund_id"`
+	Amount float64 `json:"amount"`
+}
+
+// PlanResponse is the response describing a plan. Amount is the total of the allocations.
+type PlanResponse struct {
+	ID                string                   `json:"id"`
+	CustomerID        string                   `json:"customer_id"`
+	Allocations       []PlanAllocationResponse `json:"allocations"`
+	Amount            float64                  `json:"amount"`
+	DayOfMonth        int                      `json:"day_of_month"`
+	RiskWarning       string                   `json:"risk_warning,omitempty"`
+	Status            string                   `json:"status"`
+	PauseReason       string                   `json:"pause_reason,omitempty"`
+	NextRunAt         string                   `json:"next_run_at"`
+	LastRunAt         string                   `json:"last_run_at,omitempty"`
+	LastInvestmentIDs []string                 `json:"last_investment_ids,omitempty"`
+	LastError         string                   `json:"last_error,omitempty"`
+	MissedRuns        int                      `json:"missed_runs,omitempty"`
+	Version           int64                    `json:"version"`
+	CreatedAt         string                   `json:"created_at"`
+}
+
+// ListPlans handles GET /customers/{id}/plans
+func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	plans, err := h.PlanService.GetCustomerPlans(r.Context(), customerID)
+	if err != nil {
+		serviceError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]PlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		responses = append(responses, newPlanResponse(plan))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// CreatePlan handles POST /customers/{id}/plans
+func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allocations, err := planAllocations(req, req.FundID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.PlanService.CreatePlan(r.Context(), customerID, allocations, req.DayOfMonth, req.RiskAcknowledged)
+	if err != nil {
+		serviceError(w, err, statusForCreateError(err))
+		return
+	}
+
+	setETag(w, plan.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newPlanResponse(plan))
+}
+
+// GetPlan handles GET /customers/{id}/plans/{planID}
+func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	plan, ok := h.customerPlan(w, r)
+	if !ok {
+		return
+	}
+
+	setETag(w, plan.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPlanResponse(plan))
+}
+
+// UpdatePlan handles PUT /customers/{id}/plans/{planID}. It requires an If-Match header.
+func (h *PlanHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	plan, ok := h.customerPlan(w, r)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An amount alone changes the amount of a plan investing in a single fund
+	fundID := req.FundID
+	if fundID == "" && len(plan.Allocations) == 1 {
+		fundID = plan.Allocations[0].FundID
+	}
+	if fundID == "" && len(req.Allocations) == 0 {
+		http.Error(w, "Give the amount for each of the plan's funds in allocations", http.StatusBadRequest)
+		return
+	}
+	allocations, err := planAllocations(req, fundID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := domain.PlanStatus(req.Status)
+	if status == "" {
+		status = plan.Status
+	}
+
+	plan, err = h.PlanService.UpdatePlan(r.Context(), plan.ID, allocations, req.DayOfMonth, status, version)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	setETag(w, plan.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPlanResponse(plan))
+}
+
+// DeletePlan handles DELETE /customers/{id}/plans/{planID}. It requires an If-Match header.
+func (h *PlanHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	plan, ok := h.customerPlan(w, r)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.PlanService.CancelPlan(r.Context(), plan.ID, version); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrConflict) {
+			status = http.StatusPreconditionFailed
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// customerPlan loads the plan in the path and checks it belongs to the customer in the path
+func (h *PlanHandler) customerPlan(w http.ResponseWriter, r *http.Request) (*domain.Plan, bool) {
+	vars := mux.Vars(r)
+
+	plan, err := h.PlanService.GetPlan(r.Context(), vars["planID"])
+	if errors.Is(err, domain.ErrForbidden) {
+		serviceError(w, err, http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil || plan.CustomerID != vars["id"] {
+		http.Error(w, "plan not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return plan, true
+}
+
+// planAllocations converts the request's allocations to pence. Without
+// allocations, its amount is invested in fundID.
+func planAllocations(req PlanRequest, fundID string) ([]domain.PlanAllocation, error) {
+	if len(req.Allocations) == 0 {
+		amountPence, err := domain.ParsePence(req.Amount)
+		if err != nil {
+			return nil, errInvalidPlanAmount
+		}
+		return []domain.PlanAllocation{{FundID: fundID, Amount: amountPence}}, nil
+	}
+	if req.FundID != "" || req.Amount != "" {
+		return nil, errPlanAmountAndAllocations
+	}
+
+	allocations := make([]domain.PlanAllocation, 0, len(req.Allocations))
+	for _, allocation := range req.Allocations {
+		amountPence, err := domain.ParsePence(allocation.Amount)
+		if err != nil {
+			return nil, errInvalidPlanAmount
+		}
+		allocations = append(allocations, domain.PlanAllocation{FundID: allocation.FundID, Amount: amountPence})
+	}
+	return allocations, nil
+}
+
+func newPlanResponse(plan *domain.Plan) PlanResponse {
+	allocations := make([]PlanAllocationResponse, 0, len(plan.Allocations))
+	for _, allocation := range plan.Allocations {
+		allocations = append(allocations, PlanAllocationResponse{FundID: allocation.FundID, Amount: float64(allocation.Amount) / 100.0})
+	}
+
+	response := PlanResponse{
+		ID:                plan.ID,
+		CustomerID:        plan.CustomerID,
+		Allocations:       allocations,
+		Amount:            float64(plan.Amount) / 100.0,
+		DayOfMonth:        plan.DayOfMonth,
+		RiskWarning:       riskWarning(plan.RiskAcknowledged),
+		Status:            string(plan.Status),
+		PauseReason:       plan.PauseReason,
+		NextRunAt:         plan.NextRunAt.Format("2006-01-02"),
+		LastInvestmentIDs: plan.LastInvestmentIDs,
+		LastError:         plan.LastError,
+		MissedRuns:        plan.MissedRuns,
+		Version:           plan.Version,
+		CreatedAt:         plan.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if plan.LastRunAt != nil {
+		response.LastRunAt = plan.LastRunAt.Format("2006-01-02 15:04:05")
+	}
+	return response
+}
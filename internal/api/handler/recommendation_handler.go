@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+	"strconv"
+)
+
+// RecommendationHandler handles HTTP requests related to fund recommendations
+type RecommendationHandler struct {
+	RecommendationService domain.RecommendationService
+}
+
+// NewRecommendationHandler creates a new recommendation handler
+func NewRecommendationHandler(rs domain.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{
+		RecommendationService: rs,
+	}
+}
+
+// GetRecommendations handles GET /customers/{id}/recommendations?horizon_years=10
+func (h *RecommendationHandler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	customerID := vars["id"]
+
+	horizonYears := 0
+	if v := r.URL.Query().Get("horizon_years"); v != "" {
+		var err error
+		horizonYears, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid horizon_years", http.StatusBadRequest)
+			return
+		}
+	}
+
+	recommendations, err := h.RecommendationService.GetRecommendations(r.Context(), customerID, horizonYears)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrRiskProfileRequired) {
+			status = http.StatusUnprocessableEntity
+		}
+		serviceError(w, err, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recommendations)
+}
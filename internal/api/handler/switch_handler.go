@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/http"
+)
+
+// SwitchHandler handles HTTP requests related to fund switches
+type SwitchHandler struct {
+	SwitchService domain.SwitchService
+}
+
+// NewSwitchHandler creates a new switch handler
+func NewSwitchHandler(ss domain.SwitchService) *SwitchHandler {
+	return &SwitchHandler{
+		SwitchService: ss,
+	}
+}
+
+// CreateSwitchRequest is the request for switching between funds
+type CreateSwitchRequest struct {
+	CustomerID string `json:"customer_id"`
+	FromFundID string `json:"from_fund_id"`
+	ToFundID   string `json:"to_fund_id"`
+	Amount     string `json:"amount"` // Amount as string (e.g., "5000.00")
+	// RiskAcknowledged confirms the customer accepts a fund riskier than their risk tolerance
+	RiskAcknowledged bool `json:"risk_acknowledged"`
+}
+
+// SwitchResponse is the response describing a fund switch
+type SwitchResponse struct {
+	ID               string  `json:"id"`
+	CustomerID       string  `json:"customer_id"`
+	FromFundID       string  `json:"from_fund_id"`
+	ToFundID         string  `json:"to_fund_id"`
+	Amount           float64 `json:"amount"`
+	SellInvestmentID string  `json:"sell_investment_id"`
+	BuyInvestmentID  string  `json:"buy_investment_id,omitempty"`
+	RiskWarning      string  `json:"risk_warning,omitempty"`
+	Status           string  `json:"status"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+// CreateSwitch handles POST /switches
+func (h *SwitchHandler) CreateSwitch(w http.ResponseWriter, r *http.Request) {
+	var req CreateSwitchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeCustomer(w, r, req.CustomerID) {
+		return
+	}
+
+	// Convert amount to pence (int64)
+	amountPence, err := domain.ParsePence(req.Amount)
+	if err != nil {
+		http.Error(w, "Invalid amount format", http.StatusBadRequest)
+		return
+	}
+
+	sw, err := h.SwitchService.SwitchFunds(r.Context(), req.CustomerID, req.FromFundID, req.ToFundID, amountPence, req.RiskAcknowledged)
+	if err != nil {
+		serviceError(w, err, statusForCreateError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newSwitchResponse(sw))
+}
+
+// GetSwitch handles GET /switches/{id}
+func (h *SwitchHandler) GetSwitch(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	sw, err := h.SwitchService.GetSwitch(r.Context(), id)
+	if err != nil {
+		serviceError(w, err, http.StatusNotFound)
+		return
+	}
+	if !authorizeCustomer(w, r, sw.CustomerID) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSwitchResponse(sw))
+}
+
+func newSwitchResponse(sw *domain.Switch) SwitchResponse {
+	return SwitchResponse{
+		ID:               sw.ID,
+		CustomerID:       sw.CustomerID,
+		FromFundID:       sw.FromFundID,
+		ToFundID:         sw.ToFundID,
+		Amount:           float64(sw.Amount) / 100.0,
+		SellInvestmentID: sw.SellInvestmentID,
+		BuyInvestmentID:  sw.BuyInvestmentID,
+		RiskWarning:      riskWarning(sw.RiskAcknowledged),
+		Status:           string(sw.Status),
+		CreatedAt:        sw.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
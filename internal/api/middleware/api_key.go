@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// signatureWindow is how far a signed request's timestamp may be from ours
+	signatureWindow = 5 * time.Minute
+	// maxSignedBody is the largest request body that will be read to check a signature
+	maxSignedBody = 1 << 20
+)
+
+// APIKeyAuthenticator authenticates partners' server-to-server requests made
+// with an X-API-Key header. Requests without one are left for the bearer token
+// authenticator that follows it.
+type APIKeyAuthenticator struct {
+	Keys   domain.APIKeyService
+	nonces *nonceCache
+}
+
+// NewAPIKeyAuthenticator creates a new API key authenticator
+func NewAPIKeyAuthenticator(keys domain.APIKeyService) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		Keys:   keys,
+		nonces: newNonceCache(),
+	}
+}
+
+// Middleware rejects requests with an invalid API key or signature with 401 and
+// makes the rest as the key's partner, limited to its scopes and linked customers.
+// Requests are checked for a signature when they carry one or their key requires it.
+func (a *APIKeyAuthenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented := r.Header.Get(auth.HeaderAPIKey)
+		if presented == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := a.Keys.AuthenticateAPIKey(r.Context(), presented)
+		if err == nil && (key.SignatureRequired || r.Header.Get(auth.HeaderSignature) != "") {
+			err = a.verifySignature(r, key, time.Now())
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), partnerPrincipal(key))))
+	})
+}
+
+// partnerPrincipal is the caller a request made with the key is made as
+func partnerPrincipal(key *domain.APIKey) *auth.Principal {
+	principal := &auth.Principal{
+		Subject:     "partner:" + key.PartnerID + ":" + key.ID,
+		CustomerIDs: key.CustomerIDs,
+	}
+	for _, scope := range key.Scopes {
+		principal.Permissions = append(principal.Permissions, auth.Permission(scope))
+	}
+	return principal
+}
+
+// verifySignature checks the request was signed with the key's signing secret
+// within signatureWindow of now, with a nonce that has not been seen before.
+// The body is read to check it and replaced so handlers can still read it.
+func (a *APIKeyAuthenticator) verifySignature(r *http.Request, key *domain.APIKey, now time.Time) error {
+	timestamp := r.Header.Get(auth.HeaderTimestamp)
+	nonce := r.Header.Get(auth.HeaderNonce)
+	signature := r.Header.Get(auth.HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return errors.New("request must be signed")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid request timestamp")
+	}
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-signatureWindow)) || signedAt.After(now.Add(signatureWindow)) {
+		return errors.New("request timestamp is outside the allowed window")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+	if err != nil {
+		return errors.New("reading request body")
+	}
+	if len(body) > maxSignedBody {
+		return errors.New("request body is too large to sign")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	signingString := auth.SigningString(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !auth.ValidSignature(key.SigningSecret, signingString, signature) {
+		return errors.New("invalid request signature")
+	}
+
+	// Only nonces with a valid signature are remembered, so nobody else can use up a partner's nonces
+	if !a.nonces.add(key.ID+":"+nonce, signedAt.Add(signatureWindow), now) {
+		return errors.New("request has already been made")
+	}
+	return nil
+}
+
+// nonceCache remembers the nonces of signed requests until their timestamp
+// falls out of the window, after which a replay would be refused anyway
+type nonceCache struct {
+	mutex     sync.Mutex
+	expiries  map[string]time.Time
+	lastPrune time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{expiries: make(map[string]time.Time)}
+}
+
+// add records a nonce until expiry, returning false if it was already recorded
+func (c *nonceCache) add(nonce string, expiry, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.lastPrune) > signatureWindow {
+		for n, e := range c.expiries {
+			if now.After(e) {
+				delete(c.expiries, n)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if e, ok := c.expiries[nonce]; ok && !now.After(e) {
+		return false
+	}
+	c.expiries[nonce] = expiry
+	return true
+}
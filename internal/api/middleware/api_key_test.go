@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPartnerRouter serves routes behind the API key and bearer token
+// authenticators, echoing the body of requests that get through
+func newPartnerRouter(t *testing.T, signatureRequired bool) (*mux.Router, *domain.IssuedAPIKey) {
+	apiKeyService := service.NewAPIKeyService(repository.NewInMemoryAPIKeyRepository(), repository.NewInMemoryCustomerRepository())
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "admin-1", Roles: []auth.Role{auth.RoleAdmin}})
+	issued, err := apiKeyService.IssueAPIKey(admin, "acme", domain.APIKeySettings{
+		Name:              "Payroll integration",
+		Scopes:            []string{string(auth.PermInvestmentsCreate)},
+		CustomerIDs:       []string{"customer-1"},
+		SignatureRequired: signatureRequired,
+	})
+	require.NoError(t, err)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+	r := mux.NewRouter()
+	r.Use(middleware.NewAPIKeyAuthenticator(apiKeyService).Middleware)
+	r.Use(middleware.NewAuthenticator(newTestKeys(t).set, "", "").Middleware)
+	r.Handle("/investments", middleware.RequirePermission(auth.PermInvestmentsCreate)(echo))
+	r.Handle("/funds", middleware.RequirePermission(auth.PermFundsRead)(echo))
+	r.Handle("/customers/{id}/investments", middleware.RequirePermission(auth.PermInvestmentsCreate)(middleware.RequireCustomerAccess(echo)))
+	return r, issued
+}
+
+// signedRequest is a request made with the key, signed at signedAt with nonce
+func signedRequest(key *domain.IssuedAPIKey, path, body, nonce string, signedAt time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req.Header.Set(auth.HeaderAPIKey, key.Key)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderNonce, nonce)
+	req.Header.Set(auth.HeaderSignature, auth.Sign(key.SigningSecret, auth.SigningString(http.MethodPost, path, timestamp, nonce, []byte(body))))
+	return req
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	router, key := newPartnerRouter(t, false)
+	body := `{"customer_id":"customer-1","fund_id":"fund-1","amount":10000}`
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	unsigned := func(presented, path string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(auth.HeaderAPIKey, presented)
+		return req
+	}
+
+	t.Run("Keys grant their scopes for their linked customers", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(unsigned(key.Key, "/investments")).Code)
+		assert.Equal(t, http.StatusOK, serve(unsigned(key.Key, "/customers/customer-1/investments")).Code)
+		assert.Equal(t, http.StatusForbidden, serve(unsigned(key.Key, "/customers/customer-2/investments")).Code)
+		assert.Equal(t, http.StatusForbidden, serve(unsigned(key.Key, "/funds")).Code)
+	})
+
+	t.Run("Invalid keys are refused", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(unsigned(key.ID+".wrong-secret", "/investments")).Code)
+
+		// Requests without a key are left to the bearer token authenticator
+		assert.Equal(t, http.StatusUnauthorized, serve(httptest.NewRequest(http.MethodPost, "/investments", nil)).Code)
+	})
+
+	t.Run("Signed requests are checked", func(t *testing.T) {
+		rec := serve(signedRequest(key, "/investments", body, "nonce-1", time.Now()))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, body, rec.Body.String(), "the handler still reads the signed body")
+
+		tampered := signedRequest(key, "/investments", body, "nonce-2", time.Now())
+		tampered.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "10000", "99999", 1)))
+		assert.Equal(t, http.StatusUnauthorized, serve(tampered).Code)
+
+		otherPath := signedRequest(key, "/investments", body, "nonce-3", time.Now())
+		otherPath.URL.Path = "/customers/customer-1/investments"
+		otherPath.RequestURI = otherPath.URL.RequestURI()
+		assert.Equal(t, http.StatusUnauthorized, serve(otherPath).Code)
+
+		stale := signedRequest(key, "/investments", body, "nonce-4", time.Now().Add(-10*time.Minute))
+		assert.Equal(t, http.StatusUnauthorized, serve(stale).Code)
+	})
+
+	t.Run("Signed requests cannot be replayed", func(t *testing.T) {
+		signedAt := time.Now()
+		assert.Equal(t, http.StatusOK, serve(signedRequest(key, "/investments", body, "nonce-5", signedAt)).Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(signedRequest(key, "/investments", body, "nonce-5", signedAt)).Code)
+	})
+}
+
+func TestAPIKeyAuthenticatorRequiresSignature(t *testing.T) {
+	router, key := newPartnerRouter(t, true)
+
+	req := httptest.NewRequest(http.MethodPost, "/investments", strings.NewReader("{}"))
+	req.Header.Set(auth.HeaderAPIKey, key.Key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, signedRequest(key, "/investments", "{}", "nonce-1", time.Now()))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
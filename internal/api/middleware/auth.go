@@ -0,0 +1,156 @@
+// Package middleware holds the gorilla/mux middleware wrapped around the API handlers.
+package middleware
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far token times may be off from ours
+const clockSkew = 30 * time.Second
+
+// claims are the token claims we read. Tokens without roles are customer tokens.
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// Authenticator validates RS256 and ES256 bearer tokens signed by a key in its key set
+type Authenticator struct {
+	Keys *auth.KeySet
+	// Issuer and Audience are checked against the token's iss and aud claims when set
+	Issuer   string
+	Audience string
+}
+
+// NewAuthenticator creates a new authenticator
+func NewAuthenticator(keys *auth.KeySet, issuer, audience string) *Authenticator {
+	return &Authenticator{
+		Keys:     keys,
+		Issuer:   issuer,
+		Audience: audience,
+	}
+}
+
+// Middleware rejects requests without a valid bearer token with 401 and adds the
+// token's subject to the context of the rest. Requests already authenticated
+// with an API key pass straight through.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// authenticate validates the request's bearer token
+func (a *Authenticator) authenticate(r *http.Request) (*auth.Principal, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+	}
+	if a.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, a.key, options...)
+	if err != nil {
+		return nil, errors.New("invalid bearer token")
+	}
+	if c.Subject == "" {
+		return nil, errors.New("bearer token has no subject")
+	}
+
+	principal := &auth.Principal{Subject: c.Subject}
+	for _, name := range c.Roles {
+		// Roles we do not know grant nothing
+		if role, ok := auth.ParseRole(name); ok {
+			principal.Roles = append(principal.Roles, role)
+		}
+	}
+	if len(c.Roles) == 0 {
+		principal.Roles = []auth.Role{auth.RoleCustomer}
+	}
+
+	return principal, nil
+}
+
+// key finds the key a token was signed with from its kid header
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.Keys.Key(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	return key, nil
+}
+
+// DevelopmentPrincipal is the caller every request is made as when authentication is disabled
+var DevelopmentPrincipal = &auth.Principal{Subject: "local-development", Roles: []auth.Role{auth.RoleAdmin}}
+
+// AuthDisabled stands in for the authenticator in local development, making
+// every request not already authenticated with an API key as DevelopmentPrincipal
+func AuthDisabled(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), DevelopmentPrincipal)))
+	})
+}
+
+// RequirePermission rejects with 403 requests from callers whose roles or API
+// key scopes do not grant the permission
+func RequirePermission(permission auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.Allowed(r.Context(), permission) {
+				http.Error(w, "permission "+string(permission)+" is required", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireCustomerAccess rejects with 403 requests for a customer ({id} in the
+// path) other than the authenticated caller
+func RequireCustomerAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auth.CanAccessCustomer(r.Context(), mux.Vars(r)["id"]) {
+			http.Error(w, "access to this customer is forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package middleware_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	rsa   *rsa.PrivateKey
+	ec    *ecdsa.PrivateKey
+	other *rsa.PrivateKey
+	set   *auth.KeySet
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa-1", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec-1", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	set, err := auth.ParseKeySet(jwks)
+	require.NoError(t, err)
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, other: otherKey, set: set}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://issuer.example",
+		Audience:  jwt.ClaimStrings{"isa-api"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+// newRouter serves a customer-scoped route behind the authenticator
+func newRouter(keys *testKeys) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(middleware.NewAuthenticator(keys.set, "https://issuer.example", "isa-api").Middleware)
+	r.Handle("/customers/{id}", middleware.RequireCustomerAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.PrincipalFromContext(r.Context())
+		w.Write([]byte(principal.Subject))
+	})))
+	return r
+}
+
+func TestAuthenticator(t *testing.T) {
+	keys := newTestKeys(t)
+	router := newRouter(keys)
+
+	expired := validClaims("customer-1")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := validClaims("customer-1")
+	noExpiry.ExpiresAt = nil
+	wrongAudience := validClaims("customer-1")
+	wrongAudience.Audience = jwt.ClaimStrings{"another-api"}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"valid RS256 token", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, validClaims("customer-1")), http.StatusOK},
+		{"valid ES256 token", "Bearer " + sign(t, jwt.SigningMethodES256, "ec-1", keys.ec, validClaims("customer-1")), http.StatusOK},
+		{"missing token", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"expired token", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, expired), http.StatusUnauthorized},
+		{"token without expiry", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, noExpiry), http.StatusUnauthorized},
+		{"wrong audience", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, wrongAudience), http.StatusUnauthorized},
+		{"signed by an unknown key", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.other, validClaims("customer-1")), http.StatusUnauthorized},
+		{"unknown key ID", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-2", keys.rsa, validClaims("customer-1")), http.StatusUnauthorized},
+		{"HS256 token", "Bearer " + sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), validClaims("customer-1")), http.StatusUnauthorized},
+		{"other customer's token", "Bearer " + sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, validClaims("customer-2")), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/customers/customer-1", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get("WWW-Authenticate"))
+			}
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "customer-1", rec.Body.String())
+			}
+		})
+	}
+}
+
+// roleClaims are token claims with roles
+type roleClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+func TestRequirePermission(t *testing.T) {
+	keys := newTestKeys(t)
+	r := mux.NewRouter()
+	r.Use(middleware.NewAuthenticator(keys.set, "", "").Middleware)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r.Handle("/admin/funds/{id}/suspend", middleware.RequirePermission(auth.PermFundsManage)(ok))
+	r.Handle("/customers/{id}", middleware.RequirePermission(auth.PermCustomersRead)(middleware.RequireCustomerAccess(ok)))
+
+	tests := []struct {
+		name       string
+		path       string
+		roles      []string
+		wantStatus int
+	}{
+		{"token without roles is a customer", "/customers/customer-1", nil, http.StatusOK},
+		{"customer cannot manage funds", "/admin/funds/fund-1/suspend", []string{"customer"}, http.StatusForbidden},
+		{"operations can manage funds", "/admin/funds/fund-1/suspend", []string{"operations"}, http.StatusOK},
+		{"support can read any customer", "/customers/customer-2", []string{"support_agent"}, http.StatusOK},
+		{"customer cannot read another customer", "/customers/customer-2", []string{"customer"}, http.StatusForbidden},
+		{"unknown roles grant nothing", "/customers/customer-1", []string{"superuser"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := roleClaims{RegisteredClaims: validClaims("customer-1"), Roles: tt.roles}
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, claims))
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
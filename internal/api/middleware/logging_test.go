@@ -0,0 +1,64 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	var seen string
+	handler := middleware.RequestID(middleware.AccessLog(logging.New(&buf, slog.LevelInfo))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen, _ = logging.RequestIDFromContext(r.Context())
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"caller's ID is kept", "partner-req-42", true},
+		{"missing ID is assigned", "", false},
+		{"unsafe ID is replaced", "bad id\nwith newline", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/funds?email=jane@example.com", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.HeaderRequestID, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(middleware.HeaderRequestID)
+			assert.NotEmpty(t, id)
+			assert.Equal(t, id, seen)
+			if tt.keep {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.NotEqual(t, tt.header, id)
+			}
+
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, id, record["request_id"])
+			assert.Equal(t, "/funds", record["path"])
+			assert.Equal(t, float64(http.StatusTeapot), record["status"])
+			assert.NotContains(t, buf.String(), "jane@example.com")
+		})
+	}
+}
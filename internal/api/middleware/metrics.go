@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// unmatchedRoute is the route label of requests no route matched
+const unmatchedRoute = "unmatched"
+
+// Instrument records the count and latency of requests by route template,
+// method and status. It should be used on the router, so the matched route is known.
+func Instrument(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			m.ObserveRequest(routeTemplate(r), r.Method, rec.status, time.Since(start))
+		})
+	}
+}
+
+// routeTemplate is the template of the route that matched the request, such as
+// /api/v1/investments/{id}, which unlike the path has a bounded set of values
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unmatchedRoute
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return unmatchedRoute
+	}
+	return template
+}
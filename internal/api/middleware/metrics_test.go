@@ -0,0 +1,41 @@
+package middleware_test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	m := metrics.New()
+	r := mux.NewRouter()
+	r.Use(middleware.Instrument(m))
+	r.NotFoundHandler = middleware.Instrument(m)(http.NotFoundHandler())
+	r.HandleFunc("/investments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+	r.Handle("/metrics", m.Handler())
+
+	for _, path := range []string{"/investments/inv-1", "/investments/inv-2", "/investments/missing", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	exposition := string(body)
+
+	// Requests are labelled by route template, not path
+	assert.Contains(t, exposition, `http_requests_total{method="GET",route="/investments/{id}",status="200"} 2`)
+	assert.Contains(t, exposition, `http_requests_total{method="GET",route="/investments/{id}",status="404"} 1`)
+	assert.Contains(t, exposition, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, exposition, `http_request_duration_seconds_count{method="GET",route="/investments/{id}"} 3`)
+	assert.NotContains(t, exposition, "inv-1")
+}
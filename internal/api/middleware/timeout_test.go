@@ -0,0 +1,29 @@
+package middleware_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	var err error
+	handler := middleware.Timeout(time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+		<-r.Context().Done()
+		err = r.Context().Err()
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, hasDeadline)
+	assert.WithinDuration(t, start.Add(time.Millisecond), deadline, time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// propagator reads the W3C traceparent header, so a caller's trace carries on through our spans
+var propagator = propagation.TraceContext{}
+
+// Trace records a server span for each request, named after the method and
+// route template, such as "POST /api/v1/investments". A request carrying a
+// traceparent header continues the caller's trace. Like Instrument it should
+// be used on the router, so the matched route is known.
+func Trace(tp trace.TracerProvider) func(http.Handler) http.Handler {
+	tracer := tp.Tracer(tracing.InstrumentationName)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(r)
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+			if rec.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, fmt.Sprintf("%d %s", rec.status, http.StatusText(rec.status)))
+			}
+		})
+	}
+}
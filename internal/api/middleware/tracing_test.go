@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	r := mux.NewRouter()
+	r.Use(middleware.Trace(tp))
+	r.NotFoundHandler = middleware.Trace(tp)(http.NotFoundHandler())
+
+	var handlerSpan trace.SpanContext
+	r.HandleFunc("/investments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		if mux.Vars(r)["id"] == "broken" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}
+	})
+
+	// A caller's traceparent is carried on
+	req := httptest.NewRequest(http.MethodGet, "/investments/inv-1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/investments/broken", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	ok := spans[0]
+	assert.Equal(t, "GET /investments/{id}", ok.Name())
+	assert.Equal(t, trace.SpanKindServer, ok.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", ok.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", ok.Parent().SpanID().String())
+	assert.Contains(t, ok.Attributes(), attribute.String("http.route", "/investments/{id}"))
+	assert.Contains(t, ok.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+
+	failed := spans[1]
+	assert.Equal(t, handlerSpan.SpanID(), failed.SpanContext().SpanID())
+	assert.Contains(t, failed.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, failed.Status().Code)
+
+	assert.Equal(t, "GET unmatched", spans[2].Name())
+	assert.Contains(t, spans[2].Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+}
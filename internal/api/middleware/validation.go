@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"log/slog"
+	"net/http"
+)
+
+// ValidateRequest rejects requests whose parameters or body do not match the
+// API's OpenAPI specification with a 400 listing every problem, or a 413 when
+// the body is too large, before they reach the handlers. It should be used on
+// the router, so the matched route is known. Requests to routes the
+// specification does not describe are passed on.
+func ValidateRequest(spec *openapi.Spec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if op := spec.Operation(r.Method, routeTemplate(r)); op != nil {
+				if err := spec.ValidateRequest(op, r, mux.Vars(r)); err != nil {
+					status := http.StatusBadRequest
+					if errors.Is(err, openapi.ErrBodyTooLarge) {
+						status = http.StatusRequestEntityTooLarge
+					}
+					http.Error(w, err.Error(), status)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ValidateResponse logs a warning for each response that does not match the
+// API's OpenAPI specification, leaving the response itself unchanged. It buffers
+// every response body, so it is meant for development and test environments
+// rather than production. It should be used on the router, so the matched
+// route is known.
+func ValidateResponse(spec *openapi.Spec, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := spec.Operation(r.Method, routeTemplate(r))
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &bodyRecorder{statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+			next.ServeHTTP(rec, r)
+
+			if err := spec.ValidateResponse(op, rec.status, w.Header(), rec.body.Bytes()); err != nil {
+				logger.WarnContext(r.Context(), "response does not match the OpenAPI specification",
+					"method", r.Method, "route", routeTemplate(r), "status", rec.status, "error", err)
+			}
+		})
+	}
+}
+
+// bodyRecorder keeps a copy of the response body as it is written
+type bodyRecorder struct {
+	statusRecorder
+	body bytes.Buffer
+}
+
+// Write keeps a copy of b before writing it
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.statusRecorder.Write(b)
+}
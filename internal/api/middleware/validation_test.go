@@ -0,0 +1,167 @@
+package middleware_test
+
+import (
+	"bytes"
+	"github.com/gorilla/mux"
+	"github.com/grokkos/go-isa-retail-service/internal/api/middleware"
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateRequest(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	var served string
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		served = string(body)
+	}
+	r := mux.NewRouter()
+	r.Use(middleware.ValidateRequest(spec))
+	r.HandleFunc("/api/v1/investments", echo).Methods("POST")
+	r.HandleFunc("/api/v1/customers/{id}/investments", echo).Methods("GET")
+	r.HandleFunc("/api/v1/customers/{id}/plans/{planID}", echo).Methods("PUT")
+	r.HandleFunc("/undocumented", echo).Methods("POST")
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		status   int
+		problems []string
+	}{
+		{
+			name:   "valid body",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:   `{"customer_id":"customer-1","fund_id":"fund-1","amount":"250.00","unknown":1}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "missing fields",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:     `{"customer_id":"customer-1"}`,
+			status:   http.StatusBadRequest,
+			problems: []string{"request body.fund_id is required", "request body.amount is required"},
+		},
+		{
+			name:   "wrong types",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:     `{"customer_id":1,"fund_id":"fund-1","amount":"12.345","risk_acknowledged":"yes"}`,
+			status:   http.StatusBadRequest,
+			problems: []string{"request body.customer_id must be a string", "request body.amount must match", "request body.risk_acknowledged must be a boolean"},
+		},
+		{
+			name:   "not an object",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:     `[]`,
+			status:   http.StatusBadRequest,
+			problems: []string{"request body must be an object"},
+		},
+		{
+			name:   "invalid JSON",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:     `{"customer_id":`,
+			status:   http.StatusBadRequest,
+			problems: []string{"request body is not valid JSON"},
+		},
+		{
+			name:   "missing body",
+			method: http.MethodPost, target: "/api/v1/investments",
+			status:   http.StatusBadRequest,
+			problems: []string{"request body is required"},
+		},
+		{
+			name:   "too large",
+			method: http.MethodPost, target: "/api/v1/investments",
+			body:     `{"customer_id":"` + strings.Repeat("x", 1<<20) + `"}`,
+			status:   http.StatusRequestEntityTooLarge,
+			problems: []string{"request body is too large"},
+		},
+		{
+			name:   "valid query",
+			method: http.MethodGet, target: "/api/v1/customers/customer-1/investments?limit=10&status=pending",
+			status: http.StatusOK,
+		},
+		{
+			name:   "invalid query",
+			method: http.MethodGet, target: "/api/v1/customers/customer-1/investments?limit=ten",
+			status:   http.StatusBadRequest,
+			problems: []string{"query parameter limit must be an integer"},
+		},
+		{
+			name:   "out of range",
+			method: http.MethodPut, target: "/api/v1/customers/customer-1/plans/plan-1",
+			body:     `{"amount":"100.00","day_of_month":31,"status":"stopped"}`,
+			status:   http.StatusBadRequest,
+			problems: []string{"request body.day_of_month must be at most 28", "request body.status must be one of active, paused, cancelled"},
+		},
+		{
+			name:   "undocumented route",
+			method: http.MethodPost, target: "/undocumented",
+			body:   `not json`,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served = ""
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			for _, problem := range tt.problems {
+				assert.Contains(t, rec.Body.String(), problem)
+			}
+			if tt.status == http.StatusOK {
+				// Handlers can still read the validated body
+				assert.Equal(t, tt.body, served)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	r := mux.NewRouter()
+	r.Use(middleware.ValidateResponse(spec, slog.New(slog.NewJSONHandler(&logs, nil))))
+	r.HandleFunc("/api/v1/funds/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}).Methods("GET")
+	r.HandleFunc("/api/v1/investments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such investment", http.StatusNotFound)
+	}).Methods("GET")
+
+	t.Run("A matching response is not logged", func(t *testing.T) {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/investments/inv-1", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("A mismatched response is logged and still sent", func(t *testing.T) {
+		logs.Reset()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/funds/fund-1", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"id":1}`, rec.Body.String())
+		assert.Contains(t, logs.String(), "response does not match the OpenAPI specification")
+		assert.Contains(t, logs.String(), "response body.id must be a string")
+	})
+}
@@ -0,0 +1,486 @@
+// Package openapi holds the OpenAPI 3 specification of the API, and checks
+// requests and responses against it.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// document is the specification as served
+//
+//go:embed openapi.json
+var document []byte
+
+// maxBody is the largest request body that will be read to validate it
+const maxBody = 1 << 20
+
+// ErrBodyTooLarge is returned by ValidateRequest for a body over 1 MiB
+var ErrBodyTooLarge = errors.New("request body is too large")
+
+// Spec is the part of an OpenAPI 3 document the API is described and validated with
+type Spec struct {
+	OpenAPI string `json:"openapi"`
+	// Paths maps each route template to its operations by lower case method
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	document []byte
+}
+
+// Components are the definitions operations refer to
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+	Responses  map[string]*Response  `json:"responses"`
+}
+
+// Operation is one method on a route
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response an operation may answer with
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType describes a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema describes a JSON value. Only the keywords the API uses are supported,
+// and a document using any other is refused rather than partly validated.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []string           `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+
+	pattern *regexp.Regexp
+	// unsupported holds the keywords the schema uses that are not supported
+	unsupported []string
+}
+
+// annotations are the schema keywords that describe a value without
+// constraining it, so need no validating
+var annotations = []string{"title", "description", "example", "deprecated"}
+
+// UnmarshalJSON decodes a schema, noting the keywords it uses that are not supported
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	// Decoding as a type without this method fills in the fields as usual
+	type fields Schema
+	if err := json.Unmarshal(data, (*fields)(s)); err != nil {
+		return err
+	}
+
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+	supported := reflect.TypeOf(*s)
+	for keyword := range keywords {
+		if slices.Contains(annotations, keyword) {
+			continue
+		}
+		known := false
+		for i := 0; i < supported.NumField() && !known; i++ {
+			known = supported.Field(i).Tag.Get("json") == keyword
+		}
+		if !known {
+			s.unsupported = append(s.unsupported, keyword)
+		}
+	}
+	sort.Strings(s.unsupported)
+	return nil
+}
+
+// Load parses the API's specification
+func Load() (*Spec, error) {
+	return Parse(document)
+}
+
+// Parse parses a specification, resolving the parameters and responses
+// operations refer to, checking every schema reference resolves and refusing
+// schema keywords that are not supported
+func Parse(document []byte) (*Spec, error) {
+	spec := Spec{document: document}
+	if err := json.Unmarshal(document, &spec); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+
+	var errs []error
+	for path, operations := range spec.Paths {
+		for method, op := range operations {
+			at := strings.ToUpper(method) + " " + path
+			for i, param := range op.Parameters {
+				if param.Ref == "" {
+					continue
+				}
+				resolved := spec.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+				if resolved == nil {
+					errs = append(errs, fmt.Errorf("%s: unknown parameter %s", at, param.Ref))
+					continue
+				}
+				op.Parameters[i] = resolved
+			}
+			for status, response := range op.Responses {
+				if response.Ref == "" {
+					continue
+				}
+				resolved := spec.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+				if resolved == nil {
+					errs = append(errs, fmt.Errorf("%s: unknown response %s", at, response.Ref))
+					continue
+				}
+				op.Responses[status] = resolved
+			}
+		}
+	}
+
+	for _, schema := range spec.schemas() {
+		if schema.Ref != "" && spec.Resolve(schema) == nil {
+			errs = append(errs, fmt.Errorf("unknown schema %s", schema.Ref))
+		}
+		for _, keyword := range schema.unsupported {
+			errs = append(errs, fmt.Errorf("schema keyword %s is not supported", keyword))
+		}
+		if schema.Pattern != "" {
+			var err error
+			if schema.pattern, err = regexp.Compile(schema.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf("pattern %q: %w", schema.Pattern, err))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return &spec, nil
+}
+
+// schemas returns every schema in the document, however deeply nested
+func (s *Spec) schemas() []*Schema {
+	var all []*Schema
+	var walk func(schema *Schema)
+	walk = func(schema *Schema) {
+		if schema == nil {
+			return
+		}
+		all = append(all, schema)
+		for _, property := range schema.Properties {
+			walk(property)
+		}
+		walk(schema.Items)
+		walk(schema.AdditionalProperties)
+	}
+
+	walkContent := func(content map[string]MediaType) {
+		for _, media := range content {
+			walk(media.Schema)
+		}
+	}
+	for _, schema := range s.Components.Schemas {
+		walk(schema)
+	}
+	for _, param := range s.Components.Parameters {
+		walk(param.Schema)
+	}
+	for _, response := range s.Components.Responses {
+		walkContent(response.Content)
+	}
+	for _, operations := range s.Paths {
+		for _, op := range operations {
+			for _, param := range op.Parameters {
+				walk(param.Schema)
+			}
+			if op.RequestBody != nil {
+				walkContent(op.RequestBody.Content)
+			}
+			for _, response := range op.Responses {
+				walkContent(response.Content)
+			}
+		}
+	}
+	return all
+}
+
+// Document is the specification as JSON
+func (s *Spec) Document() []byte {
+	return s.document
+}
+
+// Operation returns the operation for the method on the route template, such
+// as /api/v1/investments/{id}, or nil when the specification does not describe it
+func (s *Spec) Operation(method, template string) *Operation {
+	return s.Paths[template][strings.ToLower(method)]
+}
+
+// Resolve follows a schema's reference to the component schema it names,
+// returning nil when there is no such schema
+func (s *Spec) Resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// ValidateRequest checks the request's parameters and JSON body against the
+// operation, given the values of the path parameters, reporting every problem
+// found. The body is replaced once read, so handlers can still read it.
+func (s *Spec) ValidateRequest(op *Operation, r *http.Request, pathParams map[string]string) error {
+	var errs []error
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		switch param.In {
+		case "path":
+			value = pathParams[param.Name]
+		case "query":
+			value = query.Get(param.Name)
+		case "header":
+			value = r.Header.Get(param.Name)
+		}
+		at := param.In + " parameter " + param.Name
+		if value == "" {
+			if param.Required {
+				errs = append(errs, fmt.Errorf("%s is required", at))
+			}
+			continue
+		}
+		errs = append(errs, s.validate(param.Schema, parameterValue(s.Resolve(param.Schema), value), at)...)
+	}
+
+	if op.RequestBody != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			return errors.New("reading request body")
+		}
+		if len(body) > maxBody {
+			return ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if len(bytes.TrimSpace(body)) == 0 {
+			if op.RequestBody.Required {
+				errs = append(errs, errors.New("request body is required"))
+			}
+		} else if media, ok := op.RequestBody.Content["application/json"]; ok {
+			errs = append(errs, s.validateJSON(media.Schema, body, "request body")...)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateResponse checks a response's status, content type and JSON body
+// against the operation, reporting every problem found
+func (s *Spec) ValidateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	response := op.Responses[strconv.Itoa(status)]
+	if response == nil {
+		response = op.Responses["default"]
+	}
+	if response == nil {
+		return fmt.Errorf("response status %d is not described", status)
+	}
+
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("response status %d should have no body", status)
+		}
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("response content type: %w", err)
+	}
+	media, ok := response.Content[contentType]
+	if !ok {
+		return fmt.Errorf("response content type %s is not described for status %d", contentType, status)
+	}
+	if contentType != "application/json" {
+		return nil
+	}
+	return errors.Join(s.validateJSON(media.Schema, body, "response body")...)
+}
+
+// validateJSON checks a JSON document against a schema
+func (s *Spec) validateJSON(schema *Schema, body []byte, at string) []error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []error{fmt.Errorf("%s is not valid JSON: %w", at, err)}
+	}
+	return s.validate(schema, value, at)
+}
+
+// parameterValue converts a parameter to the JSON value its schema describes,
+// leaving it a string when it cannot be, so validation reports it
+func parameterValue(schema *Schema, value string) any {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validate checks a decoded JSON value against a schema, naming problems by
+// their place in the value
+func (s *Spec) validate(schema *Schema, value any, at string) []error {
+	schema = s.Resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []error{fmt.Errorf("%s must not be null", at)}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []error{fmt.Errorf("%s must be an object", at)}
+		}
+		return s.validateObject(schema, object, at)
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return []error{fmt.Errorf("%s must be an array", at)}
+		}
+		var errs []error
+		for i, item := range array {
+			errs = append(errs, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return errs
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []error{fmt.Errorf("%s must be a string", at)}
+		}
+		return validateString(schema, str, at)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			if schema.Type == "integer" {
+				return []error{fmt.Errorf("%s must be an integer", at)}
+			}
+			return []error{fmt.Errorf("%s must be a number", at)}
+		}
+		return validateNumber(schema, number, at)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []error{fmt.Errorf("%s must be a boolean", at)}
+		}
+	}
+	return nil
+}
+
+// validateObject checks an object's required and known properties. Properties
+// the schema does not know are allowed, as they are ignored.
+func (s *Spec) validateObject(schema *Schema, object map[string]any, at string) []error {
+	var errs []error
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s.%s is required", at, name))
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property := schema.Properties[name]
+		if property == nil {
+			property = schema.AdditionalProperties
+		}
+		errs = append(errs, s.validate(property, object[name], at+"."+name)...)
+	}
+	return errs
+}
+
+// validateString checks a string's enum and pattern
+func validateString(schema *Schema, value, at string) []error {
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		return []error{fmt.Errorf("%s must be one of %s", at, strings.Join(schema.Enum, ", "))}
+	}
+	if schema.pattern != nil && !schema.pattern.MatchString(value) {
+		return []error{fmt.Errorf("%s must match %s", at, schema.Pattern)}
+	}
+	return nil
+}
+
+// validateNumber checks a number is whole when it should be, and within range
+func validateNumber(schema *Schema, value json.Number, at string) []error {
+	n, err := value.Float64()
+	if err != nil {
+		return []error{fmt.Errorf("%s must be a number", at)}
+	}
+	if schema.Type == "integer" {
+		if _, err := value.Int64(); err != nil {
+			return []error{fmt.Errorf("%s must be an integer", at)}
+		}
+	}
+	if schema.Minimum != nil && n < *schema.Minimum {
+		return []error{fmt.Errorf("%s must be at least %v", at, *schema.Minimum)}
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		return []error{fmt.Errorf("%s must be at most %v", at, *schema.Maximum)}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"github.com/grokkos/go-isa-retail-service/internal/api/handler"
+	"github.com/grokkos/go-isa-retail-service/internal/api/openapi"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestSchemas are the schemas of request bodies, by the type handlers decode them into
+var requestSchemas = map[string]any{
+	"CreateInvestmentRequest": handler.CreateInvestmentRequest{},
+	"PriceInvestmentRequest":  handler.PriceInvestmentRequest{},
+	"FundRequest":             handler.FundRequest{},
+	"RiskProfileRequest":      handler.RiskProfileRequest{},
+	"LedgerAmountRequest":     handler.LedgerAmountRequest{},
+	"CreateSwitchRequest":     handler.CreateSwitchRequest{},
+	"PlanRequest":             handler.PlanRequest{},
+	"PlanAllocationRequest":   handler.PlanAllocationRequest{},
+	"ApprovalRequest":         handler.ApprovalRequest{},
+	"DecisionRequest":         handler.DecisionRequest{},
+	"APIKeySettings":          domain.APIKeySettings{},
+}
+
+// responseSchemas are the schemas of response bodies, by the type handlers encode
+var responseSchemas = map[string]any{
+	"CreateInvestmentResponse": handler.CreateInvestmentResponse{},
+	"InvestmentDetailResponse": handler.InvestmentDetailResponse{},
+	"EnrichedInvestment":       handler.EnrichedInvestment{},
+	"InvestmentEventResponse":  handler.InvestmentEventResponse{},
+	"Investment":               domain.Investment{},
+	"Fund":                     domain.Fund{},
+	"Customer":                 domain.Customer{},
+	"CustomerDetails":          domain.CustomerDetails{},
+	"RiskQuestion":             domain.RiskQuestion{},
+	"RiskProfileResponse":      handler.RiskProfileResponse{},
+	"Recommendations":          domain.Recommendations{},
+	"FundRecommendation":       domain.FundRecommendation{},
+	"BalancesResponse":         handler.BalancesResponse{},
+	"Journal":                  domain.Journal{},
+	"Posting":                  domain.Posting{},
+	"SwitchResponse":           handler.SwitchResponse{},
+	"PlanResponse":             handler.PlanResponse{},
+	"PlanAllocation":           handler.PlanAllocationResponse{},
+	"Approval":                 domain.Approval{},
+	"APIKey":                   domain.APIKey{},
+	"IssuedAPIKey":             domain.IssuedAPIKey{},
+	"HealthReport":             health.Report{},
+	"HealthCheckResult":        health.Result{},
+}
+
+func TestLoad(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.True(t, json.Valid(spec.Document()))
+	assert.NotNil(t, spec.Operation("POST", "/api/v1/investments"))
+	assert.NotNil(t, spec.Operation("get", "/api/v1/customers/{id}/plans/{planID}"))
+	assert.Nil(t, spec.Operation("PATCH", "/api/v1/investments"))
+}
+
+func TestParseRefusesUnsupportedKeywords(t *testing.T) {
+	_, err := openapi.Parse([]byte(`{
+		"openapi": "3.0.3",
+		"components": {"schemas": {"Name": {
+			"type": "object",
+			"description": "Annotations are allowed",
+			"properties": {"first": {"type": "string", "minLength": 1}},
+			"allOf": [{"required": ["first"]}]
+		}}}
+	}`))
+	assert.ErrorContains(t, err, "schema keyword allOf is not supported")
+	assert.ErrorContains(t, err, "schema keyword minLength is not supported")
+	assert.NotContains(t, err.Error(), "description")
+}
+
+func TestSchemasMatchTypes(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	// Every object schema is checked against the type it describes
+	for name, schema := range spec.Components.Schemas {
+		if schema.Type != "object" {
+			continue
+		}
+		_, isRequest := requestSchemas[name]
+		_, isResponse := responseSchemas[name]
+		assert.True(t, isRequest || isResponse, "schema %s is not checked against a type", name)
+	}
+
+	for name, value := range requestSchemas {
+		t.Run(name, func(t *testing.T) {
+			checkSchema(t, spec, &openapi.Schema{Ref: "#/components/schemas/" + name}, reflect.TypeOf(value), name, false)
+		})
+	}
+	for name, value := range responseSchemas {
+		t.Run(name, func(t *testing.T) {
+			checkSchema(t, spec, &openapi.Schema{Ref: "#/components/schemas/" + name}, reflect.TypeOf(value), name, true)
+		})
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// checkSchema checks a schema describes how typ is encoded as JSON. A response
+// schema must also require exactly the fields that are always encoded.
+func checkSchema(t *testing.T, spec *openapi.Spec, schema *openapi.Schema, typ reflect.Type, at string, response bool) {
+	t.Helper()
+
+	schema = spec.Resolve(schema)
+	require.NotNil(t, schema, "%s: schema not found", at)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		assert.Equal(t, "string", schema.Type, at)
+		assert.Equal(t, "date-time", schema.Format, at)
+		return
+	case typ == rawMessageType:
+		assert.Empty(t, schema.Type, "%s can be any JSON value", at)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		assert.Equal(t, "string", schema.Type, at)
+	case reflect.Bool:
+		assert.Equal(t, "boolean", schema.Type, at)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		assert.Equal(t, "integer", schema.Type, at)
+	case reflect.Float32, reflect.Float64:
+		assert.Equal(t, "number", schema.Type, at)
+	case reflect.Slice:
+		if assert.Equal(t, "array", schema.Type, at) {
+			checkSchema(t, spec, schema.Items, typ.Elem(), at+"[]", response)
+		}
+	case reflect.Map:
+		if assert.Equal(t, "object", schema.Type, at) && assert.NotNil(t, schema.AdditionalProperties, at) {
+			checkSchema(t, spec, schema.AdditionalProperties, typ.Elem(), at+"{}", response)
+		}
+	case reflect.Struct:
+		if assert.Equal(t, "object", schema.Type, at) {
+			checkObject(t, spec, schema, typ, at, response)
+		}
+	default:
+		t.Errorf("%s: unsupported type %s", at, typ)
+	}
+}
+
+// jsonField is a struct field as encoded by encoding/json
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields lists the fields of a struct as encoding/json encodes them,
+// including those of embedded structs
+func jsonFields(typ reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			fields = append(fields, jsonFields(embedded)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: field.Type, omitEmpty: strings.Contains(options, "omitempty")})
+	}
+	return fields
+}
+
+// checkObject checks an object schema has exactly the struct's fields
+func checkObject(t *testing.T, spec *openapi.Spec, schema *openapi.Schema, typ reflect.Type, at string, response bool) {
+	t.Helper()
+
+	fields := jsonFields(typ)
+	var names, required, alwaysEncoded []string
+	for _, field := range fields {
+		names = append(names, field.name)
+		if !field.omitEmpty {
+			alwaysEncoded = append(alwaysEncoded, field.name)
+		}
+
+		property := schema.Properties[field.name]
+		if !assert.NotNil(t, property, "%s.%s is not in the schema", at, field.name) {
+			continue
+		}
+		checkSchema(t, spec, property, field.typ, at+"."+field.name, response)
+
+		// nil slices, maps and pointers are encoded as null
+		kind := field.typ.Kind()
+		if response && !field.omitEmpty && (kind == reflect.Slice || kind == reflect.Map || kind == reflect.Pointer) && field.typ != rawMessageType {
+			assert.True(t, spec.Resolve(property).Nullable, "%s.%s can be null", at, field.name)
+		}
+	}
+
+	var properties []string
+	for name := range schema.Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(names)
+	sort.Strings(properties)
+	assert.Equal(t, names, properties, "%s: schema properties differ from the type's fields", at)
+
+	// A required property must always be encoded, and in a response every
+	// property that is always encoded is required
+	required = append(required, schema.Required...)
+	sort.Strings(required)
+	sort.Strings(alwaysEncoded)
+	if response {
+		assert.Equal(t, alwaysEncoded, required, "%s: required properties differ from the fields without omitempty", at)
+	} else {
+		assert.Subset(t, alwaysEncoded, required, "%s: required properties must not be omitempty", at)
+	}
+}
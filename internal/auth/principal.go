@@ -0,0 +1,93 @@
+// Package auth holds the identity of the caller making a request, what they are
+// allowed to do and the keys used to verify it.
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject is the token subject, which for customers is their customer ID
+	Subject string
+	Roles   []Role
+	// Permissions are granted directly rather than through a role, as with the
+	// scopes of a partner's API key
+	Permissions []Permission
+	// CustomerIDs are the customers a partner may act for
+	CustomerIDs []string
+}
+
+// HasRole reports whether the caller holds the role
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the caller was granted the permission directly or by any of their roles
+func (p *Principal) Can(permission Permission) bool {
+	if slices.Contains(p.Permissions, permission) {
+		return true
+	}
+	for _, role := range permissions[permission] {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStaff reports whether the caller holds a back-office role, which is not
+// limited to a single customer
+func (p *Principal) isStaff() bool {
+	for _, role := range p.Roles {
+		if role != RoleCustomer {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, if there is one
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// Allowed reports whether the caller in ctx has the permission. Requests without
+// a principal are never allowed.
+func Allowed(ctx context.Context, permission Permission) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && principal.Can(permission)
+}
+
+// IsCustomer reports whether the caller is the customer themselves rather than
+// someone acting for them
+func IsCustomer(ctx context.Context, customerID string) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && principal.HasRole(RoleCustomer) && principal.Subject == customerID
+}
+
+// CanAccessCustomer reports whether the caller may act for the customer. Callers
+// with only the customer role may only act for themselves and partners for
+// their linked customers, while back-office staff may act for any customer
+// their permissions cover. Requests without a principal may not act for anyone.
+func CanAccessCustomer(ctx context.Context, customerID string) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return principal.isStaff() || principal.Subject == customerID || slices.Contains(principal.CustomerIDs, customerID)
+}
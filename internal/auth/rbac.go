@@ -0,0 +1,84 @@
+package auth
+
+// Role is a set of permissions granted to a caller through their token
+type Role string
+
+const (
+	// RoleCustomer acts for the customer whose ID is the token subject
+	RoleCustomer Role = "customer"
+	// RoleSupport answers customer queries and can cancel on their behalf
+	RoleSupport Role = "support_agent"
+	// RoleOperations runs dealing, fund administration and ledger postings
+	RoleOperations Role = "operations"
+	// RoleCompliance reviews customer activity and the audit log
+	RoleCompliance Role = "compliance"
+	RoleAdmin      Role = "admin"
+)
+
+// ParseRole returns the role with the given name, if there is one
+func ParseRole(name string) (Role, bool) {
+	switch role := Role(name); role {
+	case RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin:
+		return role, true
+	}
+	return "", false
+}
+
+// Permission is an operation a role may be granted
+type Permission string
+
+const (
+	PermFundsRead           Permission = "funds:read"
+	PermFundsManage         Permission = "funds:manage"
+	PermCustomersRead       Permission = "customers:read"
+	PermCustomersUpdate     Permission = "customers:update"
+	PermRiskProfileSubmit   Permission = "risk_profile:submit"
+	PermRecommendationsRead Permission = "recommendations:read"
+	PermInvestmentsRead     Permission = "investments:read"
+	PermInvestmentsCreate   Permission = "investments:create"
+	PermInvestmentsDeal     Permission = "investments:deal" // pricing and processing
+	PermInvestmentsCancel   Permission = "investments:cancel"
+	PermInvestmentsWithdraw Permission = "investments:withdraw"
+	PermSwitchesRead        Permission = "switches:read"
+	PermSwitchesCreate      Permission = "switches:create"
+	PermLedgerRead          Permission = "ledger:read"
+	PermLedgerPost          Permission = "ledger:post" // fees and dividends
+	PermPlansRead           Permission = "plans:read"
+	PermPlansManage         Permission = "plans:manage"
+	PermPlansRun            Permission = "plans:run" // executing plans that have fallen due
+	PermApprovalsRead       Permission = "approvals:read"
+	PermApprovalsRequest    Permission = "approvals:request"
+	PermApprovalsDecide     Permission = "approvals:decide"
+	PermAPIKeysManage       Permission = "api_keys:manage"
+)
+
+// permissions is the permission matrix: the roles granted each permission.
+// Customers are further limited to their own records by CanAccessCustomer.
+// Approving a change also needs the permission to make the change itself.
+var permissions = map[Permission][]Role{
+	PermFundsRead:           {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermFundsManage:         {RoleOperations, RoleAdmin},
+	PermCustomersRead:       {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermCustomersUpdate:     {RoleSupport, RoleOperations, RoleAdmin},
+	PermRiskProfileSubmit:   {RoleCustomer, RoleAdmin},
+	PermRecommendationsRead: {RoleCustomer, RoleSupport, RoleAdmin},
+	PermInvestmentsRead:     {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermInvestmentsCreate:   {RoleCustomer, RoleAdmin},
+	PermInvestmentsDeal:     {RoleOperations, RoleAdmin},
+	PermInvestmentsCancel:   {RoleCustomer, RoleSupport, RoleOperations, RoleAdmin},
+	PermInvestmentsWithdraw: {RoleCustomer, RoleOperations, RoleAdmin},
+	PermSwitchesRead:        {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermSwitchesCreate:      {RoleCustomer, RoleAdmin},
+	PermLedgerRead:          {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermLedgerPost:          {RoleOperations, RoleAdmin},
+	PermPlansRead:           {RoleCustomer, RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermPlansManage:         {RoleCustomer, RoleAdmin},
+	PermPlansRun:            {RoleOperations, RoleAdmin},
+	PermApprovalsRead:       {RoleSupport, RoleOperations, RoleCompliance, RoleAdmin},
+	PermApprovalsRequest:    {RoleSupport, RoleOperations, RoleAdmin},
+	PermApprovalsDecide:     {RoleOperations, RoleAdmin},
+	PermAPIKeysManage:       {RoleAdmin},
+}
+
+// PartnerScopes are the permissions that can be granted to a partner's API key
+var PartnerScopes = []Permission{PermFundsRead, PermInvestmentsCreate, PermInvestmentsRead}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Headers partners send with API key requests. Signed requests carry all four.
+const (
+	HeaderAPIKey    = "X-API-Key"
+	HeaderTimestamp = "X-Timestamp" // Unix seconds
+	HeaderNonce     = "X-Nonce"     // Unique for each request made with the key
+	HeaderSignature = "X-Signature" // Hex HMAC-SHA256 of the signing string
+)
+
+// SigningString is what a request is signed over: its method, path and query,
+// timestamp, nonce and the hex SHA-256 of its body, one per line
+func SigningString(method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// Sign returns the signature of a signing string with a key's signing secret
+func Sign(secret, signingString string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingString))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidSignature reports whether signature is the signature of signingString,
+// comparing in constant time
+func ValidSignature(secret, signingString, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, signingString)), []byte(strings.ToLower(signature)))
+}
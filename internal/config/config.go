@@ -0,0 +1,320 @@
+// Package config loads the service's configuration from an optional JSON file
+// and environment variable overrides, and validates it before the server starts.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/tracing"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration written as a string such as "15s" in the file and environment
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration such as "15s" or "1m30s"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText writes the duration as a string such as "15s"
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Config is the configuration of the API server. Each setting can be given in
+// the file under its json key, and overridden by the environment variable in
+// its env tag.
+type Config struct {
+	Server    Server    `json:"server"`
+	Storage   Storage   `json:"storage"`
+	Auth      Auth      `json:"auth"`
+	Logging   Logging   `json:"logging"`
+	Tracing   Tracing   `json:"tracing"`
+	Health    Health    `json:"health"`
+	OpenAPI   OpenAPI   `json:"openapi"`
+	Allowance Allowance `json:"allowance"`
+	Features  Features  `json:"features"`
+}
+
+// Server configures how the API is served
+type Server struct {
+	Addr         string   `json:"addr" env:"LISTEN_ADDR"`
+	ReadTimeout  Duration `json:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout Duration `json:"write_timeout" env:"WRITE_TIMEOUT"`
+	// RequestTimeout is the deadline of each request's context, shorter than WriteTimeout
+	RequestTimeout Duration `json:"request_timeout" env:"REQUEST_TIMEOUT"`
+	// DrainDelay is how long readiness fails before shutdown starts
+	DrainDelay Duration `json:"drain_delay" env:"DRAIN_DELAY"`
+	// ShutdownTimeout is how long in-flight requests get to finish
+	ShutdownTimeout Duration `json:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	TLS             TLS      `json:"tls"`
+}
+
+// TLS configures HTTPS. The API is served over plain HTTP when neither file is set.
+type TLS struct {
+	CertFile string `json:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `json:"key_file" env:"TLS_KEY_FILE"`
+}
+
+// Enabled reports whether the API is served over HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// StorageMemory keeps everything but the audit log in memory, seeded with test data
+const StorageMemory = "memory"
+
+// Storage configures where data is kept
+type Storage struct {
+	Backend      string `json:"backend" env:"STORAGE_BACKEND"`
+	AuditLogPath string `json:"audit_log_path" env:"AUDIT_LOG_PATH"`
+}
+
+// Auth configures how API callers are authenticated
+type Auth struct {
+	// Disabled makes every caller an administrator, for local development only
+	Disabled bool   `json:"disabled" env:"AUTH_DISABLED"`
+	JWKSPath string `json:"jwks_path" env:"JWKS_PATH"`
+	Issuer   string `json:"issuer" env:"JWT_ISSUER"`
+	Audience string `json:"audience" env:"JWT_AUDIENCE"`
+}
+
+// Logging configures the JSON logs
+type Logging struct {
+	Level slog.Level `json:"level" env:"LOG_LEVEL"`
+}
+
+// Tracing configures where spans are exported
+type Tracing struct {
+	Exporter string `json:"exporter" env:"OTEL_TRACES_EXPORTER"`
+}
+
+// Health configures the readiness checks
+type Health struct {
+	CheckTimeout Duration `json:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// PriceMaxAge is how long a subscription may wait for its price before the prices check fails
+	PriceMaxAge Duration `json:"price_max_age" env:"HEALTH_PRICE_MAX_AGE"`
+	// PricesCritical makes a failing prices check fail readiness rather than only report it
+	PricesCritical bool `json:"prices_critical" env:"HEALTH_PRICES_CRITICAL"`
+}
+
+// OpenAPI configures checking the API against its OpenAPI specification.
+// Requests are always validated.
+type OpenAPI struct {
+	// ValidateResponses logs responses that do not match the specification. It
+	// buffers every response, so it is for development and test environments.
+	ValidateResponses bool `json:"validate_responses" env:"OPENAPI_VALIDATE_RESPONSES"`
+}
+
+// Allowance configures the ISA subscription rules
+type Allowance struct {
+	AnnualLimitPence  int64      `json:"annual_limit_pence" env:"ISA_ANNUAL_LIMIT_PENCE"`
+	TaxYearStartMonth time.Month `json:"tax_year_start_month" env:"ISA_TAX_YEAR_START_MONTH"`
+	TaxYearStartDay   int        `json:"tax_year_start_day" env:"ISA_TAX_YEAR_START_DAY"`
+}
+
+// Rules are the allowance rules the services check subscriptions against
+func (a Allowance) Rules() domain.AllowanceRules {
+	return domain.AllowanceRules{
+		AnnualLimit:       a.AnnualLimitPence,
+		TaxYearStartMonth: a.TaxYearStartMonth,
+		TaxYearStartDay:   a.TaxYearStartDay,
+	}
+}
+
+// Features turns optional parts of the service on and off
+type Features struct {
+	PlanScheduler   bool `json:"plan_scheduler" env:"FEATURE_PLAN_SCHEDULER"`
+	PartnerAPIKeys  bool `json:"partner_api_keys" env:"FEATURE_PARTNER_API_KEYS"`
+	Recommendations bool `json:"recommendations" env:"FEATURE_RECOMMENDATIONS"`
+	Metrics         bool `json:"metrics" env:"FEATURE_METRICS"`
+}
+
+// Default returns the configuration used for anything the file and environment leave out
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     Duration{15 * time.Second},
+			WriteTimeout:    Duration{15 * time.Second},
+			RequestTimeout:  Duration{10 * time.Second},
+			DrainDelay:      Duration{10 * time.Second},
+			ShutdownTimeout: Duration{5 * time.Second},
+		},
+		Storage: Storage{
+			Backend:      StorageMemory,
+			AuditLogPath: "audit.log",
+		},
+		Logging: Logging{Level: slog.LevelInfo},
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+		Health: Health{
+			CheckTimeout: Duration{2 * time.Second},
+			// Allows for a weekend without dealing
+			PriceMaxAge: Duration{72 * time.Hour},
+		},
+		Allowance: Allowance{
+			AnnualLimitPence:  domain.DefaultAllowanceRules.AnnualLimit,
+			TaxYearStartMonth: domain.DefaultAllowanceRules.TaxYearStartMonth,
+			TaxYearStartDay:   domain.DefaultAllowanceRules.TaxYearStartDay,
+		},
+		Features: Features{
+			PlanScheduler:   true,
+			PartnerAPIKeys:  true,
+			Recommendations: true,
+			Metrics:         true,
+		},
+	}
+}
+
+// Load reads the configuration from the JSON file at path, when there is one,
+// on top of the defaults, applies overrides from getenv and validates the result
+func Load(path string, getenv func(string) string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := cfg.decode(data); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), getenv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decode decodes a config file, rejecting settings we do not know so typos are caught
+func (c *Config) decode(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(c)
+}
+
+// textUnmarshaler is the type of settings parsed from text, such as durations and log levels
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// applyEnv sets each field with an env tag from its environment variable, when set
+func applyEnv(v reflect.Value, getenv func(string) string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		structField := v.Type().Field(i)
+
+		name, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if field.Kind() == reflect.Struct && !field.Addr().Type().Implements(textUnmarshaler) {
+				if err := applyEnv(field, getenv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value := getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setField parses value into a setting
+func setField(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate checks the configuration is one the server can run with, reporting every problem at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	s := c.Server
+	check(s.Addr != "", "server.addr must be set")
+	check(s.ReadTimeout.Duration > 0, "server.read_timeout must be positive")
+	check(s.WriteTimeout.Duration > 0, "server.write_timeout must be positive")
+	check(s.RequestTimeout.Duration > 0 && s.RequestTimeout.Duration < s.WriteTimeout.Duration,
+		"server.request_timeout must be positive and shorter than server.write_timeout, so the response can still be written")
+	check(s.DrainDelay.Duration >= 0, "server.drain_delay must not be negative")
+	check(s.ShutdownTimeout.Duration > 0, "server.shutdown_timeout must be positive")
+	check(s.TLS.Enabled() == (s.TLS.CertFile != "" && s.TLS.KeyFile != ""), "server.tls needs both cert_file and key_file")
+	for _, file := range []string{s.TLS.CertFile, s.TLS.KeyFile} {
+		if file != "" {
+			_, err := os.Stat(file)
+			check(err == nil, "server.tls: %v", err)
+		}
+	}
+
+	check(c.Storage.Backend == StorageMemory, "storage.backend %q is not supported, only %q is", c.Storage.Backend, StorageMemory)
+	check(c.Storage.AuditLogPath != "", "storage.audit_log_path must be set")
+
+	check(c.Auth.Disabled || c.Auth.JWKSPath != "", "auth.jwks_path must be set unless auth is disabled")
+
+	switch c.Tracing.Exporter {
+	case "", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterConsole:
+	default:
+		check(false, "tracing.exporter %q must be %q, %q or %q", c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterConsole)
+	}
+
+	check(c.Health.CheckTimeout.Duration > 0, "health.check_timeout must be positive")
+	check(c.Health.PriceMaxAge.Duration > 0, "health.price_max_age must be positive")
+
+	a := c.Allowance
+	check(a.AnnualLimitPence > 0, "allowance.annual_limit_pence must be positive")
+	check(a.TaxYearStartMonth >= time.January && a.TaxYearStartMonth <= time.December, "allowance.tax_year_start_month must be between 1 and 12")
+	// Days after the 28th do not exist in every month
+	check(a.TaxYearStartDay >= 1 && a.TaxYearStartDay <= 28, "allowance.tax_year_start_day must be between 1 and 28")
+
+	return errors.Join(errs...)
+}
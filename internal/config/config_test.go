@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"github.com/grokkos/go-isa-retail-service/internal/config"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// env returns a getenv reading from vars
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+// writeConfig writes a config file for a test and returns its path
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults need only a JWKS or auth disabled", func(t *testing.T) {
+		cfg, err := config.Load("", env(map[string]string{"AUTH_DISABLED": "true"}))
+		require.NoError(t, err)
+		assert.Equal(t, ":8080", cfg.Server.Addr)
+		assert.Equal(t, 15*time.Second, cfg.Server.WriteTimeout.Duration)
+		assert.Equal(t, config.StorageMemory, cfg.Storage.Backend)
+		assert.Equal(t, domain.DefaultAllowanceRules, cfg.Allowance.Rules())
+		assert.True(t, cfg.Features.PlanScheduler)
+	})
+
+	t.Run("The example config file is valid", func(t *testing.T) {
+		_, err := config.Load("../../config.example.json", env(nil))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Environment variables override the file", func(t *testing.T) {
+		path := writeConfig(t, `{
+			"server": {"addr": ":9000", "write_timeout": "30s"},
+			"auth": {"jwks_path": "jwks.json"},
+			"logging": {"level": "warn"},
+			"allowance": {"annual_limit_pence": 2500000},
+			"features": {"recommendations": false}
+		}`)
+
+		cfg, err := config.Load(path, env(map[string]string{
+			"LISTEN_ADDR":            ":9443",
+			"REQUEST_TIMEOUT":        "20s",
+			"LOG_LEVEL":              "debug",
+			"ISA_TAX_YEAR_START_DAY": "1",
+			"FEATURE_METRICS":        "false",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, ":9443", cfg.Server.Addr)
+		assert.Equal(t, 30*time.Second, cfg.Server.WriteTimeout.Duration)
+		assert.Equal(t, 20*time.Second, cfg.Server.RequestTimeout.Duration)
+		assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout.Duration)
+		assert.Equal(t, "jwks.json", cfg.Auth.JWKSPath)
+		assert.Equal(t, slog.LevelDebug, cfg.Logging.Level)
+		assert.Equal(t, domain.AllowanceRules{AnnualLimit: 2500000, TaxYearStartMonth: time.April, TaxYearStartDay: 1}, cfg.Allowance.Rules())
+		assert.False(t, cfg.Features.Recommendations)
+		assert.False(t, cfg.Features.Metrics)
+		assert.True(t, cfg.Features.PlanScheduler)
+	})
+
+	t.Run("Unknown settings in the file are rejected", func(t *testing.T) {
+		path := writeConfig(t, `{"server": {"adr": ":9000"}}`)
+		_, err := config.Load(path, env(map[string]string{"AUTH_DISABLED": "true"}))
+		assert.ErrorContains(t, err, `unknown field "adr"`)
+	})
+
+	t.Run("Unparseable environment variables are rejected", func(t *testing.T) {
+		_, err := config.Load("", env(map[string]string{"AUTH_DISABLED": "true", "READ_TIMEOUT": "15"}))
+		assert.ErrorContains(t, err, "READ_TIMEOUT")
+	})
+
+	t.Run("Every invalid setting is reported", func(t *testing.T) {
+		_, err := config.Load("", env(map[string]string{
+			"REQUEST_TIMEOUT":        "1m",
+			"TLS_CERT_FILE":          "cert.pem",
+			"STORAGE_BACKEND":        "postgres",
+			"OTEL_TRACES_EXPORTER":   "zipkin",
+			"ISA_ANNUAL_LIMIT_PENCE": "0",
+			"ISA_TAX_YEAR_START_DAY": "31",
+		}))
+		require.Error(t, err)
+		for _, problem := range []string{
+			"server.request_timeout",
+			"server.tls needs both",
+			`storage.backend "postgres"`,
+			"auth.jwks_path",
+			`tracing.exporter "zipkin"`,
+			"allowance.annual_limit_pence",
+			"allowance.tax_year_start_day",
+		} {
+			assert.ErrorContains(t, err, problem)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// AllowanceRules are the ISA rules subscriptions are checked against
+type AllowanceRules struct {
+	// AnnualLimit is the most a customer may subscribe in a tax year, in pence
+	AnnualLimit int64
+	// The tax year starts on TaxYearStartDay of TaxYearStartMonth
+	TaxYearStartMonth time.Month
+	TaxYearStartDay   int
+}
+
+// DefaultAllowanceRules are the UK ISA rules: £20,000 a tax year, from 6 April to 5 April
+var DefaultAllowanceRules = AllowanceRules{
+	AnnualLimit:       2000000,
+	TaxYearStartMonth: time.April,
+	TaxYearStartDay:   6,
+}
+
+// TaxYearStart returns the start of the tax year containing t
+func (r AllowanceRules) TaxYearStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), r.TaxYearStartMonth, r.TaxYearStartDay, 0, 0, 0, 0, t.Location())
+	if t.Before(start) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+// Exceeded is the ErrAllowanceExceeded error for these rules, giving the limit
+func (r AllowanceRules) Exceeded() error {
+	return fmt.Errorf("%w of %s", ErrAllowanceExceeded, formatPounds(r.AnnualLimit))
+}
+
+// formatPounds formats an amount in pence as pounds, such as £20,000 or £1,234.50
+func formatPounds(pence int64) string {
+	pounds := fmt.Sprint(pence / 100)
+	for i := len(pounds) - 3; i > 0; i -= 3 {
+		pounds = pounds[:i] + "," + pounds[i:]
+	}
+	if pence%100 != 0 {
+		return fmt.Sprintf("£%s.%02d", pounds, pence%100)
+	}
+	return "£" + pounds
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// APIKeyStatus represents whether an API key can still be used
+type APIKeyStatus string
+
+const (
+	APIKeyStatusActive  APIKeyStatus = "active"
+	APIKeyStatusRevoked APIKeyStatus = "revoked"
+)
+
+// APIKey lets an employer partner call the API server to server, for the
+// customers linked to it. Only a hash of the key's secret is kept, but the
+// signing secret is kept as issued since it is needed to check signatures.
+type APIKey struct {
+	ID        string   `json:"id"`
+	PartnerID string   `json:"partner_id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	// CustomerIDs are the partner's employees the key can act for
+	CustomerIDs []string `json:"customer_ids"`
+	// SignatureRequired refuses requests made with the key that are not HMAC signed
+	SignatureRequired bool         `json:"signature_required"`
+	Status            APIKeyStatus `json:"status"`
+	SecretHash        string       `json:"-"`
+	SigningSecret     string       `json:"-"`
+	CreatedBy         string       `json:"created_by"`
+	CreatedAt         time.Time    `json:"created_at"`
+	// ExpiresAt is when a key that has been rotated stops working
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ReplacedBy is the key issued when this one was rotated
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Version    int64      `json:"version"`
+}
+
+// Usable reports whether requests can be made with the key at now
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.Status == APIKeyStatusActive && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// APIKeySettings are what a partner's API key is issued with
+type APIKeySettings struct {
+	Name              string   `json:"name"`
+	Scopes            []string `json:"scopes"`
+	CustomerIDs       []string `json:"customer_ids"`
+	SignatureRequired bool     `json:"signature_required"`
+}
+
+// IssuedAPIKey is a newly issued API key with its secrets, which are only
+// returned when the key is issued
+type IssuedAPIKey struct {
+	*APIKey
+	// Key is sent in the X-API-Key header
+	Key string `json:"key"`
+	// SigningSecret is the HMAC key requests made with the key are signed with
+	SigningSecret string `json:"signing_secret"`
+}
+
+// APIKeyRepository defines methods to interact with API keys
+type APIKeyRepository interface {
+	GetByID(ctx context.Context, id string) (*APIKey, error)
+	// GetByPartnerID returns the partner's keys, oldest first
+	GetByPartnerID(ctx context.Context, partnerID string) ([]*APIKey, error)
+	Create(ctx context.Context, key *APIKey) error
+	Update(ctx context.Context, key *APIKey) error
+}
+
+// APIKeyService defines business logic for partners' API keys
+type APIKeyService interface {
+	IssueAPIKey(ctx context.Context, partnerID string, settings APIKeySettings) (*IssuedAPIKey, error)
+	// RotateAPIKey issues a replacement for a key at expectedVersion. The old key
+	// keeps working for a grace period so the partner can change over.
+	RotateAPIKey(ctx context.Context, id string, expectedVersion int64) (*IssuedAPIKey, error)
+	// RevokeAPIKey stops a key at expectedVersion working straight away
+	RevokeAPIKey(ctx context.Context, id string, expectedVersion int64) (*APIKey, error)
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	GetPartnerAPIKeys(ctx context.Context, partnerID string) ([]*APIKey, error)
+	// AuthenticateAPIKey finds the usable key a presented X-API-Key header is for
+	AuthenticateAPIKey(ctx context.Context, presented string) (*APIKey, error)
+}
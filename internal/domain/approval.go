@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ApprovalAction is a sensitive change that needs a second person's approval
+type ApprovalAction string
+
+const (
+	// ApprovalActionCancelInvestment cancels a processed investment, selling its units and refunding the cash
+	ApprovalActionCancelInvestment ApprovalAction = "investment.cancel"
+	// ApprovalActionUpdateCustomer changes a customer's name and email address
+	ApprovalActionUpdateCustomer ApprovalAction = "customer.update"
+)
+
+// ApprovalStatus represents where an approval is in the four-eyes workflow
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "pending"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
+	// ApprovalStatusFailed is an approved change that could not be applied, such
+	// as one whose entity changed after it was requested
+	ApprovalStatusFailed ApprovalStatus = "failed"
+)
+
+// Approval is a request to make a sensitive change, held until a second user
+// approves or rejects it. The change is made as it was requested: it fails if
+// the entity is no longer at EntityVersion when it is approved.
+type Approval struct {
+	ID              string           `json:"id"`
+	Action          ApprovalAction   `json:"action"`
+	EntityID        string           `json:"entity_id"`
+	EntityVersion   int64            `json:"entity_version"`
+	CustomerID      string           `json:"customer_id"`
+	CustomerDetails *CustomerDetails `json:"customer_details,omitempty"`
+	Reason          string           `json:"reason"`
+	Status          ApprovalStatus   `json:"status"`
+	RequestedBy     string           `json:"requested_by"`
+	RequestedAt     time.Time        `json:"requested_at"`
+	DecidedBy       string           `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time       `json:"decided_at,omitempty"`
+	DecisionNote    string           `json:"decision_note,omitempty"`
+	Error           string           `json:"error,omitempty"`
+	Version         int64            `json:"version"`
+}
+
+// ApprovalRepository defines methods to interact with approvals
+type ApprovalRepository interface {
+	GetByID(ctx context.Context, id string) (*Approval, error)
+	// List returns the approvals with the status, or every approval when it is
+	// empty, oldest first
+	List(ctx context.Context, status ApprovalStatus) ([]*Approval, error)
+	Create(ctx context.Context, approval *Approval) error
+	Update(ctx context.Context, approval *Approval) error
+}
+
+// ApprovalService defines business logic for the four-eyes approval of sensitive changes
+type ApprovalService interface {
+	// RequestInvestmentCancellation asks for a processed investment at expectedVersion to be cancelled
+	RequestInvestmentCancellation(ctx context.Context, investmentID string, expectedVersion int64, reason string) (*Approval, error)
+	// RequestCustomerUpdate asks for a customer at expectedVersion to be given new details
+	RequestCustomerUpdate(ctx context.Context, customerID string, details CustomerDetails, expectedVersion int64, reason string) (*Approval, error)
+	// Approve makes the requested change. The caller must not be the requester.
+	Approve(ctx context.Context, id string) (*Approval, error)
+	// Reject closes the request without making the change
+	Reject(ctx context.Context, id, note string) (*Approval, error)
+	GetApproval(ctx context.Context, id string) (*Approval, error)
+	ListApprovals(ctx context.Context, status ApprovalStatus) ([]*Approval, error)
+}
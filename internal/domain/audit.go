@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AnonymousActor is recorded as the actor of changes made without an authenticated caller
+const AnonymousActor = "anonymous"
+
+// AuditEntry records one change made through the service layer. Entries form a
+// hash chain: each entry's hash covers its contents and the previous entry's
+// hash, so editing, removing or reordering entries breaks the chain.
+type AuditEntry struct {
+	Sequence   int64           `json:"sequence"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Actor      string          `json:"actor"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// ComputeHash returns the hash the entry should have given its contents and PrevHash
+func (e *AuditEntry) ComputeHash() string {
+	contents := *e
+	contents.Hash = ""
+	data, _ := json.Marshal(contents)
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks entries form an unbroken hash chain from the first entry,
+// returning ErrAuditChainBroken for the first entry that does not fit
+func VerifyAuditChain(entries []*AuditEntry) error {
+	prevHash := ""
+	for i, entry := range entries {
+		switch {
+		case entry.Sequence != int64(i+1):
+			return fmt.Errorf("%w: entry %d has sequence %d", ErrAuditChainBroken, i+1, entry.Sequence)
+		case entry.PrevHash != prevHash:
+			return fmt.Errorf("%w: entry %d does not follow entry %d", ErrAuditChainBroken, entry.Sequence, entry.Sequence-1)
+		case entry.Hash != entry.ComputeHash():
+			return fmt.Errorf("%w: entry %d has been altered", ErrAuditChainBroken, entry.Sequence)
+		}
+		prevHash = entry.Hash
+	}
+	return nil
+}
+
+// AuditRepository is an append-only store of audit entries
+type AuditRepository interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	// GetAll returns every entry in sequence order
+	GetAll(ctx context.Context) ([]*AuditEntry, error)
+}
+
+// AuditService records changes in the tamper-evident audit log
+type AuditService interface {
+	// Record adds an entry for a change to an entity, with snapshots of it before and after.
+	// A nil snapshot is left out, as for the before of a newly created entity.
+	Record(ctx context.Context, actor, action, entityType, entityID string, before, after interface{}) (*AuditEntry, error)
+	// Enqueue adds an entry for a change that has already been made. It never fails:
+	// an entry that cannot be written yet stays queued, in order, and is written
+	// by the next Record, Enqueue or Flush.
+	Enqueue(ctx context.Context, actor, action, entityType, entityID string, before, after interface{})
+	// Flush writes any queued entries, returning why some are still queued
+	Flush(ctx context.Context) error
+	// Queued reports how many entries are still queued and why the last attempt
+	// to write them failed, or nil when none are. It never writes anything.
+	Queued() error
+	GetEntries(ctx context.Context) ([]*AuditEntry, error)
+	// Verify checks the stored log has not been tampered with
+	Verify(ctx context.Context) error
+}
@@ -1,19 +1,51 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Customer represents a retail customer who can make ISA investments
 type Customer struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	RiskScore      int        `json:"risk_score,omitempty"`
+	RiskTolerance  RiskLevel  `json:"risk_tolerance,omitempty"`
+	RiskProfiledAt *time.Time `json:"risk_profiled_at,omitempty"`
+	Version        int64      `json:"version"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// CustomerDetails are the contact details of a customer that back-office staff can change
+type CustomerDetails struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// RiskQuestion is a single question in the risk profiling questionnaire
+type RiskQuestion struct {
+	ID      string   `json:"id"`
+	Text    string   `json:"text"`
+	Options []string `json:"options"`
 }
 
 // CustomerRepository defines methods to interact with customers
 type CustomerRepository interface {
-	GetByID(id string) (*Customer, error)
-	Create(customer *Customer) error
-	Update(customer *Customer) error
+	GetByID(ctx context.Context, id string) (*Customer, error)
+	Create(ctx context.Context, customer *Customer) error
+	Update(ctx context.Context, customer *Customer) error
+}
+
+// CustomerService defines business logic for customers
+type CustomerService interface {
+	GetCustomer(ctx context.Context, id string) (*Customer, error)
+	GetRiskQuestionnaire(ctx context.Context) []RiskQuestion
+	// SubmitRiskQuestionnaire scores the answers (question ID to chosen option index)
+	// and stores the resulting risk tolerance on the customer
+	SubmitRiskQuestionnaire(ctx context.Context, customerID string, answers map[string]int) (*Customer, error)
+	// UpdateCustomerDetails changes the customer's name and email address,
+	// failing with ErrConflict if they are no longer at expectedVersion
+	UpdateCustomerDetails(ctx context.Context, customerID string, details CustomerDetails, expectedVersion int64) (*Customer, error)
 }
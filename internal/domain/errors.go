@@ -0,0 +1,33 @@
+package domain
+
+import "errors"
+
+// Domain errors returned by services so callers can react to specific failures
+var (
+	ErrAllowanceExceeded       = errors.New("investment exceeds ISA annual limit")
+	ErrInsufficientHoldings    = errors.New("insufficient holdings in fund")
+	ErrSameFundSwitch          = errors.New("cannot switch into the same fund")
+	ErrFundNotOpen             = errors.New("fund is not open to new money")
+	ErrFundSuspended           = errors.New("dealing in fund is suspended")
+	ErrInvalidFundStatusChange = errors.New("fund status cannot be changed from its current status")
+	// ErrConflict is returned when updating an entity whose version is stale
+	ErrConflict                      = errors.New("entity has been changed by someone else")
+	ErrInvalidCursor                 = errors.New("invalid pagination cursor")
+	ErrRiskProfileRequired           = errors.New("customer has not completed the risk questionnaire")
+	ErrRiskNotAcknowledged           = errors.New("fund risk level exceeds customer risk tolerance and must be acknowledged")
+	ErrInvestmentNotCancellable      = errors.New("investment cannot be cancelled")
+	ErrUnbalancedJournal             = errors.New("journal does not balance")
+	ErrInsufficientCash              = errors.New("insufficient cash")
+	ErrAuditChainBroken              = errors.New("audit log has been tampered with")
+	ErrInvalidInvestmentStatusChange = errors.New("investment cannot make that change from its current status")
+	// ErrForbidden is returned when the caller's roles do not allow the operation
+	ErrForbidden = errors.New("forbidden")
+	// ErrApprovalRequired is returned for changes that must go through the four-eyes approval workflow
+	ErrApprovalRequired = errors.New("change requires approval by a second user")
+	// ErrSelfApproval is returned when a user tries to decide on their own request
+	ErrSelfApproval       = errors.New("approvals must be decided by someone other than the requester")
+	ErrApprovalNotPending = errors.New("approval has already been decided")
+	// ErrInvalidAPIKey is returned for API keys that are unknown, revoked or expired
+	ErrInvalidAPIKey   = errors.New("invalid API key")
+	ErrAPIKeyNotActive = errors.New("API key has been revoked or replaced")
+)
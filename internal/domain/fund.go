@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // RiskLevel represents the risk level of a fund
 type RiskLevel string
@@ -11,24 +14,134 @@ const (
 	RiskLevelHigh   RiskLevel = "high"
 )
 
+var riskLevelRanks = map[RiskLevel]int{
+	RiskLevelLow:    1,
+	RiskLevelMedium: 2,
+	RiskLevelHigh:   3,
+}
+
+// Valid reports whether the risk level is one of the known levels
+func (r RiskLevel) Valid() bool {
+	_, ok := riskLevelRanks[r]
+	return ok
+}
+
+// Rank orders risk levels from 1 (low) to 3 (high), returning 0 for unknown levels
+func (r RiskLevel) Rank() int {
+	return riskLevelRanks[r]
+}
+
+// Exceeds reports whether r is a higher risk than other
+func (r RiskLevel) Exceeds(other RiskLevel) bool {
+	return riskLevelRanks[r] > riskLevelRanks[other]
+}
+
+// FundStatus represents where a fund is in its lifecycle
+type FundStatus string
+
+const (
+	// FundStatusOpen funds accept new money and deal normally
+	FundStatusOpen FundStatus = "open"
+	// FundStatusSoftClosed funds are closed to new money but existing holders can still sell or switch out
+	FundStatusSoftClosed FundStatus = "soft_closed"
+	// FundStatusSuspended funds are gated: no dealing in or out until the suspension is lifted
+	FundStatusSuspended FundStatus = "suspended"
+	// FundStatusClosed funds are permanently closed to new money
+	FundStatusClosed FundStatus = "closed"
+)
+
+// AssetClass represents the main type of asset a fund invests in
+type AssetClass string
+
+const (
+	AssetClassEquity      AssetClass = "equity"
+	AssetClassFixedIncome AssetClass = "fixed_income"
+	AssetClassMultiAsset  AssetClass = "multi_asset"
+	AssetClassProperty    AssetClass = "property"
+	AssetClassCash        AssetClass = "cash"
+)
+
+// Valid reports whether the asset class is one of the known classes
+func (a AssetClass) Valid() bool {
+	switch a {
+	case AssetClassEquity, AssetClassFixedIncome, AssetClassMultiAsset, AssetClassProperty, AssetClassCash:
+		return true
+	}
+	return false
+}
+
+// FundSortField is a field the fund catalogue can be sorted by
+type FundSortField string
+
+const (
+	FundSortByName          FundSortField = "name"
+	FundSortByRiskLevel     FundSortField = "risk_level"
+	FundSortByOngoingCharge FundSortField = "ongoing_charge"
+	FundSortByCreatedAt     FundSortField = "created_at"
+)
+
+// FundFilter narrows, orders and pages the funds returned when listing the catalogue
+type FundFilter struct {
+	// Query matches funds whose name or description contain every word
+	Query string
+	// Statuses to include; when empty only open funds are returned
+	Statuses     []FundStatus
+	RiskLevels   []RiskLevel
+	AssetClasses []AssetClass
+	// MaxOngoingChargeBps excludes funds charging more, when set
+	MaxOngoingChargeBps int
+	// SortBy defaults to name; ties are broken by ID so ordering is stable
+	SortBy   FundSortField
+	SortDesc bool
+	// Limit of 0 returns every matching fund
+	Limit  int
+	Offset int
+}
+
 // Fund represents an investment fund that customers can invest in
 type Fund struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	RiskLevel   RiskLevel `json:"risk_level"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	RiskLevel        RiskLevel  `json:"risk_level"`
+	AssetClass       AssetClass `json:"asset_class"`
+	OngoingChargeBps int        `json:"ongoing_charge_bps"`
+	Status           FundStatus `json:"status"`
+	Version          int64      `json:"version"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// FundDetails are the fields of a fund that can be set through the catalogue administration API
+type FundDetails struct {
+	Name        string
+	Description string
+	RiskLevel   RiskLevel
+	AssetClass  AssetClass
+	// OngoingChargeBps is the annual ongoing charge in basis points (e.g. 22 = 0.22%)
+	OngoingChargeBps int
 }
 
 // FundRepository defines methods to interact with funds
 type FundRepository interface {
-	GetByID(id string) (*Fund, error)
-	GetAll() ([]*Fund, error)
+	GetByID(ctx context.Context, id string) (*Fund, error)
+	GetAll(ctx context.Context) ([]*Fund, error)
+	// Find returns one page of funds matching the filter and the total number that match
+	Find(ctx context.Context, filter FundFilter) ([]*Fund, int, error)
+	Create(ctx context.Context, fund *Fund) error
+	Update(ctx context.Context, fund *Fund) error
 }
 
-// FundService defines business logic for funds
+// FundService defines business logic for funds.
+// Changes take the version the caller last saw and fail with ErrConflict if the fund has moved on.
 type FundService interface {
-	GetFund(id string) (*Fund, error)
-	ListFunds() ([]*Fund, error)
+	GetFund(ctx context.Context, id string) (*Fund, error)
+	// ListFunds returns one page of funds matching the filter and the total number that match
+	ListFunds(ctx context.Context, filter FundFilter) ([]*Fund, int, error)
+	CreateFund(ctx context.Context, details FundDetails) (*Fund, error)
+	UpdateFund(ctx context.Context, id string, details FundDetails, expectedVersion int64) (*Fund, error)
+	SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*Fund, error)
+	SuspendFund(ctx context.Context, id string, expectedVersion int64) (*Fund, error)
+	CloseFund(ctx context.Context, id string, expectedVersion int64) (*Fund, error)
+	ReopenFund(ctx context.Context, id string, expectedVersion int64) (*Fund, error)
 }
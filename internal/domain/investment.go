@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+)
 
 // InvestmentStatus represents the status of an investment
 type InvestmentStatus string
@@ -9,30 +14,136 @@ const (
 	InvestmentStatusPending   InvestmentStatus = "pending"
 	InvestmentStatusProcessed InvestmentStatus = "processed"
 	InvestmentStatusCancelled InvestmentStatus = "cancelled"
+	InvestmentStatusWithdrawn InvestmentStatus = "withdrawn"
+)
+
+// InvestmentType distinguishes new subscriptions from the legs of a fund switch
+type InvestmentType string
+
+const (
+	InvestmentTypeSubscription InvestmentType = "subscription"
+	InvestmentTypeSwitchOut    InvestmentType = "switch_out"
+	InvestmentTypeSwitchIn     InvestmentType = "switch_in"
 )
 
 // Investment represents a customer's investment in a fund
 type Investment struct {
-	ID         string           `json:"id"`
-	CustomerID string           `json:"customer_id"`
-	FundID     string           `json:"fund_id"`
-	Amount     int64            `json:"amount"`
-	Status     InvestmentStatus `json:"status"`
-	CreatedAt  time.Time        `json:"created_at"`
-	UpdatedAt  time.Time        `json:"updated_at"`
+	ID               string           `json:"id"`
+	CustomerID       string           `json:"customer_id"`
+	FundID           string           `json:"fund_id"`
+	Amount           int64            `json:"amount"`
+	Type             InvestmentType   `json:"type"`
+	SwitchID         string           `json:"switch_id,omitempty"`
+	RiskAcknowledged bool             `json:"risk_acknowledged,omitempty"`
+	UnitPrice        int64            `json:"unit_price,omitempty"` // pence per unit, set once priced
+	Units            int64            `json:"units,omitempty"`      // thousandths of a unit
+	Status           InvestmentStatus `json:"status"`
+	Version          int64            `json:"version"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+// InvestmentCursor marks the last investment on a page. Pages are ordered by
+// creation time then ID, so the next page starts strictly after this position.
+type InvestmentCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// After reports whether the investment comes after the cursor position
+func (c InvestmentCursor) After(investment *Investment) bool {
+	if !investment.CreatedAt.Equal(c.CreatedAt) {
+		return investment.CreatedAt.After(c.CreatedAt)
+	}
+	return investment.ID > c.ID
+}
+
+// Encode returns the opaque form of the cursor handed to API clients
+func (c InvestmentCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeInvestmentCursor parses a cursor produced by InvestmentCursor.Encode
+func DecodeInvestmentCursor(s string) (InvestmentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return InvestmentCursor{}, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return InvestmentCursor{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return InvestmentCursor{}, ErrInvalidCursor
+	}
+
+	return InvestmentCursor{CreatedAt: t, ID: id}, nil
+}
+
+// InvestmentFilter selects a customer's investments from a repository, ordered
+// by creation time then ID. Zero values leave a criterion unrestricted.
+type InvestmentFilter struct {
+	CustomerID string
+	Statuses   []InvestmentStatus
+	FundID     string
+	// CreatedFrom is inclusive and CreatedTo exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// After skips investments up to and including the cursor position
+	After *InvestmentCursor
+	Limit int
+}
+
+// InvestmentQuery is a request for one page of a customer's investments
+type InvestmentQuery struct {
+	CustomerID  string
+	Statuses    []InvestmentStatus
+	FundID      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+}
+
+// InvestmentPage is one page of investments and the cursor for the next page,
+// which is empty on the last page
+type InvestmentPage struct {
+	Investments []*Investment
+	NextCursor  string
 }
 
 // InvestmentRepository defines methods to interact with investments
 type InvestmentRepository interface {
-	GetByID(id string) (*Investment, error)
-	GetByCustomerID(customerID string) ([]*Investment, error)
-	Create(investment *Investment) error
-	Update(investment *Investment) error
+	GetByID(ctx context.Context, id string) (*Investment, error)
+	GetByCustomerID(ctx context.Context, customerID string) ([]*Investment, error)
+	GetByFundID(ctx context.Context, fundID string) ([]*Investment, error)
+	Find(ctx context.Context, filter InvestmentFilter) ([]*Investment, error)
+	// OldestUnpriced gets the longest-waiting pending investment without a unit
+	// price, or nil when every pending investment has been priced
+	OldestUnpriced(ctx context.Context) (*Investment, error)
+	Create(ctx context.Context, investment *Investment) error
+	Update(ctx context.Context, investment *Investment) error
 }
 
-// InvestmentService defines business logic for investments
+// InvestmentService defines business logic for investments.
+// Every change to an investment is recorded as an InvestmentEvent.
 type InvestmentService interface {
-	CreateInvestment(customerID, fundID string, amount int64) (*Investment, error)
-	GetInvestment(id string) (*Investment, error)
-	GetCustomerInvestments(customerID string) ([]*Investment, error)
+	CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*Investment, error)
+	GetInvestment(ctx context.Context, id string) (*Investment, error)
+	GetCustomerInvestments(ctx context.Context, customerID string) ([]*Investment, error)
+	ListCustomerInvestments(ctx context.Context, query InvestmentQuery) (*InvestmentPage, error)
+	// CancelInvestment cancels an investment, failing with ErrConflict if it is no longer at expectedVersion
+	CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*Investment, error)
+	// PriceInvestment sets the unit price a pending investment deals at, in pence per unit
+	PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*Investment, error)
+	// ProcessInvestment settles a priced investment
+	ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*Investment, error)
+	// WithdrawInvestment takes a processed holding out of the ISA
+	WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*Investment, error)
+	// GetInvestmentEvents returns the full history of an investment, oldest first
+	GetInvestmentEvents(ctx context.Context, id string) ([]*InvestmentEvent, error)
 }
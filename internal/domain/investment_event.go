@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// InvestmentEventType identifies what happened to an investment
+type InvestmentEventType string
+
+const (
+	InvestmentEventCreated   InvestmentEventType = "created"
+	InvestmentEventPriced    InvestmentEventType = "priced"
+	InvestmentEventProcessed InvestmentEventType = "processed"
+	InvestmentEventCancelled InvestmentEventType = "cancelled"
+	InvestmentEventWithdrawn InvestmentEventType = "withdrawn"
+)
+
+// InvestmentEvent is an immutable record of one change to an investment.
+// Replaying an investment's events in sequence rebuilds its current state.
+//
+// Sequence numbers an investment's events from 1 and matches the version each
+// event produced. Created events carry the investment's details and priced
+// events its unit price and units; other fields are left empty.
+type InvestmentEvent struct {
+	ID               string              `json:"id"`
+	InvestmentID     string              `json:"investment_id"`
+	Sequence         int64               `json:"sequence"`
+	Type             InvestmentEventType `json:"type"`
+	CustomerID       string              `json:"customer_id,omitempty"`
+	FundID           string              `json:"fund_id,omitempty"`
+	Amount           int64               `json:"amount,omitempty"`
+	InvestmentType   InvestmentType      `json:"investment_type,omitempty"`
+	SwitchID         string              `json:"switch_id,omitempty"`
+	RiskAcknowledged bool                `json:"risk_acknowledged,omitempty"`
+	UnitPrice        int64               `json:"unit_price,omitempty"`
+	Units            int64               `json:"units,omitempty"`
+	OccurredAt       time.Time           `json:"occurred_at"`
+}
+
+// Apply updates the investment with the change recorded by the event
+func (i *Investment) Apply(event *InvestmentEvent) {
+	switch event.Type {
+	case InvestmentEventCreated:
+		i.ID = event.InvestmentID
+		i.CustomerID = event.CustomerID
+		i.FundID = event.FundID
+		i.Amount = event.Amount
+		i.Type = event.InvestmentType
+		i.SwitchID = event.SwitchID
+		i.RiskAcknowledged = event.RiskAcknowledged
+		i.Status = InvestmentStatusPending
+		i.CreatedAt = event.OccurredAt
+	case InvestmentEventPriced:
+		i.UnitPrice = event.UnitPrice
+		i.Units = event.Units
+	case InvestmentEventProcessed:
+		i.Status = InvestmentStatusProcessed
+	case InvestmentEventCancelled:
+		i.Status = InvestmentStatusCancelled
+	case InvestmentEventWithdrawn:
+		i.Status = InvestmentStatusWithdrawn
+	}
+	i.UpdatedAt = event.OccurredAt
+}
+
+// ReplayInvestment rebuilds an investment from its events, which must start
+// with the created event and be in sequence order
+func ReplayInvestment(events []*InvestmentEvent) (*Investment, error) {
+	if len(events) == 0 || events[0].Type != InvestmentEventCreated {
+		return nil, errors.New("investment history must start with a created event")
+	}
+
+	investment := &Investment{}
+	for i, event := range events {
+		if event.Sequence != int64(i+1) {
+			return nil, errors.New("investment history is out of sequence")
+		}
+		investment.Apply(event)
+		investment.Version = event.Sequence
+	}
+
+	return investment, nil
+}
+
+// InvestmentEventRepository is an append-only store of investment events
+type InvestmentEventRepository interface {
+	// Append stores the event as the next in its investment's sequence. The
+	// event's Sequence must be the next number in the sequence, or Append fails
+	// with ErrConflict, so two changes made from the same version cannot both
+	// be recorded.
+	Append(ctx context.Context, event *InvestmentEvent) error
+	// GetByInvestmentID returns an investment's events in sequence order
+	GetByInvestmentID(ctx context.Context, investmentID string) ([]*InvestmentEvent, error)
+}
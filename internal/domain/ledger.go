@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JournalType describes the business event a journal records
+type JournalType string
+
+const (
+	JournalTypeSubscription JournalType = "subscription"
+	JournalTypeDealing      JournalType = "dealing"
+	JournalTypeFee          JournalType = "fee"
+	JournalTypeDividend     JournalType = "dividend"
+	JournalTypeWithdrawal   JournalType = "withdrawal"
+	// JournalTypeRefund returns the cash of a cancelled subscription
+	JournalTypeRefund JournalType = "refund"
+)
+
+// LedgerAccount identifies an account in the ledger
+type LedgerAccount string
+
+// ClientMoneyAccount is the pooled client money bank account holding customers' cash
+const ClientMoneyAccount LedgerAccount = "client_money"
+
+// CustomerCashAccount is the uninvested cash the firm owes a customer
+func CustomerCashAccount(customerID string) LedgerAccount {
+	return LedgerAccount("customer:" + customerID + ":cash")
+}
+
+// CustomerUnitsAccount is the units of a fund a customer holds
+func CustomerUnitsAccount(customerID, fundID string) LedgerAccount {
+	return LedgerAccount(customerUnitsPrefix(customerID) + fundID)
+}
+
+// customerUnitsPrefix starts the name of every units account of a customer
+func customerUnitsPrefix(customerID string) string {
+	return "customer:" + customerID + ":units:"
+}
+
+// FundAccount is the fund's side of dealing, issuing and cancelling its units
+func FundAccount(fundID string) LedgerAccount {
+	return LedgerAccount("fund:" + fundID)
+}
+
+// LedgerAsset is what an amount is measured in
+type LedgerAsset string
+
+// LedgerAssetGBP amounts are in pence
+const LedgerAssetGBP LedgerAsset = "GBP"
+
+// FundUnits is the asset for units of a fund, with amounts in thousandths of a unit
+func FundUnits(fundID string) LedgerAsset {
+	return LedgerAsset("units:" + fundID)
+}
+
+// Posting is one side of a journal. Positive amounts are debits and negative
+// amounts credits, so a customer's cash account is in credit while the firm
+// owes them money.
+type Posting struct {
+	Account LedgerAccount `json:"account"`
+	Asset   LedgerAsset   `json:"asset"`
+	Amount  int64         `json:"amount"`
+}
+
+// Journal is a set of postings made together. Journals must balance: the
+// postings in each asset sum to zero. Reference links the journal to what
+// caused it, such as an investment ID.
+type Journal struct {
+	ID         string      `json:"id"`
+	Type       JournalType `json:"type"`
+	CustomerID string      `json:"customer_id"`
+	Reference  string      `json:"reference,omitempty"`
+	Postings   []Posting   `json:"postings"`
+	PostedAt   time.Time   `json:"posted_at"`
+}
+
+// Validate checks the journal has postings that balance in every asset
+func (j *Journal) Validate() error {
+	if len(j.Postings) < 2 {
+		return fmt.Errorf("%w: a journal needs at least two postings", ErrUnbalancedJournal)
+	}
+
+	totals := make(map[LedgerAsset]int64)
+	for _, posting := range j.Postings {
+		if posting.Account == "" || posting.Asset == "" || posting.Amount == 0 {
+			return fmt.Errorf("%w: postings need an account, an asset and a non-zero amount", ErrUnbalancedJournal)
+		}
+		totals[posting.Asset] += posting.Amount
+	}
+	for asset, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("%w: %s postings are out by %d", ErrUnbalancedJournal, asset, total)
+		}
+	}
+
+	return nil
+}
+
+// CustomerBalances are a customer's positions in the ledger at a point in time
+type CustomerBalances struct {
+	CustomerID string
+	// Cash is the uninvested cash owed to the customer, in pence
+	Cash int64
+	// Reserved is the part of Cash brought in by subscriptions that have not
+	// yet been dealt or refunded, in pence. It is spent when they are, so
+	// cannot be spent on anything else.
+	Reserved int64
+	// Units held in each fund by fund ID, in thousandths of a unit
+	Units map[string]int64
+	At    time.Time
+
+	// reserved holds the cash of each subscription still waiting to be dealt, by investment ID
+	reserved map[string]int64
+}
+
+// NewCustomerBalances totals a customer's cash and units from their journals posted up to and including at
+func NewCustomerBalances(customerID string, journals []*Journal, at time.Time) *CustomerBalances {
+	balances := &CustomerBalances{CustomerID: customerID, Units: make(map[string]int64), At: at, reserved: make(map[string]int64)}
+	for _, journal := range journals {
+		if journal.PostedAt.After(at) {
+			continue
+		}
+		balances.Add(journal)
+	}
+
+	return balances
+}
+
+// Add adds the journal's postings to the customer's accounts to the balances.
+// A subscription's cash is reserved until a dealing or refund journal for the
+// same investment spends it.
+func (b *CustomerBalances) Add(journal *Journal) {
+	cash := CustomerCashAccount(b.CustomerID)
+	unitsPrefix := customerUnitsPrefix(b.CustomerID)
+
+	var cashIn int64
+	for _, posting := range journal.Postings {
+		switch {
+		case posting.Account == cash:
+			// Cash owed to the customer is a credit balance
+			b.Cash -= posting.Amount
+			cashIn -= posting.Amount
+		case strings.HasPrefix(string(posting.Account), unitsPrefix):
+			b.Units[strings.TrimPrefix(string(posting.Account), unitsPrefix)] += posting.Amount
+		}
+	}
+
+	switch journal.Type {
+	case JournalTypeSubscription:
+		if b.reserved == nil {
+			b.reserved = make(map[string]int64)
+		}
+		b.reserved[journal.Reference] += cashIn
+		b.Reserved += cashIn
+	case JournalTypeDealing, JournalTypeRefund:
+		b.Reserved -= b.reserved[journal.Reference]
+		delete(b.reserved, journal.Reference)
+	}
+}
+
+// Available is the cash the customer has that is not reserved for subscriptions waiting to be dealt
+func (b *CustomerBalances) Available() int64 {
+	return b.Cash - b.Reserved
+}
+
+// CheckInCredit checks the customer is not owed negative cash or units,
+// returning ErrInsufficientCash or ErrInsufficientHoldings if they are
+func (b *CustomerBalances) CheckInCredit() error {
+	if b.Cash < 0 {
+		return fmt.Errorf("%w: short by %d", ErrInsufficientCash, -b.Cash)
+	}
+	for fundID, units := range b.Units {
+		if units < 0 {
+			return fmt.Errorf("%w: short of %d units of %s", ErrInsufficientHoldings, -units, fundID)
+		}
+	}
+	return nil
+}
+
+// LedgerRepository is an append-only store of journals
+type LedgerRepository interface {
+	Create(ctx context.Context, journal *Journal) error
+	// GetByAccount returns the journals with a posting to the account, oldest first
+	GetByAccount(ctx context.Context, account LedgerAccount) ([]*Journal, error)
+	// GetByCustomerID returns a customer's journals, oldest first
+	GetByCustomerID(ctx context.Context, customerID string) ([]*Journal, error)
+}
+
+// LedgerService defines business logic for the double-entry cash and unit ledger
+type LedgerService interface {
+	// Post records a journal, refusing it with ErrUnbalancedJournal unless it balances
+	Post(ctx context.Context, journal *Journal) error
+	// PostWith records journals together with the change that causes them. The
+	// journals must balance and leave their customers in credit in cash and
+	// every fund's units, or they are refused with ErrUnbalancedJournal,
+	// ErrInsufficientCash or ErrInsufficientHoldings and apply is not run.
+	// Otherwise apply makes the change, and the journals are recorded once it
+	// succeeds. No other journal is posted in between.
+	PostWith(ctx context.Context, journals []*Journal, apply func(ctx context.Context) error) error
+	// Balance is the sum of the postings to an account in an asset up to and including at
+	Balance(ctx context.Context, account LedgerAccount, asset LedgerAsset, at time.Time) (int64, error)
+	GetCustomerBalances(ctx context.Context, customerID string, at time.Time) (*CustomerBalances, error)
+	GetCustomerJournals(ctx context.Context, customerID string) ([]*Journal, error)
+	// RecordFee takes a fee in pence from the customer's cash, refusing it with
+	// ErrInsufficientCash if it is more than the cash available
+	RecordFee(ctx context.Context, customerID string, amount int64) (*Journal, error)
+	// RecordDividend credits the customer's cash with a dividend in pence paid by a fund they hold
+	RecordDividend(ctx context.Context, customerID, fundID string, amount int64) (*Journal, error)
+}
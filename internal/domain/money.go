@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// ErrInvalidAmount is returned for amounts that are not pounds with up to two decimal places
+var ErrInvalidAmount = errors.New("amount must be pounds with up to two decimal places, such as 250 or 19.99")
+
+// ParsePence parses an amount in pounds, such as "19.99", exactly into pence.
+// Only digits with up to two decimal places are accepted, so signs, exponents,
+// NaN and Inf are refused, as are amounts too large to hold in pence.
+func ParsePence(amount string) (int64, error) {
+	pounds, fraction, hasFraction := strings.Cut(amount, ".")
+	if pounds == "" || (hasFraction && (fraction == "" || len(fraction) > 2)) {
+		return 0, ErrInvalidAmount
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	var pence int64
+	for _, digit := range pounds + fraction {
+		if digit < '0' || digit > '9' {
+			return 0, ErrInvalidAmount
+		}
+		if pence > (math.MaxInt64-int64(digit-'0'))/10 {
+			return 0, ErrInvalidAmount
+		}
+		pence = pence*10 + int64(digit-'0')
+	}
+	return pence, nil
+}
@@ -0,0 +1,30 @@
+package domain_test
+
+import (
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePence(t *testing.T) {
+	for amount, pence := range map[string]int64{
+		"0":                    0,
+		"0.29":                 29,
+		"1.15":                 115,
+		"4.35":                 435,
+		"19.99":                1999,
+		"0.57":                 57,
+		"250":                  25000,
+		"250.5":                25050,
+		"92233720368547758.07": 9223372036854775807,
+	} {
+		got, err := domain.ParsePence(amount)
+		assert.NoError(t, err, amount)
+		assert.Equal(t, pence, got, amount)
+	}
+
+	for _, amount := range []string{"", ".", ".5", "1.", "1.234", "-1", "+1", "1e3", "NaN", "Inf", "1,000", " 1", "92233720368547758.08"} {
+		_, err := domain.ParsePence(amount)
+		assert.ErrorIs(t, err, domain.ErrInvalidAmount, amount)
+	}
+}
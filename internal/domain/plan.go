@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// PlanStatus represents the status of a regular contribution plan
+type PlanStatus string
+
+const (
+	PlanStatusActive    PlanStatus = "active"
+	PlanStatusPaused    PlanStatus = "paused"
+	PlanStatusCancelled PlanStatus = "cancelled"
+)
+
+// PlanAllocation is the part of a plan's monthly contribution invested in one fund
+type PlanAllocation struct {
+	FundID string `json:"fund_id"`
+	Amount int64  `json:"amount"`
+}
+
+// Plan represents a recurring monthly contribution split across one or more
+// funds, collected on the same day each month in the style of a direct debit.
+// Amount is the total of the allocations.
+type Plan struct {
+	ID                string           `json:"id"`
+	CustomerID        string           `json:"customer_id"`
+	Allocations       []PlanAllocation `json:"allocations"`
+	Amount            int64            `json:"amount"`
+	DayOfMonth        int              `json:"day_of_month"`
+	RiskAcknowledged  bool             `json:"risk_acknowledged,omitempty"`
+	Status            PlanStatus       `json:"status"`
+	PauseReason       string           `json:"pause_reason,omitempty"`
+	NextRunAt         time.Time        `json:"next_run_at"`
+	LastRunAt         *time.Time       `json:"last_run_at,omitempty"`
+	LastInvestmentIDs []string         `json:"last_investment_ids,omitempty"`
+	LastError         string           `json:"last_error,omitempty"`
+	MissedRuns        int              `json:"missed_runs,omitempty"`
+	Version           int64            `json:"version"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+// PlanRepository defines methods to interact with regular contribution plans
+type PlanRepository interface {
+	GetByID(ctx context.Context, id string) (*Plan, error)
+	GetByCustomerID(ctx context.Context, customerID string) ([]*Plan, error)
+	GetDue(ctx context.Context, at time.Time) ([]*Plan, error)
+	Create(ctx context.Context, plan *Plan) error
+	Update(ctx context.Context, plan *Plan) error
+}
+
+// PlanService defines business logic for regular contribution plans.
+// An expectedVersion of 0 skips the optimistic concurrency check.
+type PlanService interface {
+	CreatePlan(ctx context.Context, customerID string, allocations []PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*Plan, error)
+	GetPlan(ctx context.Context, id string) (*Plan, error)
+	GetCustomerPlans(ctx context.Context, customerID string) ([]*Plan, error)
+	// UpdatePlan changes the amounts a plan invests in its funds, which cannot
+	// themselves be changed, its collection day or its status
+	UpdatePlan(ctx context.Context, id string, allocations []PlanAllocation, dayOfMonth int, status PlanStatus, expectedVersion int64) (*Plan, error)
+	CancelPlan(ctx context.Context, id string, expectedVersion int64) (*Plan, error)
+	RunDuePlans(ctx context.Context, at time.Time) error
+}
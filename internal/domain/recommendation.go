@@ -0,0 +1,29 @@
+package domain
+
+import "context"
+
+// FundRecommendation is a single ranked fund suggestion with the reasoning behind it
+type FundRecommendation struct {
+	FundID            string    `json:"fund_id"`
+	FundName          string    `json:"fund_name"`
+	RiskLevel         RiskLevel `json:"risk_level"`
+	Score             int       `json:"score"`
+	AllocationPercent int       `json:"allocation_percent"`
+	CurrentHolding    int64     `json:"current_holding"`
+	Reasons           []string  `json:"reasons"`
+}
+
+// Recommendations is the ranked set of funds suggested for a customer
+type Recommendations struct {
+	CustomerID    string               `json:"customer_id"`
+	RiskScore     int                  `json:"risk_score"`
+	RiskTolerance RiskLevel            `json:"risk_tolerance"`
+	HorizonYears  int                  `json:"horizon_years"`
+	TargetRisk    RiskLevel            `json:"target_risk"`
+	Funds         []FundRecommendation `json:"funds"`
+}
+
+// RecommendationService defines business logic for recommending funds to customers
+type RecommendationService interface {
+	GetRecommendations(ctx context.Context, customerID string, horizonYears int) (*Recommendations, error)
+}
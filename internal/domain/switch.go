@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// SwitchStatus represents the status of a fund switch
+type SwitchStatus string
+
+const (
+	SwitchStatusPending   SwitchStatus = "pending"
+	SwitchStatusCompleted SwitchStatus = "completed"
+	SwitchStatusFailed    SwitchStatus = "failed"
+)
+
+// Switch represents a customer moving money between two funds inside their ISA.
+// It is made up of a linked sell (switch_out) and buy (switch_in) investment and
+// does not count towards the customer's ISA allowance.
+type Switch struct {
+	ID               string       `json:"id"`
+	CustomerID       string       `json:"customer_id"`
+	FromFundID       string       `json:"from_fund_id"`
+	ToFundID         string       `json:"to_fund_id"`
+	Amount           int64        `json:"amount"`
+	SellInvestmentID string       `json:"sell_investment_id"`
+	BuyInvestmentID  string       `json:"buy_investment_id,omitempty"`
+	RiskAcknowledged bool         `json:"risk_acknowledged,omitempty"`
+	Status           SwitchStatus `json:"status"`
+	Version          int64        `json:"version"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// SwitchRepository defines methods to interact with fund switches
+type SwitchRepository interface {
+	GetByID(ctx context.Context, id string) (*Switch, error)
+	GetByCustomerID(ctx context.Context, customerID string) ([]*Switch, error)
+	Create(ctx context.Context, sw *Switch) error
+	Update(ctx context.Context, sw *Switch) error
+}
+
+// SwitchService defines business logic for fund switches
+type SwitchService interface {
+	SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*Switch, error)
+	GetSwitch(ctx context.Context, id string) (*Switch, error)
+}
@@ -0,0 +1,180 @@
+// Package health works out whether the service is ready for traffic from
+// checks on the things it depends on.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports why a dependency is unhealthy, or nil when it is healthy
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check
+type Result struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Advisory checks are reported without affecting readiness
+	Advisory bool   `json:"advisory,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the outcome of a readiness check
+type Report struct {
+	Ready    bool     `json:"ready"`
+	Draining bool     `json:"draining,omitempty"`
+	Checks   []Result `json:"checks"`
+}
+
+// ErrDraining is reported once the service has started shutting down
+var ErrDraining = errors.New("shutting down")
+
+// Checker aggregates named checks into readiness
+type Checker struct {
+	// Timeout limits how long each check may take
+	Timeout  time.Duration
+	mutex    sync.RWMutex
+	checks   map[string]Check
+	advisory map[string]bool
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker giving each check up to timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		Timeout:  timeout,
+		checks:   make(map[string]Check),
+		advisory: make(map[string]bool),
+	}
+}
+
+// Add adds a check readiness depends on, replacing any with the same name
+func (c *Checker) Add(name string, check Check) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[name] = check
+	delete(c.advisory, name)
+}
+
+// AddAdvisory adds a check that is reported by readiness without failing it,
+// replacing any with the same name. It suits conditions worth seeing that every
+// instance would fail together, where failing readiness would take them all out.
+func (c *Checker) AddAdvisory(name string, check Check) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[name] = check
+	c.advisory[name] = true
+}
+
+// Drain makes the service report not ready from now on, so load balancers stop
+// sending it traffic before it shuts down
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check at once and reports the service ready when all but
+// the advisory ones pass and it is not draining. Results are sorted by name.
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mutex.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	advisory := make(map[string]bool, len(c.advisory))
+	for name := range c.advisory {
+		advisory[name] = true
+	}
+	c.mutex.RUnlock()
+
+	results := make([]Result, 0, len(checks))
+	var resultsMutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := Result{Name: name, OK: true, Advisory: advisory[name]}
+			if err := c.run(ctx, check); err != nil {
+				result.OK = false
+				result.Error = err.Error()
+			}
+			resultsMutex.Lock()
+			results = append(results, result)
+			resultsMutex.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Ready: true, Draining: c.draining.Load(), Checks: results}
+	if report.Draining {
+		report.Ready = false
+	}
+	for _, result := range results {
+		if !result.OK && !result.Advisory {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+// run runs one check within the timeout. A check that does not return in time
+// is reported as failing, and left to finish on its own.
+func (c *Checker) run(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check did not finish: %w", ctx.Err())
+	}
+}
+
+// RepositoryCheck checks the fund repository can be read, as every request
+// that deals in money needs it
+func RepositoryCheck(funds domain.FundRepository) Check {
+	return func(ctx context.Context) error {
+		_, _, err := funds.Find(ctx, domain.FundFilter{Limit: 1})
+		return err
+	}
+}
+
+// AuditCheck fails while audit entries are queued because they could not be
+// written to the audit log. It only reports them: writing is left to Flush, so
+// probing readiness never writes.
+func AuditCheck(audit domain.AuditService) Check {
+	return func(context.Context) error {
+		return audit.Queued()
+	}
+}
+
+// PriceCheck checks prices are arriving, failing when a pending subscription
+// has been waiting longer than maxAge for its unit price. It reads only the
+// oldest unpriced subscription, so it is cheap enough for every probe.
+func PriceCheck(investments domain.InvestmentRepository, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		oldest, err := investments.OldestUnpriced(ctx)
+		if err != nil || oldest == nil {
+			return err
+		}
+		if waited := time.Since(oldest.CreatedAt); waited > maxAge {
+			return fmt.Errorf("fund %s has had no price for %s", oldest.FundID, waited.Round(time.Minute))
+		}
+		return nil
+	}
+}
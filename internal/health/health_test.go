@@ -0,0 +1,97 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/health"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	checker := health.NewChecker(50 * time.Millisecond)
+	checker.Add("ok", func(context.Context) error { return nil })
+
+	report := checker.Ready(ctx)
+	assert.True(t, report.Ready)
+	assert.Equal(t, []health.Result{{Name: "ok", OK: true}}, report.Checks)
+
+	t.Run("A failing check fails readiness", func(t *testing.T) {
+		checker.Add("broken", func(context.Context) error { return errors.New("unreachable") })
+		defer checker.Add("broken", func(context.Context) error { return nil })
+
+		report := checker.Ready(ctx)
+		assert.False(t, report.Ready)
+		assert.Equal(t, health.Result{Name: "broken", Error: "unreachable"}, report.Checks[0])
+		assert.True(t, report.Checks[1].OK)
+	})
+
+	t.Run("A failing advisory check is reported without failing readiness", func(t *testing.T) {
+		checker.AddAdvisory("backlog", func(context.Context) error { return errors.New("behind") })
+		defer checker.Add("backlog", func(context.Context) error { return nil })
+
+		report := checker.Ready(ctx)
+		assert.True(t, report.Ready)
+		assert.Equal(t, health.Result{Name: "backlog", Advisory: true, Error: "behind"}, report.Checks[0])
+	})
+
+	t.Run("A check that does not finish in time fails", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		checker.Add("slow", func(context.Context) error {
+			<-release
+			return nil
+		})
+		defer checker.Add("slow", func(context.Context) error { return nil })
+
+		start := time.Now()
+		report := checker.Ready(ctx)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.False(t, report.Ready)
+		assert.Contains(t, report.Checks[3].Error, "did not finish")
+	})
+
+	t.Run("Draining fails readiness even with every check passing", func(t *testing.T) {
+		checker.Drain()
+
+		report := checker.Ready(ctx)
+		assert.False(t, report.Ready)
+		assert.True(t, report.Draining)
+		for _, result := range report.Checks {
+			assert.True(t, result.OK)
+		}
+	})
+}
+
+func TestPriceCheck(t *testing.T) {
+	ctx := context.Background()
+	investments := repository.NewInMemoryInvestmentRepository()
+	check := health.PriceCheck(investments, 24*time.Hour)
+
+	pending := func(id string, age time.Duration, unitPrice int64) {
+		require.NoError(t, investments.Create(ctx, &domain.Investment{
+			ID:         id,
+			CustomerID: "customer-1",
+			FundID:     "fund-1",
+			Amount:     10000,
+			Status:     domain.InvestmentStatusPending,
+			UnitPrice:  unitPrice,
+			CreatedAt:  time.Now().Add(-age),
+		}))
+	}
+
+	assert.NoError(t, check(ctx))
+
+	// Waiting less than a day, or already priced, is fine
+	pending("inv-1", time.Hour, 0)
+	pending("inv-2", 48*time.Hour, 250)
+	assert.NoError(t, check(ctx))
+
+	pending("inv-3", 25*time.Hour, 0)
+	assert.ErrorContains(t, check(ctx), "fund fund-1 has had no price")
+}
@@ -0,0 +1,61 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "customer.updated",
+		"customer_id", "customer-1",
+		"email", "jane@example.com",
+		"error", errors.New(`invalid email address "jane@example.com"`),
+		"note", "contact jane@example.com",
+	)
+	logger.DebugContext(ctx, "not logged")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "customer.updated", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "customer-1", record["customer_id"])
+	assert.Equal(t, logging.Redacted, record["email"])
+	assert.Equal(t, `invalid email address "[REDACTED]"`, record["error"])
+	assert.Equal(t, "contact [REDACTED]", record["note"])
+	assert.NotContains(t, buf.String(), "jane@example.com")
+	assert.NotContains(t, buf.String(), "not logged")
+}
+
+func TestLoggerAddsTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	logger.InfoContext(ctx, "investment.created")
+	logger.Info("no trace")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+	assert.NotContains(t, string(lines[1]), "trace_id")
+}
@@ -0,0 +1,113 @@
+// Package metrics holds the Prometheus metrics the service exposes on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics records the service's HTTP, business and repository metrics in its
+// own registry, alongside the Go runtime and process metrics
+type Metrics struct {
+	registry             *prometheus.Registry
+	httpRequests         *prometheus.CounterVec
+	httpRequestDuration  *prometheus.HistogramVec
+	investmentsCreated   *prometheus.CounterVec
+	investmentsRejected  *prometheus.CounterVec
+	amountSubscribed     *prometheus.CounterVec
+	repositoryOperations *prometheus.HistogramVec
+}
+
+// New creates and registers the service's metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route template and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		investmentsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "isa_investments_created_total",
+			Help: "Subscriptions created, by fund.",
+		}, []string{"fund_id"}),
+		investmentsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "isa_investments_rejected_total",
+			Help: "Subscriptions refused, by reason.",
+		}, []string{"reason"}),
+		amountSubscribed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "isa_subscribed_pence_total",
+			Help: "Amount subscribed in pence, by fund.",
+		}, []string{"fund_id"}),
+		repositoryOperations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "repository_operation_duration_seconds",
+			Help: "Time taken by repository operations, by repository and operation.",
+			// From 10µs, as the in-memory repositories are fast
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"repository", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.investmentsCreated,
+		m.investmentsRejected,
+		m.amountSubscribed,
+		m.repositoryOperations,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records an HTTP request served for a route template
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// InvestmentCreated records a subscription of amount pence into a fund
+func (m *Metrics) InvestmentCreated(fundID string, amount int64) {
+	m.investmentsCreated.WithLabelValues(fundID).Inc()
+	m.amountSubscribed.WithLabelValues(fundID).Add(float64(amount))
+}
+
+// InvestmentRejected records a subscription refused for a reason. Reasons
+// should come from a fixed set, as each one is a separate series.
+func (m *Metrics) InvestmentRejected(reason string) {
+	m.investmentsRejected.WithLabelValues(reason).Inc()
+}
+
+// ObserveRepository records how long a repository operation took
+func (m *Metrics) ObserveRepository(repository, operation string, duration time.Duration) {
+	m.repositoryOperations.WithLabelValues(repository, operation).Observe(duration.Seconds())
+}
+
+// ObserveOldestUnpriced exposes how long the oldest subscription has been waiting
+// for its unit price, calling age on each scrape. The gauge is NaN while age fails.
+func (m *Metrics) ObserveOldestUnpriced(age func() (time.Duration, error)) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "isa_oldest_unpriced_subscription_age_seconds",
+		Help: "How long the oldest pending subscription has been waiting for its unit price.",
+	}, func() float64 {
+		oldest, err := age()
+		if err != nil {
+			return math.NaN()
+		}
+		return oldest.Seconds()
+	}))
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sort"
+	"sync"
+)
+
+type inMemoryAPIKeyRepository struct {
+	mutex sync.RWMutex
+	keys  map[string]*domain.APIKey
+}
+
+// NewInMemoryAPIKeyRepository creates a new in-memory API key repository
+func NewInMemoryAPIKeyRepository() domain.APIKeyRepository {
+	return &inMemoryAPIKeyRepository{
+		keys: make(map[string]*domain.APIKey),
+	}
+}
+
+// GetByID gets an API key by ID
+func (r *inMemoryAPIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, errors.New("API key not found")
+	}
+
+	return cloneAPIKey(key), nil
+}
+
+// GetByPartnerID gets a partner's API keys, oldest first
+func (r *inMemoryAPIKeyRepository) GetByPartnerID(ctx context.Context, partnerID string) ([]*domain.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := []*domain.APIKey{}
+	for _, key := range r.keys {
+		if key.PartnerID == partnerID {
+			keys = append(keys, cloneAPIKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// Create creates a new API key
+func (r *inMemoryAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.keys[key.ID]; ok {
+		return errors.New("API key already exists")
+	}
+
+	key.Version = 1
+	r.keys[key.ID] = cloneAPIKey(key)
+	return nil
+}
+
+// Update updates an existing API key, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.keys[key.ID]
+	if !ok {
+		return errors.New("API key not found")
+	}
+	if stored.Version != key.Version {
+		return domain.ErrConflict
+	}
+
+	key.Version++
+	r.keys[key.ID] = cloneAPIKey(key)
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sort"
+	"sync"
+)
+
+type inMemoryApprovalRepository struct {
+	mutex     sync.RWMutex
+	approvals map[string]*domain.Approval
+}
+
+// NewInMemoryApprovalRepository creates a new in-memory approval repository
+func NewInMemoryApprovalRepository() domain.ApprovalRepository {
+	return &inMemoryApprovalRepository{
+		approvals: make(map[string]*domain.Approval),
+	}
+}
+
+// GetByID gets an approval by ID
+func (r *inMemoryApprovalRepository) GetByID(ctx context.Context, id string) (*domain.Approval, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	approval, ok := r.approvals[id]
+	if !ok {
+		return nil, errors.New("approval not found")
+	}
+
+	return cloneApproval(approval), nil
+}
+
+// List gets the approvals with a status, or all approvals for an empty status, oldest first
+func (r *inMemoryApprovalRepository) List(ctx context.Context, status domain.ApprovalStatus) ([]*domain.Approval, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	approvals := []*domain.Approval{}
+	for _, approval := range r.approvals {
+		if status == "" || approval.Status == status {
+			approvals = append(approvals, cloneApproval(approval))
+		}
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		if !approvals[i].RequestedAt.Equal(approvals[j].RequestedAt) {
+			return approvals[i].RequestedAt.Before(approvals[j].RequestedAt)
+		}
+		return approvals[i].ID < approvals[j].ID
+	})
+
+	return approvals, nil
+}
+
+// Create creates a new approval
+func (r *inMemoryApprovalRepository) Create(ctx context.Context, approval *domain.Approval) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.approvals[approval.ID]; ok {
+		return errors.New("approval already exists")
+	}
+
+	approval.Version = 1
+	r.approvals[approval.ID] = cloneApproval(approval)
+	return nil
+}
+
+// Update updates an existing approval, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryApprovalRepository) Update(ctx context.Context, approval *domain.Approval) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.approvals[approval.ID]
+	if !ok {
+		return errors.New("approval not found")
+	}
+	if stored.Version != approval.Version {
+		return domain.ErrConflict
+	}
+
+	approval.Version++
+	r.approvals[approval.ID] = cloneApproval(approval)
+	return nil
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"os"
+	"sync"
+)
+
+type inMemoryAuditRepository struct {
+	mutex   sync.RWMutex
+	entries []*domain.AuditEntry
+}
+
+// NewInMemoryAuditRepository creates a new in-memory, append-only audit repository
+func NewInMemoryAuditRepository() domain.AuditRepository {
+	return &inMemoryAuditRepository{}
+}
+
+// Append stores an entry at the end of the log
+func (r *inMemoryAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = append(r.entries, cloneAuditEntry(entry))
+	return nil
+}
+
+// GetAll gets every entry in the order they were appended
+func (r *inMemoryAuditRepository) GetAll(ctx context.Context) ([]*domain.AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entries := make([]*domain.AuditEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entries = append(entries, cloneAuditEntry(entry))
+	}
+	return entries, nil
+}
+
+// fileAuditRepository keeps the audit log as a file of JSON lines, one entry per
+// line, so it survives restarts and can be checked offline by cmd/auditverify
+type fileAuditRepository struct {
+	inMemoryAuditRepository
+	file *os.File
+}
+
+// NewFileAuditRepository opens the audit log at path, creating it if needed,
+// and loads the entries already in it. It refuses a log whose hash chain is
+// broken, as new entries would otherwise be chained onto tampered ones.
+func NewFileAuditRepository(path string) (domain.AuditRepository, error) {
+	entries, err := ReadAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := domain.VerifyAuditChain(entries); err != nil {
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAuditRepository{
+		inMemoryAuditRepository: inMemoryAuditRepository{entries: entries},
+		file:                    file,
+	}, nil
+}
+
+// Append writes an entry to the end of the file, syncing it before returning
+func (r *fileAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := r.file.Sync(); err != nil {
+		return err
+	}
+
+	r.entries = append(r.entries, cloneAuditEntry(entry))
+	return nil
+}
+
+// ReadAuditLog reads every entry from an audit log file written by the file audit repository
+func ReadAuditLog(path string) ([]*domain.AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*domain.AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry domain.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
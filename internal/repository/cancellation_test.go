@@ -0,0 +1,36 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRepositoriesHonourCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fundRepo := repository.NewInMemoryFundRepository()
+	_, err := fundRepo.GetByID(ctx, "fund-1")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, _, err = fundRepo.Find(ctx, domain.FundFilter{})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	err = investmentRepo.Create(ctx, &domain.Investment{ID: "inv-1", CustomerID: "customer-1"})
+	assert.ErrorIs(t, err, context.Canceled)
+	investments, err := investmentRepo.GetByCustomerID(context.Background(), "customer-1")
+	assert.NoError(t, err)
+	assert.Empty(t, investments)
+
+	auditRepo := repository.NewInMemoryAuditRepository()
+	assert.ErrorIs(t, auditRepo.Append(ctx, &domain.AuditEntry{Sequence: 1}), context.Canceled)
+
+	// Deadlines are honoured like cancellation
+	expired, cancelExpired := context.WithTimeout(context.Background(), 0)
+	defer cancelExpired()
+	_, err = fundRepo.GetByID(expired, "fund-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
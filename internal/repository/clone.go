@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"slices"
+	"time"
+)
+
+// The in-memory repositories only ever store and hand out copies, so callers
+// cannot change repository state without going through Create or Update.
+
+func cloneCustomer(customer *domain.Customer) *domain.Customer {
+	c := *customer
+	c.RiskProfiledAt = cloneTime(customer.RiskProfiledAt)
+	return &c
+}
+
+func cloneFund(fund *domain.Fund) *domain.Fund {
+	f := *fund
+	return &f
+}
+
+func cloneInvestment(investment *domain.Investment) *domain.Investment {
+	i := *investment
+	return &i
+}
+
+func cloneInvestmentEvent(event *domain.InvestmentEvent) *domain.InvestmentEvent {
+	e := *event
+	return &e
+}
+
+func cloneJournal(journal *domain.Journal) *domain.Journal {
+	j := *journal
+	j.Postings = slices.Clone(journal.Postings)
+	return &j
+}
+
+func cloneAuditEntry(entry *domain.AuditEntry) *domain.AuditEntry {
+	e := *entry
+	e.Before = slices.Clone(entry.Before)
+	e.After = slices.Clone(entry.After)
+	return &e
+}
+
+func cloneSwitch(sw *domain.Switch) *domain.Switch {
+	s := *sw
+	return &s
+}
+
+func clonePlan(plan *domain.Plan) *domain.Plan {
+	p := *plan
+	p.Allocations = slices.Clone(plan.Allocations)
+	p.LastRunAt = cloneTime(plan.LastRunAt)
+	p.LastInvestmentIDs = slices.Clone(plan.LastInvestmentIDs)
+	return &p
+}
+
+func cloneApproval(approval *domain.Approval) *domain.Approval {
+	a := *approval
+	a.DecidedAt = cloneTime(approval.DecidedAt)
+	if approval.CustomerDetails != nil {
+		details := *approval.CustomerDetails
+		a.CustomerDetails = &details
+	}
+	return &a
+}
+
+func cloneAPIKey(key *domain.APIKey) *domain.APIKey {
+	k := *key
+	k.Scopes = slices.Clone(key.Scopes)
+	k.CustomerIDs = slices.Clone(key.CustomerIDs)
+	k.ExpiresAt = cloneTime(key.ExpiresAt)
+	k.RevokedAt = cloneTime(key.RevokedAt)
+	return &k
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
 	"sync"
@@ -20,6 +21,7 @@ func NewInMemoryCustomerRepository() domain.CustomerRepository {
 			ID:        "customer-1",
 			Name:      "John Smith",
 			Email:     "john.smith@example.com",
+			Version:   1,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		},
@@ -31,7 +33,11 @@ func NewInMemoryCustomerRepository() domain.CustomerRepository {
 }
 
 // GetByID gets a customer by ID
-func (r *inMemoryCustomerRepository) GetByID(id string) (*domain.Customer, error) {
+func (r *inMemoryCustomerRepository) GetByID(ctx context.Context, id string) (*domain.Customer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -40,11 +46,15 @@ func (r *inMemoryCustomerRepository) GetByID(id string) (*domain.Customer, error
 		return nil, errors.New("customer not found")
 	}
 
-	return customer, nil
+	return cloneCustomer(customer), nil
 }
 
 // Create creates a new customer
-func (r *inMemoryCustomerRepository) Create(customer *domain.Customer) error {
+func (r *inMemoryCustomerRepository) Create(ctx context.Context, customer *domain.Customer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -52,19 +62,29 @@ func (r *inMemoryCustomerRepository) Create(customer *domain.Customer) error {
 		return errors.New("customer already exists")
 	}
 
-	r.customers[customer.ID] = customer
+	customer.Version = 1
+	r.customers[customer.ID] = cloneCustomer(customer)
 	return nil
 }
 
-// Update updates an existing customer
-func (r *inMemoryCustomerRepository) Update(customer *domain.Customer) error {
+// Update updates an existing customer, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryCustomerRepository) Update(ctx context.Context, customer *domain.Customer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, ok := r.customers[customer.ID]; !ok {
+	stored, ok := r.customers[customer.ID]
+	if !ok {
 		return errors.New("customer not found")
 	}
+	if stored.Version != customer.Version {
+		return domain.ErrConflict
+	}
 
-	r.customers[customer.ID] = customer
+	customer.Version++
+	r.customers[customer.ID] = cloneCustomer(customer)
 	return nil
 }
@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,28 +21,40 @@ func NewInMemoryFundRepository() domain.FundRepository {
 	// Initialize with sample funds
 	funds := map[string]*domain.Fund{
 		"fund-1": {
-			ID:          "fund-1",
-			Name:        "Equities Fund",
-			Description: "A fund that invests in global equities for long-term growth",
-			RiskLevel:   domain.RiskLevelHigh,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			ID:               "fund-1",
+			Name:             "Equities Fund",
+			Description:      "A fund that invests in global equities for long-term growth",
+			RiskLevel:        domain.RiskLevelHigh,
+			AssetClass:       domain.AssetClassEquity,
+			OngoingChargeBps: 22,
+			Status:           domain.FundStatusOpen,
+			Version:          1,
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
 		},
 		"fund-2": {
-			ID:          "fund-2",
-			Name:        "Balanced Fund",
-			Description: "A balanced fund that invests in a mix of equities and bonds",
-			RiskLevel:   domain.RiskLevelMedium,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			ID:               "fund-2",
+			Name:             "Balanced Fund",
+			Description:      "A balanced fund that invests in a mix of equities and bonds",
+			RiskLevel:        domain.RiskLevelMedium,
+			AssetClass:       domain.AssetClassMultiAsset,
+			OngoingChargeBps: 35,
+			Status:           domain.FundStatusOpen,
+			Version:          1,
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
 		},
 		"fund-3": {
-			ID:          "fund-3",
-			Name:        "Bond Fund",
-			Description: "A fund that invests in government and corporate bonds",
-			RiskLevel:   domain.RiskLevelLow,
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			ID:               "fund-3",
+			Name:             "Bond Fund",
+			Description:      "A fund that invests in government and corporate bonds",
+			RiskLevel:        domain.RiskLevelLow,
+			AssetClass:       domain.AssetClassFixedIncome,
+			OngoingChargeBps: 12,
+			Status:           domain.FundStatusOpen,
+			Version:          1,
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
 		},
 	}
 
@@ -48,7 +64,11 @@ func NewInMemoryFundRepository() domain.FundRepository {
 }
 
 // GetByID gets a fund by ID
-func (r *inMemoryFundRepository) GetByID(id string) (*domain.Fund, error) {
+func (r *inMemoryFundRepository) GetByID(ctx context.Context, id string) (*domain.Fund, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -57,18 +77,144 @@ func (r *inMemoryFundRepository) GetByID(id string) (*domain.Fund, error) {
 		return nil, errors.New("fund not found")
 	}
 
-	return fund, nil
+	return cloneFund(fund), nil
 }
 
 // GetAll gets all funds
-func (r *inMemoryFundRepository) GetAll() ([]*domain.Fund, error) {
+func (r *inMemoryFundRepository) GetAll(ctx context.Context) ([]*domain.Fund, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	funds := make([]*domain.Fund, 0, len(r.funds))
 	for _, fund := range r.funds {
-		funds = append(funds, fund)
+		funds = append(funds, cloneFund(fund))
 	}
 
 	return funds, nil
 }
+
+// Find gets one page of funds matching the filter, sorted as requested,
+// along with the total number of matching funds
+func (r *inMemoryFundRepository) Find(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	terms := strings.Fields(strings.ToLower(filter.Query))
+
+	var funds []*domain.Fund
+	for _, fund := range r.funds {
+		if matchesFundFilter(fund, filter, terms) {
+			funds = append(funds, cloneFund(fund))
+		}
+	}
+
+	sort.Slice(funds, func(i, j int) bool {
+		a, b := funds[i], funds[j]
+		if filter.SortDesc {
+			a, b = b, a
+		}
+		switch filter.SortBy {
+		case domain.FundSortByRiskLevel:
+			if a.RiskLevel.Rank() != b.RiskLevel.Rank() {
+				return a.RiskLevel.Rank() < b.RiskLevel.Rank()
+			}
+		case domain.FundSortByOngoingCharge:
+			if a.OngoingChargeBps != b.OngoingChargeBps {
+				return a.OngoingChargeBps < b.OngoingChargeBps
+			}
+		case domain.FundSortByCreatedAt:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		default:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(funds)
+	if filter.Offset >= total {
+		return []*domain.Fund{}, total, nil
+	}
+	funds = funds[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(funds) {
+		funds = funds[:filter.Limit]
+	}
+
+	return funds, total, nil
+}
+
+// matchesFundFilter reports whether a fund passes every criterion in the filter
+func matchesFundFilter(fund *domain.Fund, filter domain.FundFilter, terms []string) bool {
+	if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, fund.Status) {
+		return false
+	}
+	if len(filter.RiskLevels) > 0 && !slices.Contains(filter.RiskLevels, fund.RiskLevel) {
+		return false
+	}
+	if len(filter.AssetClasses) > 0 && !slices.Contains(filter.AssetClasses, fund.AssetClass) {
+		return false
+	}
+	if filter.MaxOngoingChargeBps > 0 && fund.OngoingChargeBps > filter.MaxOngoingChargeBps {
+		return false
+	}
+
+	text := strings.ToLower(fund.Name + " " + fund.Description)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Create creates a new fund
+func (r *inMemoryFundRepository) Create(ctx context.Context, fund *domain.Fund) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.funds[fund.ID]; ok {
+		return errors.New("fund already exists")
+	}
+
+	fund.Version = 1
+	r.funds[fund.ID] = cloneFund(fund)
+	return nil
+}
+
+// Update updates an existing fund, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryFundRepository) Update(ctx context.Context, fund *domain.Fund) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.funds[fund.ID]
+	if !ok {
+		return errors.New("fund not found")
+	}
+	if stored.Version != fund.Version {
+		return domain.ErrConflict
+	}
+
+	fund.Version++
+	r.funds[fund.ID] = cloneFund(fund)
+	return nil
+}
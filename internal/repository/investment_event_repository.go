@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+)
+
+type inMemoryInvestmentEventRepository struct {
+	mutex  sync.RWMutex
+	events map[string][]*domain.InvestmentEvent
+}
+
+// NewInMemoryInvestmentEventRepository creates a new in-memory, append-only investment event repository
+func NewInMemoryInvestmentEventRepository() domain.InvestmentEventRepository {
+	return &inMemoryInvestmentEventRepository{
+		events: make(map[string][]*domain.InvestmentEvent),
+	}
+}
+
+// Append stores an event after the investment's existing events, rejecting an
+// event that is not next in the sequence with domain.ErrConflict
+func (r *inMemoryInvestmentEventRepository) Append(ctx context.Context, event *domain.InvestmentEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := r.events[event.InvestmentID]
+	if len(events) == 0 && event.Type != domain.InvestmentEventCreated {
+		return errors.New("investment history must start with a created event")
+	}
+
+	if event.Sequence != int64(len(events)+1) {
+		return domain.ErrConflict
+	}
+
+	r.events[event.InvestmentID] = append(events, cloneInvestmentEvent(event))
+	return nil
+}
+
+// GetByInvestmentID gets an investment's events in sequence order
+func (r *inMemoryInvestmentEventRepository) GetByInvestmentID(ctx context.Context, investmentID string) ([]*domain.InvestmentEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	events, ok := r.events[investmentID]
+	if !ok {
+		return nil, errors.New("investment not found")
+	}
+
+	result := make([]*domain.InvestmentEvent, 0, len(events))
+	for _, event := range events {
+		result = append(result, cloneInvestmentEvent(event))
+	}
+
+	return result, nil
+}
@@ -1,25 +1,67 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 )
 
+// investmentKey is the position of an investment in the per-customer index
+type investmentKey struct {
+	createdAt time.Time
+	id        string
+}
+
+// less orders keys by creation time then ID, matching the order of Find
+func (k investmentKey) less(other investmentKey) bool {
+	if !k.createdAt.Equal(other.createdAt) {
+		return k.createdAt.Before(other.createdAt)
+	}
+	return k.id < other.id
+}
+
+// indexedInvestment records the values an investment was indexed under, so the
+// old index entries can be found and removed when Update changes them
+type indexedInvestment struct {
+	customerID string
+	fundID     string
+	key        investmentKey
+	unpriced   bool
+}
+
 type inMemoryInvestmentRepository struct {
 	mutex       sync.RWMutex
 	investments map[string]*domain.Investment
+	indexed     map[string]indexedInvestment
+	// byCustomer holds each customer's investments ordered by creation time then ID
+	byCustomer map[string][]investmentKey
+	// byFund holds the IDs of the investments in each fund
+	byFund map[string]map[string]struct{}
+	// unpriced holds pending investments still waiting for a unit price,
+	// ordered by creation time then ID, so the oldest is always first
+	unpriced []investmentKey
 }
 
 // NewInMemoryInvestmentRepository creates a new in-memory investment repository
 func NewInMemoryInvestmentRepository() domain.InvestmentRepository {
 	return &inMemoryInvestmentRepository{
 		investments: make(map[string]*domain.Investment),
+		indexed:     make(map[string]indexedInvestment),
+		byCustomer:  make(map[string][]investmentKey),
+		byFund:      make(map[string]map[string]struct{}),
 	}
 }
 
 // GetByID gets an investment by ID
-func (r *inMemoryInvestmentRepository) GetByID(id string) (*domain.Investment, error) {
+func (r *inMemoryInvestmentRepository) GetByID(ctx context.Context, id string) (*domain.Investment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -28,18 +70,100 @@ func (r *inMemoryInvestmentRepository) GetByID(id string) (*domain.Investment, e
 		return nil, errors.New("investment not found")
 	}
 
-	return investment, nil
+	return cloneInvestment(investment), nil
 }
 
 // GetByCustomerID gets all investments for a customer
-func (r *inMemoryInvestmentRepository) GetByCustomerID(customerID string) ([]*domain.Investment, error) {
+func (r *inMemoryInvestmentRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := r.byCustomer[customerID]
+	investments := make([]*domain.Investment, 0, len(keys))
+	for _, key := range keys {
+		investments = append(investments, cloneInvestment(r.investments[key.id]))
+	}
+
+	return investments, nil
+}
+
+// GetByFundID gets all investments in a fund
+func (r *inMemoryInvestmentRepository) GetByFundID(ctx context.Context, fundID string) ([]*domain.Investment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ids := r.byFund[fundID]
+	investments := make([]*domain.Investment, 0, len(ids))
+	for id := range ids {
+		investments = append(investments, cloneInvestment(r.investments[id]))
+	}
+
+	return investments, nil
+}
+
+// OldestUnpriced gets the oldest pending investment without a unit price, or nil when there is none
+func (r *inMemoryInvestmentRepository) OldestUnpriced(ctx context.Context) (*domain.Investment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if len(r.unpriced) == 0 {
+		return nil, nil
+	}
+	return cloneInvestment(r.investments[r.unpriced[0].id]), nil
+}
+
+// Find gets a customer's investments matching the filter, ordered by
+// creation time then ID and starting after the filter's cursor
+func (r *inMemoryInvestmentRepository) Find(ctx context.Context, filter domain.InvestmentFilter) ([]*domain.Investment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
+	keys := r.byCustomer[filter.CustomerID]
+
+	// The index is already in page order, so jump straight to the first candidate
+	start := 0
+	if filter.After != nil {
+		after := investmentKey{createdAt: filter.After.CreatedAt, id: filter.After.ID}
+		start = sort.Search(len(keys), func(i int) bool { return after.less(keys[i]) })
+	}
+	if !filter.CreatedFrom.IsZero() {
+		from := sort.Search(len(keys), func(i int) bool { return !keys[i].createdAt.Before(filter.CreatedFrom) })
+		start = max(start, from)
+	}
+
 	var investments []*domain.Investment
-	for _, investment := range r.investments {
-		if investment.CustomerID == customerID {
-			investments = append(investments, investment)
+	for _, key := range keys[start:] {
+		if !filter.CreatedTo.IsZero() && !key.createdAt.Before(filter.CreatedTo) {
+			break
+		}
+
+		investment := r.investments[key.id]
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, investment.Status) {
+			continue
+		}
+		if filter.FundID != "" && investment.FundID != filter.FundID {
+			continue
+		}
+
+		investments = append(investments, cloneInvestment(investment))
+		if filter.Limit > 0 && len(investments) == filter.Limit {
+			break
 		}
 	}
 
@@ -47,7 +171,11 @@ func (r *inMemoryInvestmentRepository) GetByCustomerID(customerID string) ([]*do
 }
 
 // Create creates a new investment
-func (r *inMemoryInvestmentRepository) Create(investment *domain.Investment) error {
+func (r *inMemoryInvestmentRepository) Create(ctx context.Context, investment *domain.Investment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -55,19 +183,103 @@ func (r *inMemoryInvestmentRepository) Create(investment *domain.Investment) err
 		return errors.New("investment already exists")
 	}
 
-	r.investments[investment.ID] = investment
+	investment.Version = 1
+	r.investments[investment.ID] = cloneInvestment(investment)
+	r.addToIndexes(investment)
 	return nil
 }
 
-// Update updates an existing investment
-func (r *inMemoryInvestmentRepository) Update(investment *domain.Investment) error {
+// Update updates an existing investment, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryInvestmentRepository) Update(ctx context.Context, investment *domain.Investment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if _, ok := r.investments[investment.ID]; !ok {
+	stored, ok := r.investments[investment.ID]
+	if !ok {
 		return errors.New("investment not found")
 	}
+	if stored.Version != investment.Version {
+		return domain.ErrConflict
+	}
 
-	r.investments[investment.ID] = investment
+	investment.Version++
+	r.removeFromIndexes(investment.ID)
+	r.investments[investment.ID] = cloneInvestment(investment)
+	r.addToIndexes(investment)
 	return nil
 }
+
+// addToIndexes indexes an investment by customer and fund, and among those
+// waiting for a price. Callers must hold the write lock.
+func (r *inMemoryInvestmentRepository) addToIndexes(investment *domain.Investment) {
+	entry := indexedInvestment{
+		customerID: investment.CustomerID,
+		fundID:     investment.FundID,
+		key:        investmentKey{createdAt: investment.CreatedAt, id: investment.ID},
+		unpriced:   investment.Status == domain.InvestmentStatusPending && investment.UnitPrice == 0,
+	}
+	r.indexed[investment.ID] = entry
+
+	r.byCustomer[entry.customerID] = insertKey(r.byCustomer[entry.customerID], entry.key)
+	if entry.unpriced {
+		r.unpriced = insertKey(r.unpriced, entry.key)
+	}
+
+	if r.byFund[entry.fundID] == nil {
+		r.byFund[entry.fundID] = make(map[string]struct{})
+	}
+	r.byFund[entry.fundID][investment.ID] = struct{}{}
+}
+
+// removeFromIndexes removes an investment from the indexes it was last added to.
+// Callers must hold the write lock.
+func (r *inMemoryInvestmentRepository) removeFromIndexes(id string) {
+	entry, ok := r.indexed[id]
+	if !ok {
+		return
+	}
+	delete(r.indexed, id)
+
+	keys := deleteKey(r.byCustomer[entry.customerID], entry.key)
+	if len(keys) == 0 {
+		delete(r.byCustomer, entry.customerID)
+	} else {
+		r.byCustomer[entry.customerID] = keys
+	}
+
+	delete(r.byFund[entry.fundID], id)
+	if len(r.byFund[entry.fundID]) == 0 {
+		delete(r.byFund, entry.fundID)
+	}
+
+	if entry.unpriced {
+		r.unpriced = deleteKey(r.unpriced, entry.key)
+	}
+}
+
+// insertKey adds key to keys, keeping them in order
+func insertKey(keys []investmentKey, key investmentKey) []investmentKey {
+	i := sort.Search(len(keys), func(i int) bool { return key.less(keys[i]) })
+	return slices.Insert(keys, i, key)
+}
+
+// deleteKey removes key from ordered keys, if it is there
+func deleteKey(keys []investmentKey, key investmentKey) []investmentKey {
+	if i, found := sort.Find(len(keys), func(i int) int {
+		switch {
+		case key.less(keys[i]):
+			return -1
+		case keys[i].less(key):
+			return 1
+		default:
+			return 0
+		}
+	}); found {
+		keys = slices.Delete(keys, i, i+1)
+	}
+	return keys
+}
@@ -0,0 +1,157 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInvestmentIndexes(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryInvestmentRepository()
+	start := time.Date(2025, time.May, 1, 9, 0, 0, 0, time.UTC)
+
+	// Created out of order to check the customer index stays sorted
+	for _, i := range []int{2, 0, 1} {
+		assert.NoError(t, repo.Create(ctx, &domain.Investment{
+			ID:         fmt.Sprintf("inv-%d", i),
+			CustomerID: "customer-1",
+			FundID:     "fund-1",
+			CreatedAt:  start.AddDate(0, 0, i),
+		}))
+	}
+
+	ids := func(investments []*domain.Investment) []string {
+		result := make([]string, 0, len(investments))
+		for _, investment := range investments {
+			result = append(result, investment.ID)
+		}
+		return result
+	}
+
+	investments, err := repo.GetByCustomerID(ctx, "customer-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"inv-0", "inv-1", "inv-2"}, ids(investments))
+
+	t.Run("Update moves an investment between indexes", func(t *testing.T) {
+		assert.NoError(t, repo.Update(ctx, &domain.Investment{
+			ID:         "inv-1",
+			CustomerID: "customer-2",
+			FundID:     "fund-3",
+			CreatedAt:  start.AddDate(0, 0, 1),
+			Version:    1,
+		}))
+
+		investments, _ := repo.GetByCustomerID(ctx, "customer-1")
+		assert.Equal(t, []string{"inv-0", "inv-2"}, ids(investments))
+		investments, _ = repo.GetByCustomerID(ctx, "customer-2")
+		assert.Equal(t, []string{"inv-1"}, ids(investments))
+
+		investments, _ = repo.GetByFundID(ctx, "fund-1")
+		assert.ElementsMatch(t, []string{"inv-0", "inv-2"}, ids(investments))
+		investments, _ = repo.GetByFundID(ctx, "fund-3")
+		assert.Equal(t, []string{"inv-1"}, ids(investments))
+	})
+
+	t.Run("Find resumes after the cursor", func(t *testing.T) {
+		investments, err := repo.Find(ctx, domain.InvestmentFilter{
+			CustomerID: "customer-1",
+			After:      &domain.InvestmentCursor{CreatedAt: start, ID: "inv-0"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-2"}, ids(investments))
+	})
+
+	t.Run("The oldest unpriced investment follows pricing", func(t *testing.T) {
+		oldest, err := repo.OldestUnpriced(ctx)
+		assert.NoError(t, err)
+		assert.Nil(t, oldest)
+
+		for _, i := range []int{4, 3} {
+			assert.NoError(t, repo.Create(ctx, &domain.Investment{
+				ID:         fmt.Sprintf("inv-%d", i),
+				CustomerID: "customer-1",
+				FundID:     "fund-1",
+				Status:     domain.InvestmentStatusPending,
+				CreatedAt:  start.AddDate(0, 0, i),
+			}))
+		}
+		oldest, _ = repo.OldestUnpriced(ctx)
+		assert.Equal(t, "inv-3", oldest.ID)
+
+		assert.NoError(t, repo.Update(ctx, &domain.Investment{
+			ID:         "inv-3",
+			CustomerID: "customer-1",
+			FundID:     "fund-1",
+			Status:     domain.InvestmentStatusPending,
+			UnitPrice:  125,
+			CreatedAt:  start.AddDate(0, 0, 3),
+			Version:    1,
+		}))
+		oldest, _ = repo.OldestUnpriced(ctx)
+		assert.Equal(t, "inv-4", oldest.ID)
+	})
+}
+
+// seedInvestments fills a repository with total investments spread evenly across
+// customers so that every customer holds perCustomer investments
+func seedInvestments(b *testing.B, total, perCustomer int) domain.InvestmentRepository {
+	ctx := context.Background()
+	b.Helper()
+
+	repo := repository.NewInMemoryInvestmentRepository()
+	start := time.Date(2025, time.April, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < total; i++ {
+		err := repo.Create(ctx, &domain.Investment{
+			ID:         fmt.Sprintf("inv-%d", i),
+			CustomerID: fmt.Sprintf("customer-%d", i/perCustomer),
+			FundID:     fmt.Sprintf("fund-%d", i%3+1),
+			Amount:     10000,
+			Status:     domain.InvestmentStatusPending,
+			CreatedAt:  start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return repo
+}
+
+// The cost of a customer lookup should stay flat as the total number of investments grows
+func BenchmarkGetByCustomerID(b *testing.B) {
+	ctx := context.Background()
+	for _, total := range []int{1000, 10000, 100000, 500000} {
+		b.Run(fmt.Sprintf("total=%d", total), func(b *testing.B) {
+			repo := seedInvestments(b, total, 20)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetByCustomerID(ctx, "customer-7"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindPage(b *testing.B) {
+	ctx := context.Background()
+	for _, total := range []int{1000, 10000, 100000, 500000} {
+		b.Run(fmt.Sprintf("total=%d", total), func(b *testing.B) {
+			repo := seedInvestments(b, total, 20)
+			filter := domain.InvestmentFilter{CustomerID: "customer-7", Limit: 10}
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Find(ctx, filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests are most useful under the race detector (go test -race), which
+// reports any caller mutation that reaches the repository's own data.
+
+func TestInvestmentRepositoryIsolation(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryInvestmentRepository()
+
+	original := &domain.Investment{ID: "inv-1", CustomerID: "customer-1", FundID: "fund-1", Amount: 10000, Status: domain.InvestmentStatusPending}
+	assert.NoError(t, repo.Create(ctx, original))
+
+	t.Run("Changing the created struct does not change the repository", func(t *testing.T) {
+		original.Amount = 99999
+
+		stored, err := repo.GetByID(ctx, "inv-1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10000), stored.Amount)
+	})
+
+	t.Run("Changing a returned struct does not change the repository", func(t *testing.T) {
+		returned, _ := repo.GetByID(ctx, "inv-1")
+		returned.Status = domain.InvestmentStatusCancelled
+
+		listed, _ := repo.GetByCustomerID(ctx, "customer-1")
+		listed[0].FundID = "fund-2"
+
+		stored, _ := repo.GetByID(ctx, "inv-1")
+		assert.Equal(t, domain.InvestmentStatusPending, stored.Status)
+		assert.Equal(t, "fund-1", stored.FundID)
+	})
+
+	t.Run("Concurrent readers mutating their copies do not race with writers", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					investment, _ := repo.GetByID(ctx, "inv-1")
+					investment.Amount++
+					investments, _ := repo.Find(ctx, domain.InvestmentFilter{CustomerID: "customer-1"})
+					investments[0].Status = domain.InvestmentStatusCancelled
+				}
+			}()
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					for {
+						update, _ := repo.GetByID(ctx, "inv-1")
+						update.Amount = int64(i*100 + j)
+						err := repo.Update(ctx, update)
+						update.Amount = -1
+						if !errors.Is(err, domain.ErrConflict) {
+							assert.NoError(t, err)
+							break
+						}
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		stored, _ := repo.GetByID(ctx, "inv-1")
+		assert.Equal(t, domain.InvestmentStatusPending, stored.Status)
+		assert.GreaterOrEqual(t, stored.Amount, int64(0))
+	})
+}
+
+func TestCustomerRepositoryIsolation(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryCustomerRepository()
+
+	customer, err := repo.GetByID(ctx, "customer-1")
+	assert.NoError(t, err)
+
+	want := time.Now()
+	profiledAt := want
+	customer.RiskTolerance = domain.RiskLevelHigh
+	customer.RiskProfiledAt = &profiledAt
+
+	stored, _ := repo.GetByID(ctx, "customer-1")
+	assert.Empty(t, stored.RiskTolerance)
+	assert.Nil(t, stored.RiskProfiledAt)
+
+	// Once saved, nested pointers are copied too
+	assert.NoError(t, repo.Update(ctx, customer))
+	*customer.RiskProfiledAt = time.Time{}
+
+	stored, _ = repo.GetByID(ctx, "customer-1")
+	assert.Equal(t, domain.RiskLevelHigh, stored.RiskTolerance)
+	assert.Equal(t, want, *stored.RiskProfiledAt)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for {
+					c, _ := repo.GetByID(ctx, "customer-1")
+					c.Name = fmt.Sprintf("Customer %d-%d", i, j)
+					*c.RiskProfiledAt = time.Time{}
+					err := repo.Update(ctx, c)
+					if !errors.Is(err, domain.ErrConflict) {
+						assert.NoError(t, err)
+						break
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFundAndPlanRepositoryIsolation(t *testing.T) {
+	ctx := context.Background()
+	funds := repository.NewInMemoryFundRepository()
+
+	all, _ := funds.GetAll(ctx)
+	for _, fund := range all {
+		fund.Status = domain.FundStatusClosed
+	}
+	page, _, _ := funds.Find(ctx, domain.FundFilter{})
+	for _, fund := range page {
+		assert.Equal(t, domain.FundStatusOpen, fund.Status)
+	}
+
+	plans := repository.NewInMemoryPlanRepository()
+	want := time.Now()
+	ranAt := want
+	plan := &domain.Plan{ID: "plan-1", CustomerID: "customer-1", Status: domain.PlanStatusActive, LastRunAt: &ranAt}
+	assert.NoError(t, plans.Create(ctx, plan))
+	*plan.LastRunAt = time.Time{}
+
+	due, _ := plans.GetDue(ctx, time.Now())
+	assert.Len(t, due, 1)
+	assert.Equal(t, want, *due[0].LastRunAt)
+	due[0].Status = domain.PlanStatusCancelled
+
+	stored, _ := plans.GetByID(ctx, "plan-1")
+	assert.Equal(t, domain.PlanStatusActive, stored.Status)
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+)
+
+type inMemoryLedgerRepository struct {
+	mutex    sync.RWMutex
+	journals map[string]*domain.Journal
+	// byAccount and byCustomer hold journal IDs in the order they were posted
+	byAccount  map[domain.LedgerAccount][]string
+	byCustomer map[string][]string
+}
+
+// NewInMemoryLedgerRepository creates a new in-memory, append-only ledger repository
+func NewInMemoryLedgerRepository() domain.LedgerRepository {
+	return &inMemoryLedgerRepository{
+		journals:   make(map[string]*domain.Journal),
+		byAccount:  make(map[domain.LedgerAccount][]string),
+		byCustomer: make(map[string][]string),
+	}
+}
+
+// Create stores a journal. Journals cannot be changed once stored.
+func (r *inMemoryLedgerRepository) Create(ctx context.Context, journal *domain.Journal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.journals[journal.ID]; ok {
+		return errors.New("journal already exists")
+	}
+
+	r.journals[journal.ID] = cloneJournal(journal)
+	accounts := make(map[domain.LedgerAccount]bool)
+	for _, posting := range journal.Postings {
+		if !accounts[posting.Account] {
+			accounts[posting.Account] = true
+			r.byAccount[posting.Account] = append(r.byAccount[posting.Account], journal.ID)
+		}
+	}
+	r.byCustomer[journal.CustomerID] = append(r.byCustomer[journal.CustomerID], journal.ID)
+	return nil
+}
+
+// GetByAccount gets the journals posting to an account, oldest first
+func (r *inMemoryLedgerRepository) GetByAccount(ctx context.Context, account domain.LedgerAccount) ([]*domain.Journal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.journalsByID(r.byAccount[account]), nil
+}
+
+// GetByCustomerID gets a customer's journals, oldest first
+func (r *inMemoryLedgerRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Journal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.journalsByID(r.byCustomer[customerID]), nil
+}
+
+// journalsByID copies out the journals with the given IDs. Callers must hold the lock.
+func (r *inMemoryLedgerRepository) journalsByID(ids []string) []*domain.Journal {
+	journals := make([]*domain.Journal, 0, len(ids))
+	for _, id := range ids {
+		journals = append(journals, cloneJournal(r.journals[id]))
+	}
+	return journals
+}
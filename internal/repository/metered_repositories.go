@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+	"time"
+)
+
+// The metered repositories wrap a repository and record how long each of its
+// operations takes in the repository_operation_duration_seconds metric.
+
+// meter records the duration of one repository's operations
+type meter struct {
+	metrics    *metrics.Metrics
+	repository string
+}
+
+// observe starts timing an operation, recording it when the returned function is called
+func (m meter) observe(operation string) func() {
+	start := time.Now()
+	return func() {
+		m.metrics.ObserveRepository(m.repository, operation, time.Since(start))
+	}
+}
+
+type meteredCustomerRepository struct {
+	next domain.CustomerRepository
+	meter
+}
+
+// NewMeteredCustomerRepository wraps a customer repository so its operations are timed
+func NewMeteredCustomerRepository(r domain.CustomerRepository, m *metrics.Metrics) domain.CustomerRepository {
+	return &meteredCustomerRepository{next: r, meter: meter{metrics: m, repository: "customer"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredCustomerRepository) GetByID(ctx context.Context, id string) (*domain.Customer, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredCustomerRepository) Create(ctx context.Context, customer *domain.Customer) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, customer)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredCustomerRepository) Update(ctx context.Context, customer *domain.Customer) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, customer)
+}
+
+type meteredFundRepository struct {
+	next domain.FundRepository
+	meter
+}
+
+// NewMeteredFundRepository wraps a fund repository so its operations are timed
+func NewMeteredFundRepository(r domain.FundRepository, m *metrics.Metrics) domain.FundRepository {
+	return &meteredFundRepository{next: r, meter: meter{metrics: m, repository: "fund"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredFundRepository) GetByID(ctx context.Context, id string) (*domain.Fund, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// GetAll times GetAll on the wrapped repository
+func (r *meteredFundRepository) GetAll(ctx context.Context) ([]*domain.Fund, error) {
+	defer r.observe("get_all")()
+	return r.next.GetAll(ctx)
+}
+
+// Find times Find on the wrapped repository
+func (r *meteredFundRepository) Find(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	defer r.observe("find")()
+	return r.next.Find(ctx, filter)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredFundRepository) Create(ctx context.Context, fund *domain.Fund) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, fund)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredFundRepository) Update(ctx context.Context, fund *domain.Fund) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, fund)
+}
+
+type meteredInvestmentRepository struct {
+	next domain.InvestmentRepository
+	meter
+}
+
+// NewMeteredInvestmentRepository wraps an investment repository so its operations are timed
+func NewMeteredInvestmentRepository(r domain.InvestmentRepository, m *metrics.Metrics) domain.InvestmentRepository {
+	return &meteredInvestmentRepository{next: r, meter: meter{metrics: m, repository: "investment"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredInvestmentRepository) GetByID(ctx context.Context, id string) (*domain.Investment, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// GetByCustomerID times GetByCustomerID on the wrapped repository
+func (r *meteredInvestmentRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	defer r.observe("get_by_customer_id")()
+	return r.next.GetByCustomerID(ctx, customerID)
+}
+
+// GetByFundID times GetByFundID on the wrapped repository
+func (r *meteredInvestmentRepository) GetByFundID(ctx context.Context, fundID string) ([]*domain.Investment, error) {
+	defer r.observe("get_by_fund_id")()
+	return r.next.GetByFundID(ctx, fundID)
+}
+
+// Find times Find on the wrapped repository
+func (r *meteredInvestmentRepository) Find(ctx context.Context, filter domain.InvestmentFilter) ([]*domain.Investment, error) {
+	defer r.observe("find")()
+	return r.next.Find(ctx, filter)
+}
+
+// OldestUnpriced times OldestUnpriced on the wrapped repository
+func (r *meteredInvestmentRepository) OldestUnpriced(ctx context.Context) (*domain.Investment, error) {
+	defer r.observe("oldest_unpriced")()
+	return r.next.OldestUnpriced(ctx)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredInvestmentRepository) Create(ctx context.Context, investment *domain.Investment) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, investment)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredInvestmentRepository) Update(ctx context.Context, investment *domain.Investment) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, investment)
+}
+
+type meteredInvestmentEventRepository struct {
+	next domain.InvestmentEventRepository
+	meter
+}
+
+// NewMeteredInvestmentEventRepository wraps an investment event repository so its operations are timed
+func NewMeteredInvestmentEventRepository(r domain.InvestmentEventRepository, m *metrics.Metrics) domain.InvestmentEventRepository {
+	return &meteredInvestmentEventRepository{next: r, meter: meter{metrics: m, repository: "investment_event"}}
+}
+
+// Append times Append on the wrapped repository
+func (r *meteredInvestmentEventRepository) Append(ctx context.Context, event *domain.InvestmentEvent) error {
+	defer r.observe("append")()
+	return r.next.Append(ctx, event)
+}
+
+// GetByInvestmentID times GetByInvestmentID on the wrapped repository
+func (r *meteredInvestmentEventRepository) GetByInvestmentID(ctx context.Context, investmentID string) ([]*domain.InvestmentEvent, error) {
+	defer r.observe("get_by_investment_id")()
+	return r.next.GetByInvestmentID(ctx, investmentID)
+}
+
+type meteredSwitchRepository struct {
+	next domain.SwitchRepository
+	meter
+}
+
+// NewMeteredSwitchRepository wraps a switch repository so its operations are timed
+func NewMeteredSwitchRepository(r domain.SwitchRepository, m *metrics.Metrics) domain.SwitchRepository {
+	return &meteredSwitchRepository{next: r, meter: meter{metrics: m, repository: "switch"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredSwitchRepository) GetByID(ctx context.Context, id string) (*domain.Switch, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// GetByCustomerID times GetByCustomerID on the wrapped repository
+func (r *meteredSwitchRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Switch, error) {
+	defer r.observe("get_by_customer_id")()
+	return r.next.GetByCustomerID(ctx, customerID)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredSwitchRepository) Create(ctx context.Context, sw *domain.Switch) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, sw)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredSwitchRepository) Update(ctx context.Context, sw *domain.Switch) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, sw)
+}
+
+type meteredPlanRepository struct {
+	next domain.PlanRepository
+	meter
+}
+
+// NewMeteredPlanRepository wraps a plan repository so its operations are timed
+func NewMeteredPlanRepository(r domain.PlanRepository, m *metrics.Metrics) domain.PlanRepository {
+	return &meteredPlanRepository{next: r, meter: meter{metrics: m, repository: "plan"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredPlanRepository) GetByID(ctx context.Context, id string) (*domain.Plan, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// GetByCustomerID times GetByCustomerID on the wrapped repository
+func (r *meteredPlanRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	defer r.observe("get_by_customer_id")()
+	return r.next.GetByCustomerID(ctx, customerID)
+}
+
+// GetDue times GetDue on the wrapped repository
+func (r *meteredPlanRepository) GetDue(ctx context.Context, at time.Time) ([]*domain.Plan, error) {
+	defer r.observe("get_due")()
+	return r.next.GetDue(ctx, at)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredPlanRepository) Create(ctx context.Context, plan *domain.Plan) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, plan)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredPlanRepository) Update(ctx context.Context, plan *domain.Plan) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, plan)
+}
+
+type meteredLedgerRepository struct {
+	next domain.LedgerRepository
+	meter
+}
+
+// NewMeteredLedgerRepository wraps a ledger repository so its operations are timed
+func NewMeteredLedgerRepository(r domain.LedgerRepository, m *metrics.Metrics) domain.LedgerRepository {
+	return &meteredLedgerRepository{next: r, meter: meter{metrics: m, repository: "ledger"}}
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredLedgerRepository) Create(ctx context.Context, journal *domain.Journal) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, journal)
+}
+
+// GetByAccount times GetByAccount on the wrapped repository
+func (r *meteredLedgerRepository) GetByAccount(ctx context.Context, account domain.LedgerAccount) ([]*domain.Journal, error) {
+	defer r.observe("get_by_account")()
+	return r.next.GetByAccount(ctx, account)
+}
+
+// GetByCustomerID times GetByCustomerID on the wrapped repository
+func (r *meteredLedgerRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Journal, error) {
+	defer r.observe("get_by_customer_id")()
+	return r.next.GetByCustomerID(ctx, customerID)
+}
+
+type meteredAuditRepository struct {
+	next domain.AuditRepository
+	meter
+}
+
+// NewMeteredAuditRepository wraps an audit repository so its operations are timed
+func NewMeteredAuditRepository(r domain.AuditRepository, m *metrics.Metrics) domain.AuditRepository {
+	return &meteredAuditRepository{next: r, meter: meter{metrics: m, repository: "audit"}}
+}
+
+// Append times Append on the wrapped repository
+func (r *meteredAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	defer r.observe("append")()
+	return r.next.Append(ctx, entry)
+}
+
+// GetAll times GetAll on the wrapped repository
+func (r *meteredAuditRepository) GetAll(ctx context.Context) ([]*domain.AuditEntry, error) {
+	defer r.observe("get_all")()
+	return r.next.GetAll(ctx)
+}
+
+type meteredApprovalRepository struct {
+	next domain.ApprovalRepository
+	meter
+}
+
+// NewMeteredApprovalRepository wraps an approval repository so its operations are timed
+func NewMeteredApprovalRepository(r domain.ApprovalRepository, m *metrics.Metrics) domain.ApprovalRepository {
+	return &meteredApprovalRepository{next: r, meter: meter{metrics: m, repository: "approval"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredApprovalRepository) GetByID(ctx context.Context, id string) (*domain.Approval, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// List times List on the wrapped repository
+func (r *meteredApprovalRepository) List(ctx context.Context, status domain.ApprovalStatus) ([]*domain.Approval, error) {
+	defer r.observe("list")()
+	return r.next.List(ctx, status)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredApprovalRepository) Create(ctx context.Context, approval *domain.Approval) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, approval)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredApprovalRepository) Update(ctx context.Context, approval *domain.Approval) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, approval)
+}
+
+type meteredAPIKeyRepository struct {
+	next domain.APIKeyRepository
+	meter
+}
+
+// NewMeteredAPIKeyRepository wraps an API key repository so its operations are timed
+func NewMeteredAPIKeyRepository(r domain.APIKeyRepository, m *metrics.Metrics) domain.APIKeyRepository {
+	return &meteredAPIKeyRepository{next: r, meter: meter{metrics: m, repository: "api_key"}}
+}
+
+// GetByID times GetByID on the wrapped repository
+func (r *meteredAPIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	defer r.observe("get_by_id")()
+	return r.next.GetByID(ctx, id)
+}
+
+// GetByPartnerID times GetByPartnerID on the wrapped repository
+func (r *meteredAPIKeyRepository) GetByPartnerID(ctx context.Context, partnerID string) ([]*domain.APIKey, error) {
+	defer r.observe("get_by_partner_id")()
+	return r.next.GetByPartnerID(ctx, partnerID)
+}
+
+// Create times Create on the wrapped repository
+func (r *meteredAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	defer r.observe("create")()
+	return r.next.Create(ctx, key)
+}
+
+// Update times Update on the wrapped repository
+func (r *meteredAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	defer r.observe("update")()
+	return r.next.Update(ctx, key)
+}
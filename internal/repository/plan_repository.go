@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+	"time"
+)
+
+type inMemoryPlanRepository struct {
+	mutex sync.RWMutex
+	plans map[string]*domain.Plan
+}
+
+// NewInMemoryPlanRepository creates a new in-memory plan repository
+func NewInMemoryPlanRepository() domain.PlanRepository {
+	return &inMemoryPlanRepository{
+		plans: make(map[string]*domain.Plan),
+	}
+}
+
+// GetByID gets a plan by ID
+func (r *inMemoryPlanRepository) GetByID(ctx context.Context, id string) (*domain.Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	plan, ok := r.plans[id]
+	if !ok {
+		return nil, errors.New("plan not found")
+	}
+
+	return clonePlan(plan), nil
+}
+
+// GetByCustomerID gets all plans for a customer
+func (r *inMemoryPlanRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var plans []*domain.Plan
+	for _, plan := range r.plans {
+		if plan.CustomerID == customerID {
+			plans = append(plans, clonePlan(plan))
+		}
+	}
+
+	return plans, nil
+}
+
+// GetDue gets all active plans whose next run is at or before the given time
+func (r *inMemoryPlanRepository) GetDue(ctx context.Context, at time.Time) ([]*domain.Plan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var plans []*domain.Plan
+	for _, plan := range r.plans {
+		if plan.Status == domain.PlanStatusActive && !plan.NextRunAt.After(at) {
+			plans = append(plans, clonePlan(plan))
+		}
+	}
+
+	return plans, nil
+}
+
+// Create creates a new plan
+func (r *inMemoryPlanRepository) Create(ctx context.Context, plan *domain.Plan) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.plans[plan.ID]; ok {
+		return errors.New("plan already exists")
+	}
+
+	plan.Version = 1
+	r.plans[plan.ID] = clonePlan(plan)
+	return nil
+}
+
+// Update updates an existing plan, rejecting stale versions with domain.ErrConflict
+func (r *inMemoryPlanRepository) Update(ctx context.Context, plan *domain.Plan) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.plans[plan.ID]
+	if !ok {
+		return errors.New("plan not found")
+	}
+	if stored.Version != plan.Version {
+		return domain.ErrConflict
+	}
+
+	plan.Version++
+	r.plans[plan.ID] = clonePlan(plan)
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+)
+
+type inMemorySwitchRepository struct {
+	mutex    sync.RWMutex
+	switches map[string]*domain.Switch
+}
+
+// NewInMemorySwitchRepository creates a new in-memory switch repository
+func NewInMemorySwitchRepository() domain.SwitchRepository {
+	return &inMemorySwitchRepository{
+		switches: make(map[string]*domain.Switch),
+	}
+}
+
+// GetByID gets a switch by ID
+func (r *inMemorySwitchRepository) GetByID(ctx context.Context, id string) (*domain.Switch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sw, ok := r.switches[id]
+	if !ok {
+		return nil, errors.New("switch not found")
+	}
+
+	return cloneSwitch(sw), nil
+}
+
+// GetByCustomerID gets all switches for a customer
+func (r *inMemorySwitchRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Switch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var switches []*domain.Switch
+	for _, sw := range r.switches {
+		if sw.CustomerID == customerID {
+			switches = append(switches, cloneSwitch(sw))
+		}
+	}
+
+	return switches, nil
+}
+
+// Create creates a new switch
+func (r *inMemorySwitchRepository) Create(ctx context.Context, sw *domain.Switch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.switches[sw.ID]; ok {
+		return errors.New("switch already exists")
+	}
+
+	sw.Version = 1
+	r.switches[sw.ID] = cloneSwitch(sw)
+	return nil
+}
+
+// Update updates an existing switch, rejecting stale versions with domain.ErrConflict
+func (r *inMemorySwitchRepository) Update(ctx context.Context, sw *domain.Switch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.switches[sw.ID]
+	if !ok {
+		return errors.New("switch not found")
+	}
+	if stored.Version != sw.Version {
+		return domain.ErrConflict
+	}
+
+	sw.Version++
+	r.switches[sw.ID] = cloneSwitch(sw)
+	return nil
+}
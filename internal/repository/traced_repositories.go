@@ -0,0 +1,444 @@
+package repository
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// The traced repositories wrap a repository and record a span for each of its
+// operations, named after the repository and operation, such as
+// FundRepository.GetByID.
+
+// spanner starts the spans of one repository's operations
+type spanner struct {
+	tracer     trace.Tracer
+	name       string
+	repository string
+}
+
+// newSpanner creates the spanner of the named repository
+func newSpanner(tp trace.TracerProvider, name, repository string) spanner {
+	return spanner{tracer: tp.Tracer(tracing.InstrumentationName), name: name, repository: repository}
+}
+
+// start starts the span of an operation
+func (s spanner) start(ctx context.Context, operation, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("repository", s.repository), attribute.String("operation", operation))
+	return s.tracer.Start(ctx, s.name+"."+method, trace.WithAttributes(attrs...))
+}
+
+type tracedCustomerRepository struct {
+	next domain.CustomerRepository
+	spanner
+}
+
+// NewTracedCustomerRepository wraps a customer repository so its operations are traced
+func NewTracedCustomerRepository(r domain.CustomerRepository, tp trace.TracerProvider) domain.CustomerRepository {
+	return &tracedCustomerRepository{next: r, spanner: newSpanner(tp, "CustomerRepository", "customer")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedCustomerRepository) GetByID(ctx context.Context, id string) (*domain.Customer, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("customer.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedCustomerRepository) Create(ctx context.Context, customer *domain.Customer) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, customer)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedCustomerRepository) Update(ctx context.Context, customer *domain.Customer) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, customer)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedFundRepository struct {
+	next domain.FundRepository
+	spanner
+}
+
+// NewTracedFundRepository wraps a fund repository so its operations are traced
+func NewTracedFundRepository(r domain.FundRepository, tp trace.TracerProvider) domain.FundRepository {
+	return &tracedFundRepository{next: r, spanner: newSpanner(tp, "FundRepository", "fund")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedFundRepository) GetByID(ctx context.Context, id string) (*domain.Fund, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("fund.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetAll traces GetAll on the wrapped repository
+func (r *tracedFundRepository) GetAll(ctx context.Context) ([]*domain.Fund, error) {
+	ctx, span := r.start(ctx, "get_all", "GetAll")
+	result, err := r.next.GetAll(ctx)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Find traces Find on the wrapped repository
+func (r *tracedFundRepository) Find(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	ctx, span := r.start(ctx, "find", "Find")
+	result, total, err := r.next.Find(ctx, filter)
+	tracing.End(span, err)
+	return result, total, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedFundRepository) Create(ctx context.Context, fund *domain.Fund) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, fund)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedFundRepository) Update(ctx context.Context, fund *domain.Fund) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, fund)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedInvestmentRepository struct {
+	next domain.InvestmentRepository
+	spanner
+}
+
+// NewTracedInvestmentRepository wraps an investment repository so its operations are traced
+func NewTracedInvestmentRepository(r domain.InvestmentRepository, tp trace.TracerProvider) domain.InvestmentRepository {
+	return &tracedInvestmentRepository{next: r, spanner: newSpanner(tp, "InvestmentRepository", "investment")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedInvestmentRepository) GetByID(ctx context.Context, id string) (*domain.Investment, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("investment.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByCustomerID traces GetByCustomerID on the wrapped repository
+func (r *tracedInvestmentRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	ctx, span := r.start(ctx, "get_by_customer_id", "GetByCustomerID", attribute.String("customer.id", customerID))
+	result, err := r.next.GetByCustomerID(ctx, customerID)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByFundID traces GetByFundID on the wrapped repository
+func (r *tracedInvestmentRepository) GetByFundID(ctx context.Context, fundID string) ([]*domain.Investment, error) {
+	ctx, span := r.start(ctx, "get_by_fund_id", "GetByFundID", attribute.String("fund.id", fundID))
+	result, err := r.next.GetByFundID(ctx, fundID)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Find traces Find on the wrapped repository
+func (r *tracedInvestmentRepository) Find(ctx context.Context, filter domain.InvestmentFilter) ([]*domain.Investment, error) {
+	ctx, span := r.start(ctx, "find", "Find")
+	result, err := r.next.Find(ctx, filter)
+	tracing.End(span, err)
+	return result, err
+}
+
+// OldestUnpriced traces OldestUnpriced on the wrapped repository
+func (r *tracedInvestmentRepository) OldestUnpriced(ctx context.Context) (*domain.Investment, error) {
+	ctx, span := r.start(ctx, "oldest_unpriced", "OldestUnpriced")
+	result, err := r.next.OldestUnpriced(ctx)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedInvestmentRepository) Create(ctx context.Context, investment *domain.Investment) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, investment)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedInvestmentRepository) Update(ctx context.Context, investment *domain.Investment) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, investment)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedInvestmentEventRepository struct {
+	next domain.InvestmentEventRepository
+	spanner
+}
+
+// NewTracedInvestmentEventRepository wraps an investment event repository so its operations are traced
+func NewTracedInvestmentEventRepository(r domain.InvestmentEventRepository, tp trace.TracerProvider) domain.InvestmentEventRepository {
+	return &tracedInvestmentEventRepository{next: r, spanner: newSpanner(tp, "InvestmentEventRepository", "investment_event")}
+}
+
+// Append traces Append on the wrapped repository
+func (r *tracedInvestmentEventRepository) Append(ctx context.Context, event *domain.InvestmentEvent) error {
+	ctx, span := r.start(ctx, "append", "Append")
+	err := r.next.Append(ctx, event)
+	tracing.End(span, err)
+	return err
+}
+
+// GetByInvestmentID traces GetByInvestmentID on the wrapped repository
+func (r *tracedInvestmentEventRepository) GetByInvestmentID(ctx context.Context, investmentID string) ([]*domain.InvestmentEvent, error) {
+	ctx, span := r.start(ctx, "get_by_investment_id", "GetByInvestmentID", attribute.String("investment.id", investmentID))
+	result, err := r.next.GetByInvestmentID(ctx, investmentID)
+	tracing.End(span, err)
+	return result, err
+}
+
+type tracedSwitchRepository struct {
+	next domain.SwitchRepository
+	spanner
+}
+
+// NewTracedSwitchRepository wraps a switch repository so its operations are traced
+func NewTracedSwitchRepository(r domain.SwitchRepository, tp trace.TracerProvider) domain.SwitchRepository {
+	return &tracedSwitchRepository{next: r, spanner: newSpanner(tp, "SwitchRepository", "switch")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedSwitchRepository) GetByID(ctx context.Context, id string) (*domain.Switch, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("switch.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByCustomerID traces GetByCustomerID on the wrapped repository
+func (r *tracedSwitchRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Switch, error) {
+	ctx, span := r.start(ctx, "get_by_customer_id", "GetByCustomerID", attribute.String("customer.id", customerID))
+	result, err := r.next.GetByCustomerID(ctx, customerID)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedSwitchRepository) Create(ctx context.Context, sw *domain.Switch) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, sw)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedSwitchRepository) Update(ctx context.Context, sw *domain.Switch) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, sw)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedPlanRepository struct {
+	next domain.PlanRepository
+	spanner
+}
+
+// NewTracedPlanRepository wraps a plan repository so its operations are traced
+func NewTracedPlanRepository(r domain.PlanRepository, tp trace.TracerProvider) domain.PlanRepository {
+	return &tracedPlanRepository{next: r, spanner: newSpanner(tp, "PlanRepository", "plan")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedPlanRepository) GetByID(ctx context.Context, id string) (*domain.Plan, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("plan.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByCustomerID traces GetByCustomerID on the wrapped repository
+func (r *tracedPlanRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	ctx, span := r.start(ctx, "get_by_customer_id", "GetByCustomerID", attribute.String("customer.id", customerID))
+	result, err := r.next.GetByCustomerID(ctx, customerID)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetDue traces GetDue on the wrapped repository
+func (r *tracedPlanRepository) GetDue(ctx context.Context, at time.Time) ([]*domain.Plan, error) {
+	ctx, span := r.start(ctx, "get_due", "GetDue")
+	result, err := r.next.GetDue(ctx, at)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedPlanRepository) Create(ctx context.Context, plan *domain.Plan) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, plan)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedPlanRepository) Update(ctx context.Context, plan *domain.Plan) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, plan)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedLedgerRepository struct {
+	next domain.LedgerRepository
+	spanner
+}
+
+// NewTracedLedgerRepository wraps a ledger repository so its operations are traced
+func NewTracedLedgerRepository(r domain.LedgerRepository, tp trace.TracerProvider) domain.LedgerRepository {
+	return &tracedLedgerRepository{next: r, spanner: newSpanner(tp, "LedgerRepository", "ledger")}
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedLedgerRepository) Create(ctx context.Context, journal *domain.Journal) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, journal)
+	tracing.End(span, err)
+	return err
+}
+
+// GetByAccount traces GetByAccount on the wrapped repository
+func (r *tracedLedgerRepository) GetByAccount(ctx context.Context, account domain.LedgerAccount) ([]*domain.Journal, error) {
+	ctx, span := r.start(ctx, "get_by_account", "GetByAccount")
+	result, err := r.next.GetByAccount(ctx, account)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByCustomerID traces GetByCustomerID on the wrapped repository
+func (r *tracedLedgerRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Journal, error) {
+	ctx, span := r.start(ctx, "get_by_customer_id", "GetByCustomerID", attribute.String("customer.id", customerID))
+	result, err := r.next.GetByCustomerID(ctx, customerID)
+	tracing.End(span, err)
+	return result, err
+}
+
+type tracedAuditRepository struct {
+	next domain.AuditRepository
+	spanner
+}
+
+// NewTracedAuditRepository wraps an audit repository so its operations are traced
+func NewTracedAuditRepository(r domain.AuditRepository, tp trace.TracerProvider) domain.AuditRepository {
+	return &tracedAuditRepository{next: r, spanner: newSpanner(tp, "AuditRepository", "audit")}
+}
+
+// Append traces Append on the wrapped repository
+func (r *tracedAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	ctx, span := r.start(ctx, "append", "Append")
+	err := r.next.Append(ctx, entry)
+	tracing.End(span, err)
+	return err
+}
+
+// GetAll traces GetAll on the wrapped repository
+func (r *tracedAuditRepository) GetAll(ctx context.Context) ([]*domain.AuditEntry, error) {
+	ctx, span := r.start(ctx, "get_all", "GetAll")
+	result, err := r.next.GetAll(ctx)
+	tracing.End(span, err)
+	return result, err
+}
+
+type tracedApprovalRepository struct {
+	next domain.ApprovalRepository
+	spanner
+}
+
+// NewTracedApprovalRepository wraps an approval repository so its operations are traced
+func NewTracedApprovalRepository(r domain.ApprovalRepository, tp trace.TracerProvider) domain.ApprovalRepository {
+	return &tracedApprovalRepository{next: r, spanner: newSpanner(tp, "ApprovalRepository", "approval")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedApprovalRepository) GetByID(ctx context.Context, id string) (*domain.Approval, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("approval.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// List traces List on the wrapped repository
+func (r *tracedApprovalRepository) List(ctx context.Context, status domain.ApprovalStatus) ([]*domain.Approval, error) {
+	ctx, span := r.start(ctx, "list", "List")
+	result, err := r.next.List(ctx, status)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedApprovalRepository) Create(ctx context.Context, approval *domain.Approval) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, approval)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedApprovalRepository) Update(ctx context.Context, approval *domain.Approval) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, approval)
+	tracing.End(span, err)
+	return err
+}
+
+type tracedAPIKeyRepository struct {
+	next domain.APIKeyRepository
+	spanner
+}
+
+// NewTracedAPIKeyRepository wraps an API key repository so its operations are traced
+func NewTracedAPIKeyRepository(r domain.APIKeyRepository, tp trace.TracerProvider) domain.APIKeyRepository {
+	return &tracedAPIKeyRepository{next: r, spanner: newSpanner(tp, "APIKeyRepository", "api_key")}
+}
+
+// GetByID traces GetByID on the wrapped repository
+func (r *tracedAPIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	ctx, span := r.start(ctx, "get_by_id", "GetByID", attribute.String("api_key.id", id))
+	result, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return result, err
+}
+
+// GetByPartnerID traces GetByPartnerID on the wrapped repository
+func (r *tracedAPIKeyRepository) GetByPartnerID(ctx context.Context, partnerID string) ([]*domain.APIKey, error) {
+	ctx, span := r.start(ctx, "get_by_partner_id", "GetByPartnerID", attribute.String("partner.id", partnerID))
+	result, err := r.next.GetByPartnerID(ctx, partnerID)
+	tracing.End(span, err)
+	return result, err
+}
+
+// Create traces Create on the wrapped repository
+func (r *tracedAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	ctx, span := r.start(ctx, "create", "Create")
+	err := r.next.Create(ctx, key)
+	tracing.End(span, err)
+	return err
+}
+
+// Update traces Update on the wrapped repository
+func (r *tracedAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	ctx, span := r.start(ctx, "update", "Update")
+	err := r.next.Update(ctx, key)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,41 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Create starts at version 1 and each update bumps it", func(t *testing.T) {
+		repo := repository.NewInMemoryInvestmentRepository()
+		investment := &domain.Investment{ID: "inv-1", CustomerID: "customer-1", FundID: "fund-1", Amount: 10000, Status: domain.InvestmentStatusPending}
+		assert.NoError(t, repo.Create(ctx, investment))
+		assert.Equal(t, int64(1), investment.Version)
+
+		investment.Status = domain.InvestmentStatusProcessed
+		assert.NoError(t, repo.Update(ctx, investment))
+		assert.Equal(t, int64(2), investment.Version)
+
+		stored, _ := repo.GetByID(ctx, "inv-1")
+		assert.Equal(t, int64(2), stored.Version)
+	})
+
+	t.Run("Updating a stale copy fails with a conflict", func(t *testing.T) {
+		repo := repository.NewInMemoryFundRepository()
+		first, _ := repo.GetByID(ctx, "fund-1")
+		second, _ := repo.GetByID(ctx, "fund-1")
+
+		first.Name = "First writer"
+		assert.NoError(t, repo.Update(ctx, first))
+
+		second.Name = "Second writer"
+		assert.ErrorIs(t, repo.Update(ctx, second), domain.ErrConflict)
+
+		stored, _ := repo.GetByID(ctx, "fund-1")
+		assert.Equal(t, "First writer", stored.Name)
+	})
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// PlanScheduler periodically executes regular contribution plans that have fallen due
+type PlanScheduler struct {
+	PlanService domain.PlanService
+	Interval    time.Duration
+	Logger      *slog.Logger
+	done        chan struct{}
+	// running is set while plans are being run
+	running atomic.Bool
+	// lastRun is when a run last finished, in Unix nanoseconds
+	lastRun atomic.Int64
+}
+
+// NewPlanScheduler creates a new plan scheduler checking for due plans every interval
+func NewPlanScheduler(ps domain.PlanService, interval time.Duration, logger *slog.Logger) *PlanScheduler {
+	return &PlanScheduler{
+		PlanService: ps,
+		Interval:    interval,
+		Logger:      logger,
+		done:        make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled. Plans are
+// run with ctx, so it should carry the principal the scheduler acts as.
+func (s *PlanScheduler) Start(ctx context.Context) {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		s.run(ctx, time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.run(ctx, now)
+			}
+		}
+	}()
+}
+
+// Done is closed once the scheduler has stopped
+func (s *PlanScheduler) Done() <-chan struct{} {
+	return s.done
+}
+
+// run runs the plans due now. A run stopped by the scheduler stopping is not an error.
+func (s *PlanScheduler) run(ctx context.Context, now time.Time) {
+	s.running.Store(true)
+	defer func() {
+		s.lastRun.Store(time.Now().UnixNano())
+		s.running.Store(false)
+	}()
+
+	if err := s.PlanService.RunDuePlans(ctx, now); err != nil && ctx.Err() == nil {
+		s.Logger.ErrorContext(ctx, "running due plans", "error", err)
+	}
+}
+
+// Check reports the scheduler unhealthy once it has stopped, or when it has
+// missed a run, as plans are then not being collected. A run in progress is
+// healthy however long it takes, as a busy collection day can outlast the interval.
+func (s *PlanScheduler) Check(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return errors.New("plan scheduler has stopped")
+	default:
+	}
+	if s.running.Load() {
+		return nil
+	}
+
+	lastRun := s.lastRun.Load()
+	if lastRun == 0 {
+		return errors.New("plan scheduler has not started")
+	}
+	if since := time.Since(time.Unix(0, lastRun)); since > 2*s.Interval {
+		return fmt.Errorf("plan scheduler last ran %s ago", since.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package scheduler_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// blockingPlanService holds each run of due plans until it is released or stopped
+type blockingPlanService struct {
+	domain.PlanService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingPlanService) RunDuePlans(ctx context.Context, now time.Time) error {
+	select {
+	case s.started <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestPlanSchedulerCheck(t *testing.T) {
+	planService := &blockingPlanService{started: make(chan struct{}), release: make(chan struct{})}
+	planScheduler := scheduler.NewPlanScheduler(planService, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	planScheduler.Start(ctx)
+
+	// A run taking longer than the interval is still healthy
+	<-planService.started
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, planScheduler.Check(ctx))
+
+	// Once it finishes, the time since it finished is checked
+	planService.release <- struct{}{}
+	<-planService.started
+	assert.NoError(t, planScheduler.Check(ctx))
+
+	cancel()
+	<-planScheduler.Done()
+	assert.ErrorContains(t, planScheduler.Check(context.Background()), "stopped")
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"slices"
+	"strings"
+	"time"
+)
+
+// apiKeyRotationGrace is how long a rotated key keeps working alongside its replacement
+const apiKeyRotationGrace = 24 * time.Hour
+
+type apiKeyService struct {
+	apiKeyRepo   domain.APIKeyRepository
+	customerRepo domain.CustomerRepository
+}
+
+// NewAPIKeyService creates a new instance of API key service
+func NewAPIKeyService(kr domain.APIKeyRepository, cr domain.CustomerRepository) domain.APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo:   kr,
+		customerRepo: cr,
+	}
+}
+
+// IssueAPIKey issues a partner a new key with the settings
+func (s *apiKeyService) IssueAPIKey(ctx context.Context, partnerID string, settings domain.APIKeySettings) (*domain.IssuedAPIKey, error) {
+	if strings.TrimSpace(partnerID) == "" {
+		return nil, errors.New("partner ID is required")
+	}
+	if err := s.validateSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	key := &domain.APIKey{
+		ID:                uuid.New().String(),
+		PartnerID:         partnerID,
+		Name:              settings.Name,
+		Scopes:            settings.Scopes,
+		CustomerIDs:       settings.CustomerIDs,
+		SignatureRequired: settings.SignatureRequired,
+	}
+	return s.issue(ctx, key)
+}
+
+// validateSettings checks a key is named, only has scopes partners can be
+// granted and is only linked to customers that exist
+func (s *apiKeyService) validateSettings(ctx context.Context, settings domain.APIKeySettings) error {
+	if strings.TrimSpace(settings.Name) == "" {
+		return errors.New("key name is required")
+	}
+	if len(settings.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range settings.Scopes {
+		if !slices.Contains(auth.PartnerScopes, auth.Permission(scope)) {
+			return fmt.Errorf("scope %q cannot be granted to partners", scope)
+		}
+	}
+	for _, customerID := range settings.CustomerIDs {
+		if _, err := s.customerRepo.GetByID(ctx, customerID); err != nil {
+			return fmt.Errorf("linked customer %s: %w", customerID, err)
+		}
+	}
+	return nil
+}
+
+// issue generates the secrets for a new key and stores it
+func (s *apiKeyService) issue(ctx context.Context, key *domain.APIKey) (*domain.IssuedAPIKey, error) {
+	secret, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	signingSecret, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	key.Status = domain.APIKeyStatusActive
+	key.SecretHash = hashSecret(secret)
+	key.SigningSecret = signingSecret
+	key.CreatedBy = actor(ctx)
+	key.CreatedAt = time.Now()
+
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return &domain.IssuedAPIKey{
+		APIKey:        key,
+		Key:           key.ID + "." + secret,
+		SigningSecret: signingSecret,
+	}, nil
+}
+
+// RotateAPIKey issues a replacement with the same settings and expires the old
+// key once the grace period is over
+func (s *apiKeyService) RotateAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.IssuedAPIKey, error) {
+	old, err := s.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(old.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if old.Status != domain.APIKeyStatusActive || old.ReplacedBy != "" {
+		return nil, domain.ErrAPIKeyNotActive
+	}
+
+	// The old key is updated first, so a concurrent rotation fails rather than
+	// issuing a second replacement, and then the replacement is issued even if
+	// the caller goes away
+	replacement := &domain.APIKey{
+		ID:                uuid.New().String(),
+		PartnerID:         old.PartnerID,
+		Name:              old.Name,
+		Scopes:            old.Scopes,
+		CustomerIDs:       old.CustomerIDs,
+		SignatureRequired: old.SignatureRequired,
+	}
+	expiresAt := time.Now().Add(apiKeyRotationGrace)
+	old.ExpiresAt = &expiresAt
+	old.ReplacedBy = replacement.ID
+	if err := s.apiKeyRepo.Update(ctx, old); err != nil {
+		return nil, err
+	}
+
+	return s.issue(context.WithoutCancel(ctx), replacement)
+}
+
+// RevokeAPIKey stops a key working, including one in its rotation grace period
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(key.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if key.Status != domain.APIKeyStatusActive {
+		return nil, domain.ErrAPIKeyNotActive
+	}
+
+	now := time.Now()
+	key.Status = domain.APIKeyStatusRevoked
+	key.RevokedAt = &now
+
+	if err := s.apiKeyRepo.Update(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetAPIKey gets an API key by ID
+func (s *apiKeyService) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	return s.apiKeyRepo.GetByID(ctx, id)
+}
+
+// GetPartnerAPIKeys gets every key issued to a partner, including revoked ones
+func (s *apiKeyService) GetPartnerAPIKeys(ctx context.Context, partnerID string) ([]*domain.APIKey, error) {
+	return s.apiKeyRepo.GetByPartnerID(ctx, partnerID)
+}
+
+// AuthenticateAPIKey checks a presented key of the form <key ID>.<secret>.
+// Every failure is ErrInvalidAPIKey, so callers learn nothing about which keys exist.
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, presented string) (*domain.APIKey, error) {
+	id, secret, ok := strings.Cut(presented, ".")
+	if !ok || secret == "" {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, domain.ErrInvalidAPIKey
+	}
+	if !key.Usable(time.Now()) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+// randomSecret returns 256 random bits, URL-safe encoded
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret is what is stored for a key's secret. The secret is random, so an
+// unsalted hash is enough.
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
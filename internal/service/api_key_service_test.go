@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	apiKeyService := service.NewAuthorizedAPIKeyService(
+		service.NewAPIKeyService(repository.NewInMemoryAPIKeyRepository(), repository.NewInMemoryCustomerRepository()),
+	)
+	admin := as("admin-1", auth.RoleAdmin)
+	settings := domain.APIKeySettings{
+		Name:        "Payroll integration",
+		Scopes:      []string{string(auth.PermFundsRead), string(auth.PermInvestmentsCreate)},
+		CustomerIDs: []string{"customer-1"},
+	}
+
+	t.Run("Only admins issue keys", func(t *testing.T) {
+		_, err := apiKeyService.IssueAPIKey(as("ops-1", auth.RoleOperations), "acme", settings)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("Keys are only issued with partner scopes and known customers", func(t *testing.T) {
+		invalid := settings
+		invalid.Scopes = []string{string(auth.PermFundsManage)}
+		_, err := apiKeyService.IssueAPIKey(admin, "acme", invalid)
+		assert.Error(t, err)
+
+		invalid = settings
+		invalid.CustomerIDs = []string{"customer-unknown"}
+		_, err = apiKeyService.IssueAPIKey(admin, "acme", invalid)
+		assert.Error(t, err)
+	})
+
+	issued, err := apiKeyService.IssueAPIKey(admin, "acme", settings)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin-1", issued.CreatedBy)
+
+	t.Run("Only the issued key authenticates", func(t *testing.T) {
+		key, err := apiKeyService.AuthenticateAPIKey(admin, issued.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, issued.ID, key.ID)
+		assert.Equal(t, issued.SigningSecret, key.SigningSecret)
+
+		_, err = apiKeyService.AuthenticateAPIKey(admin, issued.ID+".wrong-secret")
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+		_, err = apiKeyService.AuthenticateAPIKey(admin, issued.ID)
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+	})
+
+	var replacement *domain.IssuedAPIKey
+	t.Run("Rotated keys work until their grace period ends", func(t *testing.T) {
+		_, err := apiKeyService.RotateAPIKey(admin, issued.ID, issued.Version+1)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		replacement, err = apiKeyService.RotateAPIKey(admin, issued.ID, issued.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, settings.Scopes, replacement.Scopes)
+		assert.NotEqual(t, issued.Key, replacement.Key)
+
+		_, err = apiKeyService.AuthenticateAPIKey(admin, replacement.Key)
+		assert.NoError(t, err)
+
+		old, err := apiKeyService.AuthenticateAPIKey(admin, issued.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, replacement.ID, old.ReplacedBy)
+		assert.False(t, old.Usable(time.Now().Add(25*time.Hour)))
+
+		_, err = apiKeyService.RotateAPIKey(admin, issued.ID, old.Version)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotActive)
+	})
+
+	t.Run("Revoked keys stop working straight away", func(t *testing.T) {
+		revoked, err := apiKeyService.RevokeAPIKey(admin, replacement.ID, replacement.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.APIKeyStatusRevoked, revoked.Status)
+
+		_, err = apiKeyService.AuthenticateAPIKey(admin, replacement.Key)
+		assert.ErrorIs(t, err, domain.ErrInvalidAPIKey)
+
+		_, err = apiKeyService.RevokeAPIKey(admin, replacement.ID, revoked.Version)
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotActive)
+	})
+
+	keys, err := apiKeyService.GetPartnerAPIKeys(admin, "acme")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
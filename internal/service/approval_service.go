@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"strings"
+	"time"
+)
+
+type approvalService struct {
+	approvalRepo      domain.ApprovalRepository
+	investmentService domain.InvestmentService
+	customerService   domain.CustomerService
+}
+
+// NewApprovalService creates a new instance of approval service. Approved changes
+// are made through the investment and customer services as the approver, so
+// they should be the audited services rather than the authorized ones, which
+// refuse these changes outside of an approval.
+func NewApprovalService(ar domain.ApprovalRepository, is domain.InvestmentService, cs domain.CustomerService) domain.ApprovalService {
+	return &approvalService{
+		approvalRepo:      ar,
+		investmentService: is,
+		customerService:   cs,
+	}
+}
+
+// RequestInvestmentCancellation records a pending request to cancel a processed investment
+func (s *approvalService) RequestInvestmentCancellation(ctx context.Context, investmentID string, expectedVersion int64, reason string) (*domain.Approval, error) {
+	investment, err := s.investmentService.GetInvestment(ctx, investmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(investment.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	switch {
+	case investment.Status != domain.InvestmentStatusProcessed:
+		return nil, fmt.Errorf("%w: only processed investments are cancelled through approval", domain.ErrInvestmentNotCancellable)
+	case investment.SwitchID != "":
+		return nil, fmt.Errorf("%w: switch legs cannot be cancelled individually", domain.ErrInvestmentNotCancellable)
+	}
+
+	return s.request(ctx, &domain.Approval{
+		Action:        domain.ApprovalActionCancelInvestment,
+		EntityID:      investment.ID,
+		EntityVersion: investment.Version,
+		CustomerID:    investment.CustomerID,
+		Reason:        reason,
+	})
+}
+
+// RequestCustomerUpdate records a pending request to change a customer's details
+func (s *approvalService) RequestCustomerUpdate(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64, reason string) (*domain.Approval, error) {
+	if err := validateCustomerDetails(details); err != nil {
+		return nil, err
+	}
+
+	customer, err := s.customerService.GetCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(customer.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	return s.request(ctx, &domain.Approval{
+		Action:          domain.ApprovalActionUpdateCustomer,
+		EntityID:        customer.ID,
+		EntityVersion:   customer.Version,
+		CustomerID:      customer.ID,
+		CustomerDetails: &details,
+		Reason:          reason,
+	})
+}
+
+// request stores a new pending approval requested by the caller
+func (s *approvalService) request(ctx context.Context, approval *domain.Approval) (*domain.Approval, error) {
+	requester, err := identifiedActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(approval.Reason) == "" {
+		return nil, errors.New("a reason for the change is required")
+	}
+
+	approval.ID = uuid.New().String()
+	approval.Status = domain.ApprovalStatusPending
+	approval.RequestedBy = requester
+	approval.RequestedAt = time.Now()
+
+	if err := s.approvalRepo.Create(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	return approval, nil
+}
+
+// Approve decides in favour of a pending approval and makes its change. The
+// decision is stored before the change is made, so only one approver can make
+// it. If the change then fails, the approval is returned with the failed status
+// and the reason in Error rather than as an error. Once approved, the change is
+// made even if the caller goes away, so the approval is never left unapplied.
+func (s *approvalService) Approve(ctx context.Context, id string) (*domain.Approval, error) {
+	approval, err := s.decide(ctx, id, domain.ApprovalStatusApproved, "")
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	if err := s.apply(ctx, approval); err != nil {
+		approval.Status = domain.ApprovalStatusFailed
+		approval.Error = err.Error()
+		if err := s.approvalRepo.Update(ctx, approval); err != nil {
+			return nil, err
+		}
+	}
+
+	return approval, nil
+}
+
+// Reject decides against a pending approval, leaving the entity unchanged
+func (s *approvalService) Reject(ctx context.Context, id, note string) (*domain.Approval, error) {
+	return s.decide(ctx, id, domain.ApprovalStatusRejected, note)
+}
+
+// decide records the caller's decision on a pending approval requested by someone else
+func (s *approvalService) decide(ctx context.Context, id string, status domain.ApprovalStatus, note string) (*domain.Approval, error) {
+	decider, err := identifiedActor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	approval, err := s.approvalRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != domain.ApprovalStatusPending {
+		return nil, fmt.Errorf("%w: it is %s", domain.ErrApprovalNotPending, approval.Status)
+	}
+	if approval.RequestedBy == decider {
+		return nil, domain.ErrSelfApproval
+	}
+
+	now := time.Now()
+	approval.Status = status
+	approval.DecidedBy = decider
+	approval.DecidedAt = &now
+	approval.DecisionNote = note
+
+	if err := s.approvalRepo.Update(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	return approval, nil
+}
+
+// apply makes the change an approval was requested for
+func (s *approvalService) apply(ctx context.Context, approval *domain.Approval) error {
+	var err error
+	switch approval.Action {
+	case domain.ApprovalActionCancelInvestment:
+		_, err = s.investmentService.CancelInvestment(ctx, approval.EntityID, approval.EntityVersion)
+	case domain.ApprovalActionUpdateCustomer:
+		_, err = s.customerService.UpdateCustomerDetails(ctx, approval.EntityID, *approval.CustomerDetails, approval.EntityVersion)
+	default:
+		err = fmt.Errorf("unknown approval action %q", approval.Action)
+	}
+	return err
+}
+
+// GetApproval gets an approval by ID
+func (s *approvalService) GetApproval(ctx context.Context, id string) (*domain.Approval, error) {
+	return s.approvalRepo.GetByID(ctx, id)
+}
+
+// ListApprovals gets the approvals with a status, or every approval when it is empty
+func (s *approvalService) ListApprovals(ctx context.Context, status domain.ApprovalStatus) ([]*domain.Approval, error) {
+	return s.approvalRepo.List(ctx, status)
+}
+
+// identifiedActor is the caller in ctx, who must be known for the four-eyes check to mean anything
+func identifiedActor(ctx context.Context) (string, error) {
+	subject := actor(ctx)
+	if subject == domain.AnonymousActor {
+		return "", fmt.Errorf("%w: approvals need an authenticated user", domain.ErrForbidden)
+	}
+	return subject, nil
+}
@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApprovalWorkflow(t *testing.T) {
+	customerService := service.NewCustomerService(repository.NewInMemoryCustomerRepository())
+	investmentService := service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	)
+	approvalService := service.NewAuthorizedApprovalService(
+		service.NewApprovalService(repository.NewInMemoryApprovalRepository(), investmentService, customerService),
+	)
+	authorizedInvestments := service.NewAuthorizedInvestmentService(investmentService)
+	requester := as("ops-1", auth.RoleOperations)
+	approver := as("ops-2", auth.RoleOperations)
+	support := as("support-1", auth.RoleSupport)
+
+	// A processed £1,000 subscription
+	ctx := context.Background()
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	assert.NoError(t, err)
+	investment, err = investmentService.PriceInvestment(ctx, investment.ID, 250, investment.Version)
+	assert.NoError(t, err)
+	investment, err = investmentService.ProcessInvestment(ctx, investment.ID, investment.Version)
+	assert.NoError(t, err)
+
+	t.Run("Staff cannot cancel processed investments directly", func(t *testing.T) {
+		_, err := authorizedInvestments.CancelInvestment(requester, investment.ID, investment.Version)
+		assert.ErrorIs(t, err, domain.ErrApprovalRequired)
+	})
+
+	var approval *domain.Approval
+	t.Run("A cancellation is requested", func(t *testing.T) {
+		_, err := approvalService.RequestInvestmentCancellation(requester, investment.ID, investment.Version, "")
+		assert.Error(t, err, "a reason is required")
+		_, err = approvalService.RequestInvestmentCancellation(requester, investment.ID, investment.Version-1, "Mis-sold")
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		approval, err = approvalService.RequestInvestmentCancellation(requester, investment.ID, investment.Version, "Mis-sold")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalStatusPending, approval.Status)
+		assert.Equal(t, "ops-1", approval.RequestedBy)
+
+		unchanged, _ := investmentService.GetInvestment(ctx, investment.ID)
+		assert.Equal(t, domain.InvestmentStatusProcessed, unchanged.Status)
+	})
+
+	t.Run("The requester and users without approvals:decide cannot approve", func(t *testing.T) {
+		_, err := approvalService.Approve(requester, approval.ID)
+		assert.ErrorIs(t, err, domain.ErrSelfApproval)
+		_, err = approvalService.Approve(support, approval.ID)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("A second user's approval makes the change", func(t *testing.T) {
+		approved, err := approvalService.Approve(approver, approval.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalStatusApproved, approved.Status)
+		assert.Equal(t, "ops-2", approved.DecidedBy)
+
+		cancelled, _ := investmentService.GetInvestment(ctx, investment.ID)
+		assert.Equal(t, domain.InvestmentStatusCancelled, cancelled.Status)
+
+		_, err = approvalService.Reject(approver, approval.ID, "Too late")
+		assert.ErrorIs(t, err, domain.ErrApprovalNotPending)
+	})
+
+	t.Run("Customer details change only through approval", func(t *testing.T) {
+		customers := service.NewAuthorizedCustomerService(customerService)
+		customer, _ := customerService.GetCustomer(ctx, "customer-1")
+		details := domain.CustomerDetails{Name: "Jane Smith", Email: "jane.smith@example.com"}
+
+		_, err := customers.UpdateCustomerDetails(requester, customer.ID, details, customer.Version)
+		assert.ErrorIs(t, err, domain.ErrApprovalRequired)
+
+		_, err = approvalService.RequestCustomerUpdate(support, customer.ID, domain.CustomerDetails{Name: "Jane", Email: "not an email"}, customer.Version, "Married")
+		assert.Error(t, err)
+
+		rejected, err := approvalService.RequestCustomerUpdate(support, customer.ID, details, customer.Version, "Married")
+		assert.NoError(t, err)
+		rejected, err = approvalService.Reject(approver, rejected.ID, "Needs ID check")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalStatusRejected, rejected.Status)
+
+		approval, err := approvalService.RequestCustomerUpdate(support, customer.ID, details, customer.Version, "Married")
+		assert.NoError(t, err)
+		approved, err := approvalService.Approve(approver, approval.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalStatusApproved, approved.Status)
+
+		updated, _ := customerService.GetCustomer(ctx, customer.ID)
+		assert.Equal(t, "Jane Smith", updated.Name)
+		assert.Equal(t, "jane.smith@example.com", updated.Email)
+	})
+
+	t.Run("Approvals of entities changed since the request fail", func(t *testing.T) {
+		customer, _ := customerService.GetCustomer(ctx, "customer-1")
+		details := domain.CustomerDetails{Name: "Jane Jones", Email: "jane.jones@example.com"}
+		first, err := approvalService.RequestCustomerUpdate(support, customer.ID, details, customer.Version, "Married")
+		assert.NoError(t, err)
+		second, err := approvalService.RequestCustomerUpdate(support, customer.ID, details, customer.Version, "Duplicate")
+		assert.NoError(t, err)
+
+		_, err = approvalService.Approve(approver, first.ID)
+		assert.NoError(t, err)
+		failed, err := approvalService.Approve(approver, second.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalStatusFailed, failed.Status)
+		assert.NotEmpty(t, failed.Error)
+	})
+
+	t.Run("The approval trail is listable", func(t *testing.T) {
+		all, err := approvalService.ListApprovals(support, "")
+		assert.NoError(t, err)
+		assert.Len(t, all, 5)
+		assert.Equal(t, domain.ApprovalActionCancelInvestment, all[0].Action)
+
+		failed, err := approvalService.ListApprovals(support, domain.ApprovalStatusFailed)
+		assert.NoError(t, err)
+		assert.Len(t, failed, 1)
+
+		_, err = approvalService.ListApprovals(as("customer-1", auth.RoleCustomer), "")
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+}
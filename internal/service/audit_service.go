@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+	"time"
+)
+
+type auditService struct {
+	// mutex serialises writes so each entry links to the one before it
+	mutex     sync.Mutex
+	auditRepo domain.AuditRepository
+	loaded    bool
+	last      *domain.AuditEntry
+	// queued holds entries for changes already made that are still to be written, oldest first
+	queued []*domain.AuditEntry
+	// queuedErr is why queued entries could not be written when last tried
+	queuedErr error
+}
+
+// NewAuditService creates a new instance of audit service
+func NewAuditService(ar domain.AuditRepository) domain.AuditService {
+	return &auditService{
+		auditRepo: ar,
+	}
+}
+
+// Record appends an entry to the audit log, chained to the entry before it,
+// once any queued entries have been written
+func (as *auditService) Record(ctx context.Context, actor, action, entityType, entityID string, before, after interface{}) (*domain.AuditEntry, error) {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if err := as.flush(ctx); err != nil {
+		return nil, err
+	}
+	entry := newAuditEntry(actor, action, entityType, entityID, beforeJSON, afterJSON)
+	if err := as.write(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Enqueue queues an entry for a change already made and writes every queued
+// entry it can. A snapshot that cannot be encoded is left out of the entry
+// rather than the entry being lost.
+func (as *auditService) Enqueue(ctx context.Context, actor, action, entityType, entityID string, before, after interface{}) {
+	beforeJSON, _ := snapshot(before)
+	afterJSON, _ := snapshot(after)
+
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	as.queued = append(as.queued, newAuditEntry(actor, action, entityType, entityID, beforeJSON, afterJSON))
+	_ = as.flush(ctx)
+}
+
+// Flush writes any queued entries
+func (as *auditService) Flush(ctx context.Context) error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	return as.flush(ctx)
+}
+
+// Queued reports the entries still waiting to be written without trying to write them
+func (as *auditService) Queued() error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if len(as.queued) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d audit entries waiting to be written: %w", len(as.queued), as.queuedErr)
+}
+
+// flush writes queued entries in order, stopping at the first that cannot be written
+func (as *auditService) flush(ctx context.Context) error {
+	for len(as.queued) > 0 {
+		if err := as.write(ctx, as.queued[0]); err != nil {
+			as.queuedErr = err
+			return fmt.Errorf("%d audit entries waiting to be written: %w", len(as.queued), err)
+		}
+		as.queued[0] = nil
+		as.queued = as.queued[1:]
+	}
+	as.queuedErr = nil
+	return nil
+}
+
+// write chains an entry to the last one written and appends it to the log
+func (as *auditService) write(ctx context.Context, entry *domain.AuditEntry) error {
+	// Pick up the chain from entries already stored, such as those in an existing log file
+	if !as.loaded {
+		entries, err := as.auditRepo.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			as.last = entries[len(entries)-1]
+		}
+		as.loaded = true
+	}
+
+	entry.Sequence = 1
+	entry.PrevHash = ""
+	if as.last != nil {
+		entry.Sequence = as.last.Sequence + 1
+		entry.PrevHash = as.last.Hash
+	}
+	entry.Hash = entry.ComputeHash()
+
+	if err := as.auditRepo.Append(ctx, entry); err != nil {
+		return err
+	}
+	as.last = entry
+	return nil
+}
+
+// newAuditEntry creates an entry for a change made now, to be chained when it is written
+func newAuditEntry(actor, action, entityType, entityID string, before, after json.RawMessage) *domain.AuditEntry {
+	return &domain.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Actor:      actor,
+		Timestamp:  time.Now().UTC(),
+		Before:     before,
+		After:      after,
+	}
+}
+
+// GetEntries gets the whole audit log, oldest first
+func (as *auditService) GetEntries(ctx context.Context) ([]*domain.AuditEntry, error) {
+	return as.auditRepo.GetAll(ctx)
+}
+
+// Verify checks the hash chain of the stored audit log
+func (as *auditService) Verify(ctx context.Context) error {
+	entries, err := as.auditRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	return domain.VerifyAuditChain(entries)
+}
+
+// snapshot encodes an entity for the audit log, leaving nil snapshots empty
+// (including nil pointers, such as a failed lookup of the before state)
+func snapshot(entity interface{}) (json.RawMessage, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,231 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditLogHashChain(t *testing.T) {
+	ctx := context.Background()
+	auditService := service.NewAuditService(repository.NewInMemoryAuditRepository())
+
+	for _, amount := range []int64{100, 200, 300} {
+		_, err := auditService.Record(ctx, domain.AnonymousActor, "investment.created", "investment", "inv-1", nil, map[string]int64{"amount": amount})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, auditService.Verify(ctx))
+
+	entries, err := auditService.GetEntries(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Empty(t, entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+
+	t.Run("Editing an entry breaks the chain", func(t *testing.T) {
+		entries, _ := auditService.GetEntries(ctx)
+		entries[1].After = json.RawMessage(`{"amount":999}`)
+		assert.ErrorIs(t, domain.VerifyAuditChain(entries), domain.ErrAuditChainBroken)
+	})
+
+	t.Run("Removing an entry breaks the chain", func(t *testing.T) {
+		entries, _ := auditService.GetEntries(ctx)
+		entries = append(entries[:1], entries[2:]...)
+		assert.ErrorIs(t, domain.VerifyAuditChain(entries), domain.ErrAuditChainBroken)
+	})
+
+	t.Run("Rehashing an edited entry still breaks the chain", func(t *testing.T) {
+		entries, _ := auditService.GetEntries(ctx)
+		entries[1].Actor = "someone-else"
+		entries[1].Hash = entries[1].ComputeHash()
+		assert.ErrorIs(t, domain.VerifyAuditChain(entries), domain.ErrAuditChainBroken)
+	})
+}
+
+func TestAuditedServicesRecordChanges(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "customer-1", Roles: []auth.Role{auth.RoleCustomer}})
+	auditService := service.NewAuditService(repository.NewInMemoryAuditRepository())
+	investmentService := service.NewAuditedInvestmentService(
+		service.NewInvestmentService(
+			repository.NewInMemoryInvestmentRepository(),
+			repository.NewInMemoryInvestmentEventRepository(),
+			repository.NewInMemoryCustomerRepository(),
+			repository.NewInMemoryFundRepository(),
+			newLedgerService(),
+			domain.DefaultAllowanceRules,
+		),
+		auditService,
+	)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+	assert.NoError(t, err)
+	_, err = investmentService.CancelInvestment(ctx, investment.ID, investment.Version)
+	assert.NoError(t, err)
+
+	// Failed changes are not recorded
+	_, err = investmentService.CancelInvestment(ctx, investment.ID, 0)
+	assert.Error(t, err)
+
+	entries, err := auditService.GetEntries(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Equal(t, "investment.created", entries[0].Action)
+	assert.Nil(t, entries[0].Before)
+	assert.Equal(t, "investment.cancelled", entries[1].Action)
+	assert.Equal(t, investment.ID, entries[1].EntityID)
+	assert.Equal(t, "customer-1", entries[1].Actor)
+
+	var before, after domain.Investment
+	assert.NoError(t, json.Unmarshal(entries[1].Before, &before))
+	assert.NoError(t, json.Unmarshal(entries[1].After, &after))
+	assert.Equal(t, domain.InvestmentStatusPending, before.Status)
+	assert.Equal(t, domain.InvestmentStatusCancelled, after.Status)
+
+	assert.NoError(t, auditService.Verify(ctx))
+}
+
+// failingAuditRepository fails to append entries while failing is set
+type failingAuditRepository struct {
+	domain.AuditRepository
+	failing bool
+}
+
+func (r *failingAuditRepository) Append(ctx context.Context, entry *domain.AuditEntry) error {
+	if r.failing {
+		return errors.New("disk full")
+	}
+	return r.AuditRepository.Append(ctx, entry)
+}
+
+func TestAuditedChangesSucceedWhenTheLogCannotBeWritten(t *testing.T) {
+	ctx := context.Background()
+	auditRepo := &failingAuditRepository{AuditRepository: repository.NewInMemoryAuditRepository(), failing: true}
+	auditService := service.NewAuditService(auditRepo)
+	investmentService := service.NewAuditedInvestmentService(
+		service.NewInvestmentService(
+			repository.NewInMemoryInvestmentRepository(),
+			repository.NewInMemoryInvestmentEventRepository(),
+			repository.NewInMemoryCustomerRepository(),
+			repository.NewInMemoryFundRepository(),
+			newLedgerService(),
+			domain.DefaultAllowanceRules,
+		),
+		auditService,
+	)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+	require.NoError(t, err)
+	_, err = investmentService.CancelInvestment(ctx, investment.ID, investment.Version)
+	require.NoError(t, err)
+
+	entries, err := auditService.GetEntries(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.ErrorContains(t, auditService.Queued(), "2 audit entries waiting to be written")
+	assert.Error(t, auditService.Flush(ctx))
+
+	// Reporting the queue does not write it, even once the log can be written
+	auditRepo.failing = false
+	assert.Error(t, auditService.Queued())
+	entries, _ = auditService.GetEntries(ctx)
+	assert.Empty(t, entries)
+
+	// Once flushed the queued entries are written in order
+	assert.NoError(t, auditService.Flush(ctx))
+	assert.NoError(t, auditService.Queued())
+
+	entries, err = auditService.GetEntries(ctx)
+	assert.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "investment.created", entries[0].Action)
+	assert.Equal(t, "investment.cancelled", entries[1].Action)
+	assert.NoError(t, auditService.Verify(ctx))
+}
+
+// repricingInvestmentService prices an investment behind the audited service's
+// back before cancelling it, as a concurrent change would
+type repricingInvestmentService struct {
+	domain.InvestmentService
+}
+
+func (s *repricingInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	if _, err := s.InvestmentService.PriceInvestment(ctx, id, 125, 0); err != nil {
+		return nil, err
+	}
+	return s.InvestmentService.CancelInvestment(ctx, id, 0)
+}
+
+func TestAuditedChangesLeaveOutAStaleBefore(t *testing.T) {
+	ctx := context.Background()
+	auditService := service.NewAuditService(repository.NewInMemoryAuditRepository())
+	investmentService := service.NewAuditedInvestmentService(
+		&repricingInvestmentService{InvestmentService: service.NewInvestmentService(
+			repository.NewInMemoryInvestmentRepository(),
+			repository.NewInMemoryInvestmentEventRepository(),
+			repository.NewInMemoryCustomerRepository(),
+			repository.NewInMemoryFundRepository(),
+			newLedgerService(),
+			domain.DefaultAllowanceRules,
+		)},
+		auditService,
+	)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+	require.NoError(t, err)
+	cancelled, err := investmentService.CancelInvestment(ctx, investment.ID, investment.Version)
+	require.NoError(t, err)
+	assert.Equal(t, investment.Version+2, cancelled.Version)
+
+	entries, err := auditService.GetEntries(ctx)
+	assert.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "investment.cancelled", entries[1].Action)
+	assert.Nil(t, entries[1].Before)
+	assert.NotNil(t, entries[1].After)
+}
+
+func TestFileAuditLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	record := func(entityID string) {
+		repo, err := repository.NewFileAuditRepository(path)
+		assert.NoError(t, err)
+		_, err = service.NewAuditService(repo).Record(ctx, domain.AnonymousActor, "fund.updated", "fund", entityID, nil, map[string]string{"name": "Fund " + entityID})
+		assert.NoError(t, err)
+	}
+
+	// Each reopen carries on the chain from the entries already in the file
+	record("fund-1")
+	record("fund-2")
+
+	entries, err := repository.ReadAuditLog(path)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.NoError(t, domain.VerifyAuditChain(entries))
+
+	t.Run("Editing the file is detected", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte("Fund fund-1"), []byte("Fund fund-9"), 1), 0o600))
+
+		entries, err := repository.ReadAuditLog(path)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, domain.VerifyAuditChain(entries), domain.ErrAuditChainBroken)
+
+		// and the log is not opened to add more entries to
+		_, err = repository.NewFileAuditRepository(path)
+		assert.ErrorIs(t, err, domain.ErrAuditChainBroken)
+	})
+}
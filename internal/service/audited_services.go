@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+)
+
+// The audited services wrap the business services and record every successful
+// change in the audit log, with the entity as it was before and after. Reads
+// pass straight through to the wrapped service. Changes are attributed to the
+// caller in the context, or to domain.AnonymousActor when there is none. A
+// change that has been made is never reported as failing because its audit
+// entry could not be written; the entry is queued until it can be.
+
+// auditor records changes for the audited services
+type auditor struct {
+	audit domain.AuditService
+	// locks make audited changes to the same entity one at a time, so the
+	// before snapshot taken for a change is of the entity it was made to
+	locks stripedMutex
+}
+
+// record adds an audit entry for a change that has already been made. It
+// cannot fail, as the change is committed: an entry that cannot be written yet
+// is queued by the audit service and written later, even if the caller has
+// since gone away.
+func (a *auditor) record(ctx context.Context, action, entityType, entityID string, before, after interface{}) {
+	a.audit.Enqueue(context.WithoutCancel(ctx), actor(ctx), action, entityType, entityID, before, after)
+}
+
+// lock stops other audited changes to an entity until the returned function is called
+func (a *auditor) lock(entityID string) (unlock func()) {
+	return a.locks.lock(entityID)
+}
+
+// follows reports whether a change took an entity from beforeVersion straight to
+// afterVersion. Plan runs and switches change entities outside the audited
+// services, so one can slip in between the before snapshot and the change; the
+// before snapshot is then left out rather than recorded wrongly.
+func follows(beforeVersion, afterVersion int64) bool {
+	return afterVersion == beforeVersion+1
+}
+
+// actor is who a change made with ctx is attributed to
+func actor(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.Subject
+	}
+	return domain.AnonymousActor
+}
+
+type auditedInvestmentService struct {
+	domain.InvestmentService
+	auditor
+}
+
+// NewAuditedInvestmentService wraps an investment service so its changes are audited
+func NewAuditedInvestmentService(is domain.InvestmentService, as domain.AuditService) domain.InvestmentService {
+	return &auditedInvestmentService{InvestmentService: is, auditor: auditor{audit: as}}
+}
+
+// CreateInvestment creates an investment and audits it
+func (s *auditedInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	investment, err := s.InvestmentService.CreateInvestment(ctx, customerID, fundID, amount, riskAcknowledged)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "investment.created", "investment", investment.ID, nil, investment)
+	return investment, nil
+}
+
+// CancelInvestment cancels an investment and audits it
+func (s *auditedInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.cancelled", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.CancelInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// PriceInvestment prices an investment and audits it
+func (s *auditedInvestmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.priced", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.PriceInvestment(ctx, id, unitPrice, expectedVersion)
+	})
+}
+
+// ProcessInvestment processes an investment and audits it
+func (s *auditedInvestmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.processed", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.ProcessInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// WithdrawInvestment withdraws an investment and audits it
+func (s *auditedInvestmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.withdrawn", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.WithdrawInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing investment and audits it
+func (s *auditedInvestmentService) change(ctx context.Context, action, id string, change func() (*domain.Investment, error)) (*domain.Investment, error) {
+	defer s.lock(id)()
+	before, _ := s.InvestmentService.GetInvestment(ctx, id)
+	investment, err := change()
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, investment.Version) {
+		before = nil
+	}
+	s.record(ctx, action, "investment", id, before, investment)
+	return investment, nil
+}
+
+type auditedSwitchService struct {
+	domain.SwitchService
+	auditor
+}
+
+// NewAuditedSwitchService wraps a switch service so its changes are audited
+func NewAuditedSwitchService(ss domain.SwitchService, as domain.AuditService) domain.SwitchService {
+	return &auditedSwitchService{SwitchService: ss, auditor: auditor{audit: as}}
+}
+
+// SwitchFunds switches between funds and audits the switch
+func (s *auditedSwitchService) SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*domain.Switch, error) {
+	sw, err := s.SwitchService.SwitchFunds(ctx, customerID, fromFundID, toFundID, amount, riskAcknowledged)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "switch.created", "switch", sw.ID, nil, sw)
+	return sw, nil
+}
+
+type auditedPlanService struct {
+	domain.PlanService
+	auditor
+}
+
+// NewAuditedPlanService wraps a plan service so its changes are audited. The
+// investments collected by RunDuePlans are audited by the investment service
+// the plan service uses.
+func NewAuditedPlanService(ps domain.PlanService, as domain.AuditService) domain.PlanService {
+	return &auditedPlanService{PlanService: ps, auditor: auditor{audit: as}}
+}
+
+// CreatePlan creates a plan and audits it
+func (s *auditedPlanService) CreatePlan(ctx context.Context, customerID string, allocations []domain.PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*domain.Plan, error) {
+	plan, err := s.PlanService.CreatePlan(ctx, customerID, allocations, dayOfMonth, riskAcknowledged)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "plan.created", "plan", plan.ID, nil, plan)
+	return plan, nil
+}
+
+// UpdatePlan updates a plan and audits it
+func (s *auditedPlanService) UpdatePlan(ctx context.Context, id string, allocations []domain.PlanAllocation, dayOfMonth int, status domain.PlanStatus, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "plan.updated", id, func() (*domain.Plan, error) {
+		return s.PlanService.UpdatePlan(ctx, id, allocations, dayOfMonth, status, expectedVersion)
+	})
+}
+
+// CancelPlan cancels a plan and audits it
+func (s *auditedPlanService) CancelPlan(ctx context.Context, id string, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "plan.cancelled", id, func() (*domain.Plan, error) {
+		return s.PlanService.CancelPlan(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing plan and audits it
+func (s *auditedPlanService) change(ctx context.Context, action, id string, change func() (*domain.Plan, error)) (*domain.Plan, error) {
+	defer s.lock(id)()
+	before, _ := s.PlanService.GetPlan(ctx, id)
+	plan, err := change()
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, plan.Version) {
+		before = nil
+	}
+	s.record(ctx, action, "plan", id, before, plan)
+	return plan, nil
+}
+
+type auditedFundService struct {
+	domain.FundService
+	auditor
+}
+
+// NewAuditedFundService wraps a fund service so its changes are audited
+func NewAuditedFundService(fs domain.FundService, as domain.AuditService) domain.FundService {
+	return &auditedFundService{FundService: fs, auditor: auditor{audit: as}}
+}
+
+// CreateFund creates a fund and audits it
+func (s *auditedFundService) CreateFund(ctx context.Context, details domain.FundDetails) (*domain.Fund, error) {
+	fund, err := s.FundService.CreateFund(ctx, details)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "fund.created", "fund", fund.ID, nil, fund)
+	return fund, nil
+}
+
+// UpdateFund updates a fund and audits it
+func (s *auditedFundService) UpdateFund(ctx context.Context, id string, details domain.FundDetails, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.updated", id, func() (*domain.Fund, error) {
+		return s.FundService.UpdateFund(ctx, id, details, expectedVersion)
+	})
+}
+
+// SoftCloseFund soft-closes a fund and audits it
+func (s *auditedFundService) SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.soft_closed", id, func() (*domain.Fund, error) {
+		return s.FundService.SoftCloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// SuspendFund suspends a fund and audits it
+func (s *auditedFundService) SuspendFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.suspended", id, func() (*domain.Fund, error) {
+		return s.FundService.SuspendFund(ctx, id, expectedVersion)
+	})
+}
+
+// CloseFund closes a fund and audits it
+func (s *auditedFundService) CloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.closed", id, func() (*domain.Fund, error) {
+		return s.FundService.CloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// ReopenFund reopens a fund and audits it
+func (s *auditedFundService) ReopenFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.reopened", id, func() (*domain.Fund, error) {
+		return s.FundService.ReopenFund(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing fund and audits it
+func (s *auditedFundService) change(ctx context.Context, action, id string, change func() (*domain.Fund, error)) (*domain.Fund, error) {
+	defer s.lock(id)()
+	before, _ := s.FundService.GetFund(ctx, id)
+	fund, err := change()
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, fund.Version) {
+		before = nil
+	}
+	s.record(ctx, action, "fund", id, before, fund)
+	return fund, nil
+}
+
+type auditedCustomerService struct {
+	domain.CustomerService
+	auditor
+}
+
+// NewAuditedCustomerService wraps a customer service so its changes are audited
+func NewAuditedCustomerService(cs domain.CustomerService, as domain.AuditService) domain.CustomerService {
+	return &auditedCustomerService{CustomerService: cs, auditor: auditor{audit: as}}
+}
+
+// SubmitRiskQuestionnaire updates the customer's risk profile and audits it
+func (s *auditedCustomerService) SubmitRiskQuestionnaire(ctx context.Context, customerID string, answers map[string]int) (*domain.Customer, error) {
+	defer s.lock(customerID)()
+	before, _ := s.CustomerService.GetCustomer(ctx, customerID)
+	customer, err := s.CustomerService.SubmitRiskQuestionnaire(ctx, customerID, answers)
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, customer.Version) {
+		before = nil
+	}
+	s.record(ctx, "customer.risk_profiled", "customer", customerID, before, customer)
+	return customer, nil
+}
+
+// UpdateCustomerDetails changes the customer's details and audits it
+func (s *auditedCustomerService) UpdateCustomerDetails(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64) (*domain.Customer, error) {
+	defer s.lock(customerID)()
+	before, _ := s.CustomerService.GetCustomer(ctx, customerID)
+	customer, err := s.CustomerService.UpdateCustomerDetails(ctx, customerID, details, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, customer.Version) {
+		before = nil
+	}
+	s.record(ctx, "customer.updated", "customer", customerID, before, customer)
+	return customer, nil
+}
+
+type auditedLedgerService struct {
+	domain.LedgerService
+	auditor
+}
+
+// NewAuditedLedgerService wraps a ledger service so fees and dividends are audited.
+// Journals posted for investments are covered by the investment's own audit entries.
+func NewAuditedLedgerService(ls domain.LedgerService, as domain.AuditService) domain.LedgerService {
+	return &auditedLedgerService{LedgerService: ls, auditor: auditor{audit: as}}
+}
+
+// RecordFee takes a fee and audits it
+func (s *auditedLedgerService) RecordFee(ctx context.Context, customerID string, amount int64) (*domain.Journal, error) {
+	journal, err := s.LedgerService.RecordFee(ctx, customerID, amount)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "ledger.fee_recorded", "journal", journal.ID, nil, journal)
+	return journal, nil
+}
+
+// RecordDividend pays a dividend and audits it
+func (s *auditedLedgerService) RecordDividend(ctx context.Context, customerID, fundID string, amount int64) (*domain.Journal, error) {
+	journal, err := s.LedgerService.RecordDividend(ctx, customerID, fundID, amount)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "ledger.dividend_recorded", "journal", journal.ID, nil, journal)
+	return journal, nil
+}
+
+type auditedApprovalService struct {
+	domain.ApprovalService
+	auditor
+}
+
+// NewAuditedApprovalService wraps an approval service so requests and decisions
+// are audited. The approved changes themselves are audited by the services that make them.
+func NewAuditedApprovalService(as domain.ApprovalService, audit domain.AuditService) domain.ApprovalService {
+	return &auditedApprovalService{ApprovalService: as, auditor: auditor{audit: audit}}
+}
+
+// RequestInvestmentCancellation requests a cancellation and audits it
+func (s *auditedApprovalService) RequestInvestmentCancellation(ctx context.Context, investmentID string, expectedVersion int64, reason string) (*domain.Approval, error) {
+	return s.request(ctx, func() (*domain.Approval, error) {
+		return s.ApprovalService.RequestInvestmentCancellation(ctx, investmentID, expectedVersion, reason)
+	})
+}
+
+// RequestCustomerUpdate requests a change of customer details and audits it
+func (s *auditedApprovalService) RequestCustomerUpdate(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64, reason string) (*domain.Approval, error) {
+	return s.request(ctx, func() (*domain.Approval, error) {
+		return s.ApprovalService.RequestCustomerUpdate(ctx, customerID, details, expectedVersion, reason)
+	})
+}
+
+// Approve approves a request and audits the decision
+func (s *auditedApprovalService) Approve(ctx context.Context, id string) (*domain.Approval, error) {
+	return s.decide(ctx, id, func() (*domain.Approval, error) {
+		return s.ApprovalService.Approve(ctx, id)
+	})
+}
+
+// Reject rejects a request and audits the decision
+func (s *auditedApprovalService) Reject(ctx context.Context, id, note string) (*domain.Approval, error) {
+	return s.decide(ctx, id, func() (*domain.Approval, error) {
+		return s.ApprovalService.Reject(ctx, id, note)
+	})
+}
+
+// request makes a new approval request and audits it
+func (s *auditedApprovalService) request(ctx context.Context, request func() (*domain.Approval, error)) (*domain.Approval, error) {
+	approval, err := request()
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "approval.requested", "approval", approval.ID, nil, approval)
+	return approval, nil
+}
+
+// decide makes a decision on an approval and audits it as approval.<resulting status>
+func (s *auditedApprovalService) decide(ctx context.Context, id string, decide func() (*domain.Approval, error)) (*domain.Approval, error) {
+	// Approvals are only changed here, and a failed approval is saved twice,
+	// so the lock alone keeps the before snapshot right
+	defer s.lock(id)()
+	before, _ := s.ApprovalService.GetApproval(ctx, id)
+	approval, err := decide()
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "approval."+string(approval.Status), "approval", id, before, approval)
+	return approval, nil
+}
+
+type auditedAPIKeyService struct {
+	domain.APIKeyService
+	auditor
+}
+
+// NewAuditedAPIKeyService wraps an API key service so keys being issued, rotated
+// and revoked are audited. The secrets are never recorded.
+func NewAuditedAPIKeyService(ks domain.APIKeyService, as domain.AuditService) domain.APIKeyService {
+	return &auditedAPIKeyService{APIKeyService: ks, auditor: auditor{audit: as}}
+}
+
+// IssueAPIKey issues a key and audits it
+func (s *auditedAPIKeyService) IssueAPIKey(ctx context.Context, partnerID string, settings domain.APIKeySettings) (*domain.IssuedAPIKey, error) {
+	issued, err := s.APIKeyService.IssueAPIKey(ctx, partnerID, settings)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, "api_key.issued", "api_key", issued.ID, nil, issued.APIKey)
+	return issued, nil
+}
+
+// RotateAPIKey rotates a key and audits both the old key and its replacement
+func (s *auditedAPIKeyService) RotateAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.IssuedAPIKey, error) {
+	defer s.lock(id)()
+	before, _ := s.APIKeyService.GetAPIKey(ctx, id)
+	issued, err := s.APIKeyService.RotateAPIKey(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	after, _ := s.APIKeyService.GetAPIKey(ctx, id)
+	if before != nil && after != nil && !follows(before.Version, after.Version) {
+		before = nil
+	}
+	s.record(ctx, "api_key.rotated", "api_key", id, before, after)
+	s.record(ctx, "api_key.issued", "api_key", issued.ID, nil, issued.APIKey)
+	return issued, nil
+}
+
+// RevokeAPIKey revokes a key and audits it
+func (s *auditedAPIKeyService) RevokeAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.APIKey, error) {
+	defer s.lock(id)()
+	before, _ := s.APIKeyService.GetAPIKey(ctx, id)
+	key, err := s.APIKeyService.RevokeAPIKey(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if before != nil && !follows(before.Version, key.Version) {
+		before = nil
+	}
+	s.record(ctx, "api_key.revoked", "api_key", id, before, key)
+	return key, nil
+}
@@ -0,0 +1,575 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"time"
+)
+
+// The authorized services wrap the business services and refuse, with
+// domain.ErrForbidden, any call the caller in the context is not permitted to
+// make, so the permission matrix in package auth holds however a service is
+// reached. Unlike the audited services they do not embed the wrapped service:
+// every method has to decide what permission it needs.
+
+// authorize returns ErrForbidden unless the caller has the permission
+func authorize(ctx context.Context, permission auth.Permission) error {
+	if !auth.Allowed(ctx, permission) {
+		return fmt.Errorf("%w: permission %s is required", domain.ErrForbidden, permission)
+	}
+	return nil
+}
+
+// authorizeCustomer returns ErrForbidden unless the caller has the permission
+// and may act for the customer
+func authorizeCustomer(ctx context.Context, permission auth.Permission, customerID string) error {
+	if err := authorize(ctx, permission); err != nil {
+		return err
+	}
+	if !auth.CanAccessCustomer(ctx, customerID) {
+		return fmt.Errorf("%w: access to customer %s", domain.ErrForbidden, customerID)
+	}
+	return nil
+}
+
+type authorizedInvestmentService struct {
+	next domain.InvestmentService
+}
+
+// NewAuthorizedInvestmentService wraps an investment service so callers can only make the calls their roles permit
+func NewAuthorizedInvestmentService(is domain.InvestmentService) domain.InvestmentService {
+	return &authorizedInvestmentService{next: is}
+}
+
+// CreateInvestment requires investments:create, for the customer concerned
+func (s *authorizedInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	if err := authorizeCustomer(ctx, auth.PermInvestmentsCreate, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.CreateInvestment(ctx, customerID, fundID, amount, riskAcknowledged)
+}
+
+// GetInvestment requires investments:read, for the customer concerned
+func (s *authorizedInvestmentService) GetInvestment(ctx context.Context, id string) (*domain.Investment, error) {
+	return s.investment(ctx, auth.PermInvestmentsRead, id)
+}
+
+// GetCustomerInvestments requires investments:read, for the customer concerned
+func (s *authorizedInvestmentService) GetCustomerInvestments(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	if err := authorizeCustomer(ctx, auth.PermInvestmentsRead, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.GetCustomerInvestments(ctx, customerID)
+}
+
+// ListCustomerInvestments requires investments:read, for the customer concerned
+func (s *authorizedInvestmentService) ListCustomerInvestments(ctx context.Context, query domain.InvestmentQuery) (*domain.InvestmentPage, error) {
+	if err := authorizeCustomer(ctx, auth.PermInvestmentsRead, query.CustomerID); err != nil {
+		return nil, err
+	}
+	return s.next.ListCustomerInvestments(ctx, query)
+}
+
+// CancelInvestment requires investments:cancel, for the customer concerned.
+// Staff can only cancel processed investments through an approval. Without an
+// expected version, the version checked is the one cancelled, so an investment
+// processed meanwhile is a conflict rather than cancelled without approval.
+func (s *authorizedInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	investment, err := s.investment(ctx, auth.PermInvestmentsCancel, id)
+	if err != nil {
+		return nil, err
+	}
+	if investment.Status == domain.InvestmentStatusProcessed && !auth.IsCustomer(ctx, investment.CustomerID) {
+		return nil, fmt.Errorf("%w: request the cancellation of processed investments", domain.ErrApprovalRequired)
+	}
+	if expectedVersion == 0 {
+		expectedVersion = investment.Version
+	}
+	return s.next.CancelInvestment(ctx, id, expectedVersion)
+}
+
+// PriceInvestment requires investments:deal, for the customer concerned
+func (s *authorizedInvestmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	if _, err := s.investment(ctx, auth.PermInvestmentsDeal, id); err != nil {
+		return nil, err
+	}
+	return s.next.PriceInvestment(ctx, id, unitPrice, expectedVersion)
+}
+
+// ProcessInvestment requires investments:deal, for the customer concerned
+func (s *authorizedInvestmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	if _, err := s.investment(ctx, auth.PermInvestmentsDeal, id); err != nil {
+		return nil, err
+	}
+	return s.next.ProcessInvestment(ctx, id, expectedVersion)
+}
+
+// WithdrawInvestment requires investments:withdraw, for the customer concerned
+func (s *authorizedInvestmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	if _, err := s.investment(ctx, auth.PermInvestmentsWithdraw, id); err != nil {
+		return nil, err
+	}
+	return s.next.WithdrawInvestment(ctx, id, expectedVersion)
+}
+
+// GetInvestmentEvents requires investments:read, for the customer concerned
+func (s *authorizedInvestmentService) GetInvestmentEvents(ctx context.Context, id string) ([]*domain.InvestmentEvent, error) {
+	if _, err := s.investment(ctx, auth.PermInvestmentsRead, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetInvestmentEvents(ctx, id)
+}
+
+// investment loads an investment once the caller is known to have the
+// permission, and checks they may act for its customer
+func (s *authorizedInvestmentService) investment(ctx context.Context, permission auth.Permission, id string) (*domain.Investment, error) {
+	if err := authorize(ctx, permission); err != nil {
+		return nil, err
+	}
+	investment, err := s.next.GetInvestment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, permission, investment.CustomerID); err != nil {
+		return nil, err
+	}
+	return investment, nil
+}
+
+type authorizedSwitchService struct {
+	next domain.SwitchService
+}
+
+// NewAuthorizedSwitchService wraps a switch service so callers can only make the calls their roles permit
+func NewAuthorizedSwitchService(ss domain.SwitchService) domain.SwitchService {
+	return &authorizedSwitchService{next: ss}
+}
+
+// SwitchFunds requires switches:create, for the customer concerned
+func (s *authorizedSwitchService) SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*domain.Switch, error) {
+	if err := authorizeCustomer(ctx, auth.PermSwitchesCreate, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.SwitchFunds(ctx, customerID, fromFundID, toFundID, amount, riskAcknowledged)
+}
+
+// GetSwitch requires switches:read, for the customer concerned
+func (s *authorizedSwitchService) GetSwitch(ctx context.Context, id string) (*domain.Switch, error) {
+	if err := authorize(ctx, auth.PermSwitchesRead); err != nil {
+		return nil, err
+	}
+	sw, err := s.next.GetSwitch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, auth.PermSwitchesRead, sw.CustomerID); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+type authorizedPlanService struct {
+	next domain.PlanService
+}
+
+// NewAuthorizedPlanService wraps a plan service so callers can only make the calls their roles permit
+func NewAuthorizedPlanService(ps domain.PlanService) domain.PlanService {
+	return &authorizedPlanService{next: ps}
+}
+
+// CreatePlan requires plans:manage, for the customer concerned
+func (s *authorizedPlanService) CreatePlan(ctx context.Context, customerID string, allocations []domain.PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*domain.Plan, error) {
+	if err := authorizeCustomer(ctx, auth.PermPlansManage, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.CreatePlan(ctx, customerID, allocations, dayOfMonth, riskAcknowledged)
+}
+
+// GetPlan requires plans:read, for the customer concerned
+func (s *authorizedPlanService) GetPlan(ctx context.Context, id string) (*domain.Plan, error) {
+	return s.plan(ctx, auth.PermPlansRead, id)
+}
+
+// GetCustomerPlans requires plans:read, for the customer concerned
+func (s *authorizedPlanService) GetCustomerPlans(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	if err := authorizeCustomer(ctx, auth.PermPlansRead, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.GetCustomerPlans(ctx, customerID)
+}
+
+// UpdatePlan requires plans:manage, for the customer concerned
+func (s *authorizedPlanService) UpdatePlan(ctx context.Context, id string, allocations []domain.PlanAllocation, dayOfMonth int, status domain.PlanStatus, expectedVersion int64) (*domain.Plan, error) {
+	if _, err := s.plan(ctx, auth.PermPlansManage, id); err != nil {
+		return nil, err
+	}
+	return s.next.UpdatePlan(ctx, id, allocations, dayOfMonth, status, expectedVersion)
+}
+
+// CancelPlan requires plans:manage, for the customer concerned
+func (s *authorizedPlanService) CancelPlan(ctx context.Context, id string, expectedVersion int64) (*domain.Plan, error) {
+	if _, err := s.plan(ctx, auth.PermPlansManage, id); err != nil {
+		return nil, err
+	}
+	return s.next.CancelPlan(ctx, id, expectedVersion)
+}
+
+// RunDuePlans requires plans:run
+func (s *authorizedPlanService) RunDuePlans(ctx context.Context, at time.Time) error {
+	if err := authorize(ctx, auth.PermPlansRun); err != nil {
+		return err
+	}
+	return s.next.RunDuePlans(ctx, at)
+}
+
+// plan loads a plan once the caller is known to have the permission, and
+// checks they may act for its customer
+func (s *authorizedPlanService) plan(ctx context.Context, permission auth.Permission, id string) (*domain.Plan, error) {
+	if err := authorize(ctx, permission); err != nil {
+		return nil, err
+	}
+	plan, err := s.next.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, permission, plan.CustomerID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+type authorizedFundService struct {
+	next domain.FundService
+}
+
+// NewAuthorizedFundService wraps a fund service so callers can only make the calls their roles permit
+func NewAuthorizedFundService(fs domain.FundService) domain.FundService {
+	return &authorizedFundService{next: fs}
+}
+
+// GetFund requires funds:read
+func (s *authorizedFundService) GetFund(ctx context.Context, id string) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsRead); err != nil {
+		return nil, err
+	}
+	return s.next.GetFund(ctx, id)
+}
+
+// ListFunds requires funds:read
+func (s *authorizedFundService) ListFunds(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	if err := authorize(ctx, auth.PermFundsRead); err != nil {
+		return nil, 0, err
+	}
+	return s.next.ListFunds(ctx, filter)
+}
+
+// CreateFund requires funds:manage
+func (s *authorizedFundService) CreateFund(ctx context.Context, details domain.FundDetails) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.CreateFund(ctx, details)
+}
+
+// UpdateFund requires funds:manage
+func (s *authorizedFundService) UpdateFund(ctx context.Context, id string, details domain.FundDetails, expectedVersion int64) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.UpdateFund(ctx, id, details, expectedVersion)
+}
+
+// SoftCloseFund requires funds:manage
+func (s *authorizedFundService) SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.SoftCloseFund(ctx, id, expectedVersion)
+}
+
+// SuspendFund requires funds:manage
+func (s *authorizedFundService) SuspendFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.SuspendFund(ctx, id, expectedVersion)
+}
+
+// CloseFund requires funds:manage
+func (s *authorizedFundService) CloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.CloseFund(ctx, id, expectedVersion)
+}
+
+// ReopenFund requires funds:manage
+func (s *authorizedFundService) ReopenFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	if err := authorize(ctx, auth.PermFundsManage); err != nil {
+		return nil, err
+	}
+	return s.next.ReopenFund(ctx, id, expectedVersion)
+}
+
+type authorizedCustomerService struct {
+	next domain.CustomerService
+}
+
+// NewAuthorizedCustomerService wraps a customer service so callers can only make the calls their roles permit
+func NewAuthorizedCustomerService(cs domain.CustomerService) domain.CustomerService {
+	return &authorizedCustomerService{next: cs}
+}
+
+// GetCustomer requires customers:read, for the customer concerned
+func (s *authorizedCustomerService) GetCustomer(ctx context.Context, id string) (*domain.Customer, error) {
+	if err := authorizeCustomer(ctx, auth.PermCustomersRead, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetCustomer(ctx, id)
+}
+
+// GetRiskQuestionnaire is the same fixed list of questions for everyone, so needs no permission
+func (s *authorizedCustomerService) GetRiskQuestionnaire(ctx context.Context) []domain.RiskQuestion {
+	return s.next.GetRiskQuestionnaire(ctx)
+}
+
+// SubmitRiskQuestionnaire requires risk_profile:submit, for the customer concerned
+func (s *authorizedCustomerService) SubmitRiskQuestionnaire(ctx context.Context, customerID string, answers map[string]int) (*domain.Customer, error) {
+	if err := authorizeCustomer(ctx, auth.PermRiskProfileSubmit, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.SubmitRiskQuestionnaire(ctx, customerID, answers)
+}
+
+// UpdateCustomerDetails is only made through an approval, so is always refused
+func (s *authorizedCustomerService) UpdateCustomerDetails(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64) (*domain.Customer, error) {
+	if err := authorizeCustomer(ctx, auth.PermCustomersUpdate, customerID); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: request the change of customer details", domain.ErrApprovalRequired)
+}
+
+type authorizedLedgerService struct {
+	next domain.LedgerService
+}
+
+// NewAuthorizedLedgerService wraps a ledger service so callers can only make the calls their roles permit
+func NewAuthorizedLedgerService(ls domain.LedgerService) domain.LedgerService {
+	return &authorizedLedgerService{next: ls}
+}
+
+// Post and Balance work on any account rather than one customer's, so are
+// limited to those who can post to the ledger
+func (s *authorizedLedgerService) Post(ctx context.Context, journal *domain.Journal) error {
+	if err := authorize(ctx, auth.PermLedgerPost); err != nil {
+		return err
+	}
+	return s.next.Post(ctx, journal)
+}
+
+// PostWith requires ledger:post
+func (s *authorizedLedgerService) PostWith(ctx context.Context, journals []*domain.Journal, apply func(ctx context.Context) error) error {
+	if err := authorize(ctx, auth.PermLedgerPost); err != nil {
+		return err
+	}
+	return s.next.PostWith(ctx, journals, apply)
+}
+
+// Balance requires ledger:post
+func (s *authorizedLedgerService) Balance(ctx context.Context, account domain.LedgerAccount, asset domain.LedgerAsset, at time.Time) (int64, error) {
+	if err := authorize(ctx, auth.PermLedgerPost); err != nil {
+		return 0, err
+	}
+	return s.next.Balance(ctx, account, asset, at)
+}
+
+// GetCustomerBalances requires ledger:read, for the customer concerned
+func (s *authorizedLedgerService) GetCustomerBalances(ctx context.Context, customerID string, at time.Time) (*domain.CustomerBalances, error) {
+	if err := authorizeCustomer(ctx, auth.PermLedgerRead, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.GetCustomerBalances(ctx, customerID, at)
+}
+
+// GetCustomerJournals requires ledger:read, for the customer concerned
+func (s *authorizedLedgerService) GetCustomerJournals(ctx context.Context, customerID string) ([]*domain.Journal, error) {
+	if err := authorizeCustomer(ctx, auth.PermLedgerRead, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.GetCustomerJournals(ctx, customerID)
+}
+
+// RecordFee requires ledger:post, for the customer concerned
+func (s *authorizedLedgerService) RecordFee(ctx context.Context, customerID string, amount int64) (*domain.Journal, error) {
+	if err := authorizeCustomer(ctx, auth.PermLedgerPost, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.RecordFee(ctx, customerID, amount)
+}
+
+// RecordDividend requires ledger:post, for the customer concerned
+func (s *authorizedLedgerService) RecordDividend(ctx context.Context, customerID, fundID string, amount int64) (*domain.Journal, error) {
+	if err := authorizeCustomer(ctx, auth.PermLedgerPost, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.RecordDividend(ctx, customerID, fundID, amount)
+}
+
+type authorizedRecommendationService struct {
+	next domain.RecommendationService
+}
+
+// NewAuthorizedRecommendationService wraps a recommendation service so callers can only make the calls their roles permit
+func NewAuthorizedRecommendationService(rs domain.RecommendationService) domain.RecommendationService {
+	return &authorizedRecommendationService{next: rs}
+}
+
+// GetRecommendations requires recommendations:read, for the customer concerned
+func (s *authorizedRecommendationService) GetRecommendations(ctx context.Context, customerID string, horizonYears int) (*domain.Recommendations, error) {
+	if err := authorizeCustomer(ctx, auth.PermRecommendationsRead, customerID); err != nil {
+		return nil, err
+	}
+	return s.next.GetRecommendations(ctx, customerID, horizonYears)
+}
+
+type authorizedApprovalService struct {
+	next domain.ApprovalService
+}
+
+// NewAuthorizedApprovalService wraps an approval service so callers can only make the calls their roles permit
+func NewAuthorizedApprovalService(as domain.ApprovalService) domain.ApprovalService {
+	return &authorizedApprovalService{next: as}
+}
+
+// actionPermission is the permission needed to make the change an approval is for
+var actionPermission = map[domain.ApprovalAction]auth.Permission{
+	domain.ApprovalActionCancelInvestment: auth.PermInvestmentsCancel,
+	domain.ApprovalActionUpdateCustomer:   auth.PermCustomersUpdate,
+}
+
+// RequestInvestmentCancellation requires approvals:request and investments:cancel
+func (s *authorizedApprovalService) RequestInvestmentCancellation(ctx context.Context, investmentID string, expectedVersion int64, reason string) (*domain.Approval, error) {
+	if err := authorize(ctx, auth.PermApprovalsRequest); err != nil {
+		return nil, err
+	}
+	if err := authorize(ctx, actionPermission[domain.ApprovalActionCancelInvestment]); err != nil {
+		return nil, err
+	}
+	return s.next.RequestInvestmentCancellation(ctx, investmentID, expectedVersion, reason)
+}
+
+// RequestCustomerUpdate requires approvals:request and customers:update, for the customer concerned
+func (s *authorizedApprovalService) RequestCustomerUpdate(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64, reason string) (*domain.Approval, error) {
+	if err := authorize(ctx, auth.PermApprovalsRequest); err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, actionPermission[domain.ApprovalActionUpdateCustomer], customerID); err != nil {
+		return nil, err
+	}
+	return s.next.RequestCustomerUpdate(ctx, customerID, details, expectedVersion, reason)
+}
+
+// Approve requires approvals:decide and the permission to make the change, for the customer concerned
+func (s *authorizedApprovalService) Approve(ctx context.Context, id string) (*domain.Approval, error) {
+	approval, err := s.approval(ctx, auth.PermApprovalsDecide, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, actionPermission[approval.Action], approval.CustomerID); err != nil {
+		return nil, err
+	}
+	return s.next.Approve(ctx, id)
+}
+
+// Reject requires approvals:decide, for the customer concerned
+func (s *authorizedApprovalService) Reject(ctx context.Context, id, note string) (*domain.Approval, error) {
+	if _, err := s.approval(ctx, auth.PermApprovalsDecide, id); err != nil {
+		return nil, err
+	}
+	return s.next.Reject(ctx, id, note)
+}
+
+// GetApproval requires approvals:read, for the customer concerned
+func (s *authorizedApprovalService) GetApproval(ctx context.Context, id string) (*domain.Approval, error) {
+	return s.approval(ctx, auth.PermApprovalsRead, id)
+}
+
+// ListApprovals requires approvals:read
+func (s *authorizedApprovalService) ListApprovals(ctx context.Context, status domain.ApprovalStatus) ([]*domain.Approval, error) {
+	if err := authorize(ctx, auth.PermApprovalsRead); err != nil {
+		return nil, err
+	}
+	return s.next.ListApprovals(ctx, status)
+}
+
+// approval loads an approval once the caller is known to have the permission,
+// and checks they may act for its customer
+func (s *authorizedApprovalService) approval(ctx context.Context, permission auth.Permission, id string) (*domain.Approval, error) {
+	if err := authorize(ctx, permission); err != nil {
+		return nil, err
+	}
+	approval, err := s.next.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeCustomer(ctx, permission, approval.CustomerID); err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+type authorizedAPIKeyService struct {
+	next domain.APIKeyService
+}
+
+// NewAuthorizedAPIKeyService wraps an API key service so callers can only make the calls their roles permit
+func NewAuthorizedAPIKeyService(ks domain.APIKeyService) domain.APIKeyService {
+	return &authorizedAPIKeyService{next: ks}
+}
+
+// IssueAPIKey requires api_keys:manage
+func (s *authorizedAPIKeyService) IssueAPIKey(ctx context.Context, partnerID string, settings domain.APIKeySettings) (*domain.IssuedAPIKey, error) {
+	if err := authorize(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+	return s.next.IssueAPIKey(ctx, partnerID, settings)
+}
+
+// RotateAPIKey requires api_keys:manage
+func (s *authorizedAPIKeyService) RotateAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.IssuedAPIKey, error) {
+	if err := authorize(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+	return s.next.RotateAPIKey(ctx, id, expectedVersion)
+}
+
+// RevokeAPIKey requires api_keys:manage
+func (s *authorizedAPIKeyService) RevokeAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.APIKey, error) {
+	if err := authorize(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+	return s.next.RevokeAPIKey(ctx, id, expectedVersion)
+}
+
+// GetAPIKey requires api_keys:manage
+func (s *authorizedAPIKeyService) GetAPIKey(ctx context.Context, id string) (*domain.APIKey, error) {
+	if err := authorize(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+	return s.next.GetAPIKey(ctx, id)
+}
+
+// GetPartnerAPIKeys requires api_keys:manage
+func (s *authorizedAPIKeyService) GetPartnerAPIKeys(ctx context.Context, partnerID string) ([]*domain.APIKey, error) {
+	if err := authorize(ctx, auth.PermAPIKeysManage); err != nil {
+		return nil, err
+	}
+	return s.next.GetPartnerAPIKeys(ctx, partnerID)
+}
+
+// AuthenticateAPIKey needs no permission, as it is how a partner becomes the caller
+func (s *authorizedAPIKeyService) AuthenticateAPIKey(ctx context.Context, presented string) (*domain.APIKey, error) {
+	return s.next.AuthenticateAPIKey(ctx, presented)
+}
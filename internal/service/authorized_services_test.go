@@ -0,0 +1,152 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/auth"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func as(subject string, roles ...auth.Role) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: subject, Roles: roles})
+}
+
+func TestAuthorizedInvestmentService(t *testing.T) {
+	investmentService := service.NewAuthorizedInvestmentService(service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	))
+	customer := as("customer-1", auth.RoleCustomer)
+	otherCustomer := as("customer-2", auth.RoleCustomer)
+	operations := as("ops-1", auth.RoleOperations)
+	support := as("support-1", auth.RoleSupport)
+
+	t.Run("Calls without a principal are refused", func(t *testing.T) {
+		_, err := investmentService.CreateInvestment(context.Background(), "customer-1", "fund-1", 10000, false)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("Customers only create their own investments", func(t *testing.T) {
+		_, err := investmentService.CreateInvestment(otherCustomer, "customer-1", "fund-1", 10000, false)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		_, err = investmentService.CreateInvestment(operations, "customer-1", "fund-1", 10000, false)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	investment, err := investmentService.CreateInvestment(customer, "customer-1", "fund-1", 10000, false)
+	assert.NoError(t, err)
+
+	t.Run("Customers only read their own investments", func(t *testing.T) {
+		_, err := investmentService.GetInvestment(otherCustomer, investment.ID)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		_, err = investmentService.GetInvestmentEvents(otherCustomer, investment.ID)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		_, err = investmentService.ListCustomerInvestments(otherCustomer, domain.InvestmentQuery{CustomerID: "customer-1"})
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		// Back-office staff can read any customer's investments
+		_, err = investmentService.GetInvestment(support, investment.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Only operations deal", func(t *testing.T) {
+		_, err := investmentService.PriceInvestment(customer, investment.ID, 125, investment.Version)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = investmentService.PriceInvestment(support, investment.ID, 125, investment.Version)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		priced, err := investmentService.PriceInvestment(operations, investment.ID, 125, investment.Version)
+		assert.NoError(t, err)
+		investment = priced
+	})
+
+	t.Run("Partners only act for their linked customers within their scopes", func(t *testing.T) {
+		partner := auth.WithPrincipal(context.Background(), &auth.Principal{
+			Subject:     "partner:acme:key-1",
+			Permissions: []auth.Permission{auth.PermInvestmentsCreate},
+			CustomerIDs: []string{"customer-1"},
+		})
+
+		_, err := investmentService.CreateInvestment(partner, "customer-2", "fund-1", 10000, false)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = investmentService.GetInvestment(partner, investment.ID)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		_, err = investmentService.CreateInvestment(partner, "customer-1", "fund-1", 10000, false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Support can cancel for a customer", func(t *testing.T) {
+		_, err := investmentService.CancelInvestment(otherCustomer, investment.ID, investment.Version)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		cancelled, err := investmentService.CancelInvestment(support, investment.ID, investment.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.InvestmentStatusCancelled, cancelled.Status)
+	})
+}
+
+// processingInvestmentService processes an investment behind the authorized
+// service's back before cancelling it, as a concurrent change would
+type processingInvestmentService struct {
+	domain.InvestmentService
+}
+
+func (s *processingInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	if _, err := s.InvestmentService.ProcessInvestment(ctx, id, 0); err != nil {
+		return nil, err
+	}
+	return s.InvestmentService.CancelInvestment(ctx, id, expectedVersion)
+}
+
+func TestStaffCannotCancelAnInvestmentProcessedMeanwhile(t *testing.T) {
+	core := service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	)
+	investmentService := service.NewAuthorizedInvestmentService(&processingInvestmentService{InvestmentService: core})
+	operations := as("ops-1", auth.RoleOperations)
+
+	investment, err := core.CreateInvestment(context.Background(), "customer-1", "fund-1", 10000, false)
+	require.NoError(t, err)
+	_, err = core.PriceInvestment(context.Background(), investment.ID, 125, 0)
+	require.NoError(t, err)
+
+	_, err = investmentService.CancelInvestment(operations, investment.ID, 0)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	stored, err := core.GetInvestment(context.Background(), investment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.InvestmentStatusProcessed, stored.Status)
+}
+
+func TestAuthorizedFundService(t *testing.T) {
+	fundService := service.NewAuthorizedFundService(service.NewFundService(repository.NewInMemoryFundRepository()))
+
+	for _, role := range []auth.Role{auth.RoleCustomer, auth.RoleSupport, auth.RoleCompliance} {
+		_, err := fundService.SuspendFund(as("user-1", role), "fund-1", 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden, role)
+
+		_, err = fundService.GetFund(as("user-1", role), "fund-1")
+		assert.NoError(t, err, role)
+	}
+
+	fund, err := fundService.SuspendFund(as("ops-1", auth.RoleOperations), "fund-1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.FundStatusSuspended, fund.Status)
+}
@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// cancellingInvestmentRepository cancels the request once an investment is
+// created, as if the caller went away just after it was stored
+type cancellingInvestmentRepository struct {
+	domain.InvestmentRepository
+	cancel context.CancelFunc
+}
+
+func (r *cancellingInvestmentRepository) Create(ctx context.Context, investment *domain.Investment) error {
+	err := r.InvestmentRepository.Create(ctx, investment)
+	r.cancel()
+	return err
+}
+
+func TestCancelledRequests(t *testing.T) {
+	t.Run("Nothing is stored for a request cancelled before it starts", func(t *testing.T) {
+		investmentRepo := repository.NewInMemoryInvestmentRepository()
+		investmentService := service.NewInvestmentService(
+			investmentRepo,
+			repository.NewInMemoryInvestmentEventRepository(),
+			repository.NewInMemoryCustomerRepository(),
+			repository.NewInMemoryFundRepository(),
+			newLedgerService(),
+			domain.DefaultAllowanceRules,
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		investments, err := investmentRepo.GetByCustomerID(context.Background(), "customer-1")
+		assert.NoError(t, err)
+		assert.Empty(t, investments)
+	})
+
+	t.Run("A stored change is seen through when the caller goes away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ledgerService := newLedgerService()
+		auditService := service.NewAuditService(repository.NewInMemoryAuditRepository())
+		investmentService := service.NewAuditedInvestmentService(
+			service.NewInvestmentService(
+				&cancellingInvestmentRepository{InvestmentRepository: repository.NewInMemoryInvestmentRepository(), cancel: cancel},
+				repository.NewInMemoryInvestmentEventRepository(),
+				repository.NewInMemoryCustomerRepository(),
+				repository.NewInMemoryFundRepository(),
+				ledgerService,
+				domain.DefaultAllowanceRules,
+			),
+			auditService,
+		)
+
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+		require.NoError(t, err)
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+		// Its event, ledger journal and audit entry are all recorded
+		background := context.Background()
+		events, err := investmentService.GetInvestmentEvents(background, investment.ID)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		balances, err := ledgerService.GetCustomerBalances(background, "customer-1", investment.CreatedAt)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10000), balances.Cash)
+		entries, err := auditService.GetEntries(background)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("A stopped plan run leaves the plans it did not collect due", func(t *testing.T) {
+		mockCustomerRepo := new(mockCustomerRepository)
+		mockFundRepo := new(mockFundRepository)
+		mockCustomerRepo.On("GetByID", mock.Anything).Return(&domain.Customer{ID: "customer-1"}, nil)
+		mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", Status: domain.FundStatusOpen}, nil)
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+
+		plan, err := planService.CreatePlan(context.Background(), "customer-1", []domain.PlanAllocation{{FundID: "fund-1", Amount: 25000}}, 1, false)
+		require.NoError(t, err)
+		dueAt := plan.NextRunAt
+
+		ctx, cancel := context.WithCancel(context.Background())
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Run(func(mock.Arguments) { cancel() }).
+			Return(nil, context.Canceled)
+
+		assert.ErrorIs(t, planService.RunDuePlans(ctx, dueAt), context.Canceled)
+
+		plan, _ = planService.GetPlan(context.Background(), plan.ID)
+		assert.Equal(t, dueAt, plan.NextRunAt)
+		assert.Empty(t, plan.LastError)
+	})
+}
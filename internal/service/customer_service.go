@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// riskQuestionnaire is the fixed set of risk profiling questions. Each option is
+// scored by its position, so later options indicate a higher tolerance for risk.
+var riskQuestionnaire = []domain.RiskQuestion{
+	{
+		ID:   "horizon",
+		Text: "How long do you plan to keep this money invested?",
+		Options: []string{
+			"Less than 3 years",
+			"3 to 5 years",
+			"5 to 10 years",
+			"More than 10 years",
+		},
+	},
+	{
+		ID:   "experience",
+		Text: "How much experience do you have of investing in funds or shares?",
+		Options: []string{
+			"None",
+			"A little",
+			"Some",
+			"A lot",
+		},
+	},
+	{
+		ID:   "reaction",
+		Text: "If your investment fell by 20% in a year, what would you do?",
+		Options: []string{
+			"Sell everything",
+			"Sell some",
+			"Do nothing",
+			"Invest more",
+		},
+	},
+	{
+		ID:   "goal",
+		Text: "Which best describes your investment goal?",
+		Options: []string{
+			"Protect what I have",
+			"Steady income",
+			"Balance of income and growth",
+			"Maximum long-term growth",
+		},
+	},
+}
+
+// Total score thresholds for each risk tolerance (scores range from 0 to 12)
+const (
+	mediumRiskMinScore = 5
+	highRiskMinScore   = 9
+)
+
+type customerService struct {
+	customerRepo domain.CustomerRepository
+}
+
+// NewCustomerService creates a new instance of customer service
+func NewCustomerService(cr domain.CustomerRepository) domain.CustomerService {
+	return &customerService{
+		customerRepo: cr,
+	}
+}
+
+// GetCustomer gets a customer by ID
+func (cs *customerService) GetCustomer(ctx context.Context, id string) (*domain.Customer, error) {
+	return cs.customerRepo.GetByID(ctx, id)
+}
+
+// GetRiskQuestionnaire returns the risk profiling questions
+func (cs *customerService) GetRiskQuestionnaire(ctx context.Context) []domain.RiskQuestion {
+	return riskQuestionnaire
+}
+
+// SubmitRiskQuestionnaire scores the customer's answers and stores their risk tolerance
+func (cs *customerService) SubmitRiskQuestionnaire(ctx context.Context, customerID string, answers map[string]int) (*domain.Customer, error) {
+	customer, err := cs.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := scoreRiskQuestionnaire(answers)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	customer.RiskScore = score
+	customer.RiskTolerance = riskToleranceForScore(score)
+	customer.RiskProfiledAt = &now
+	customer.UpdatedAt = now
+
+	if err := cs.customerRepo.Update(ctx, customer); err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// UpdateCustomerDetails changes a customer's name and email address
+func (cs *customerService) UpdateCustomerDetails(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64) (*domain.Customer, error) {
+	if err := validateCustomerDetails(details); err != nil {
+		return nil, err
+	}
+
+	customer, err := cs.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(customer.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	customer.Name = details.Name
+	customer.Email = details.Email
+	customer.UpdatedAt = time.Now()
+
+	if err := cs.customerRepo.Update(ctx, customer); err != nil {
+		return nil, err
+	}
+
+	return customer, nil
+}
+
+// validateCustomerDetails checks a customer has a name and a plausible email address
+func validateCustomerDetails(details domain.CustomerDetails) error {
+	if strings.TrimSpace(details.Name) == "" {
+		return errors.New("customer name is required")
+	}
+	if address, err := mail.ParseAddress(details.Email); err != nil || address.Address != details.Email {
+		return errors.New("customer email address is invalid")
+	}
+	return nil
+}
+
+// scoreRiskQuestionnaire checks every question has a valid answer and totals the score
+func scoreRiskQuestionnaire(answers map[string]int) (int, error) {
+	if len(answers) != len(riskQuestionnaire) {
+		return 0, fmt.Errorf("all %d questions must be answered", len(riskQuestionnaire))
+	}
+
+	score := 0
+	for _, question := range riskQuestionnaire {
+		answer, ok := answers[question.ID]
+		if !ok {
+			return 0, fmt.Errorf("question %q has not been answered", question.ID)
+		}
+		if answer < 0 || answer >= len(question.Options) {
+			return 0, fmt.Errorf("invalid answer %d to question %q", answer, question.ID)
+		}
+		score += answer
+	}
+
+	return score, nil
+}
+
+// riskToleranceForScore maps a questionnaire score to a risk tolerance
+func riskToleranceForScore(score int) domain.RiskLevel {
+	switch {
+	case score >= highRiskMinScore:
+		return domain.RiskLevelHigh
+	case score >= mediumRiskMinScore:
+		return domain.RiskLevelMedium
+	default:
+		return domain.RiskLevelLow
+	}
+}
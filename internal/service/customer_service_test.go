@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestSubmitRiskQuestionnaire(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(mockCustomerRepository)
+	customerService := service.NewCustomerService(mockCustomerRepo)
+
+	mockCustomerRepo.On("GetByID", "customer-1").Return(&domain.Customer{ID: "customer-1"}, nil)
+	mockCustomerRepo.On("Update", mock.AnythingOfType("*domain.Customer")).Return(nil)
+
+	tests := []struct {
+		name      string
+		answers   map[string]int
+		tolerance domain.RiskLevel
+	}{
+		{"Cautious answers", map[string]int{"horizon": 0, "experience": 1, "reaction": 0, "goal": 1}, domain.RiskLevelLow},
+		{"Middling answers", map[string]int{"horizon": 2, "experience": 1, "reaction": 2, "goal": 2}, domain.RiskLevelMedium},
+		{"Adventurous answers", map[string]int{"horizon": 3, "experience": 2, "reaction": 3, "goal": 3}, domain.RiskLevelHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customer, err := customerService.SubmitRiskQuestionnaire(ctx, "customer-1", tt.answers)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.tolerance, customer.RiskTolerance)
+			assert.NotNil(t, customer.RiskProfiledAt)
+		})
+	}
+
+	t.Run("Incomplete answers are rejected", func(t *testing.T) {
+		customer, err := customerService.SubmitRiskQuestionnaire(ctx, "customer-1", map[string]int{"horizon": 3})
+		assert.Error(t, err)
+		assert.Nil(t, customer)
+	})
+
+	t.Run("Out of range answers are rejected", func(t *testing.T) {
+		customer, err := customerService.SubmitRiskQuestionnaire(ctx, "customer-1", map[string]int{"horizon": 4, "experience": 0, "reaction": 0, "goal": 0})
+		assert.Error(t, err)
+		assert.Nil(t, customer)
+	})
+}
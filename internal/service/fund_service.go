@@ -1,6 +1,30 @@
 package service
 
-import "github.com/grokkos/go-isa-retail-service/internal/domain"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"strings"
+	"time"
+)
+
+// Limits on fund catalogue fields and listing
+const (
+	maxFundNameLength        = 100
+	maxFundDescriptionLength = 1000
+	maxFundOngoingChargeBps  = 500
+	maxFundPageSize          = 100
+)
+
+// fundStatusTransitions lists the statuses each status may move to
+var fundStatusTransitions = map[domain.FundStatus][]domain.FundStatus{
+	domain.FundStatusOpen:       {domain.FundStatusSoftClosed, domain.FundStatusSuspended, domain.FundStatusClosed},
+	domain.FundStatusSoftClosed: {domain.FundStatusOpen, domain.FundStatusSuspended, domain.FundStatusClosed},
+	domain.FundStatusSuspended:  {domain.FundStatusOpen, domain.FundStatusSoftClosed, domain.FundStatusClosed},
+	domain.FundStatusClosed:     {domain.FundStatusOpen},
+}
 
 type fundService struct {
 	fundRepo domain.FundRepository
@@ -14,11 +38,185 @@ func NewFundService(fr domain.FundRepository) domain.FundService {
 }
 
 // GetFund gets a fund by ID
-func (fs *fundService) GetFund(id string) (*domain.Fund, error) {
-	return fs.fundRepo.GetByID(id)
+func (fs *fundService) GetFund(ctx context.Context, id string) (*domain.Fund, error) {
+	return fs.fundRepo.GetByID(ctx, id)
+}
+
+// ListFunds searches, filters, sorts and pages the catalogue.
+// Only funds open for investment are listed unless statuses are given.
+func (fs *fundService) ListFunds(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = []domain.FundStatus{domain.FundStatusOpen}
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = domain.FundSortByName
+	}
+
+	switch filter.SortBy {
+	case domain.FundSortByName, domain.FundSortByRiskLevel, domain.FundSortByOngoingCharge, domain.FundSortByCreatedAt:
+	default:
+		return nil, 0, fmt.Errorf("cannot sort funds by %q", filter.SortBy)
+	}
+	for _, riskLevel := range filter.RiskLevels {
+		if !riskLevel.Valid() {
+			return nil, 0, fmt.Errorf("invalid risk level %q", riskLevel)
+		}
+	}
+	for _, assetClass := range filter.AssetClasses {
+		if !assetClass.Valid() {
+			return nil, 0, fmt.Errorf("invalid asset class %q", assetClass)
+		}
+	}
+	if filter.MaxOngoingChargeBps < 0 {
+		return nil, 0, errors.New("maximum ongoing charge cannot be negative")
+	}
+	if filter.Limit < 0 || filter.Limit > maxFundPageSize {
+		return nil, 0, fmt.Errorf("limit must be between 1 and %d", maxFundPageSize)
+	}
+	if filter.Offset < 0 {
+		return nil, 0, errors.New("offset cannot be negative")
+	}
+
+	return fs.fundRepo.Find(ctx, filter)
+}
+
+// CreateFund adds a new open fund to the catalogue
+func (fs *fundService) CreateFund(ctx context.Context, details domain.FundDetails) (*domain.Fund, error) {
+	details.Name = strings.TrimSpace(details.Name)
+	if err := fs.validateFund(ctx, "", details); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	fund := &domain.Fund{
+		ID:               uuid.New().String(),
+		Name:             details.Name,
+		Description:      details.Description,
+		RiskLevel:        details.RiskLevel,
+		AssetClass:       details.AssetClass,
+		OngoingChargeBps: details.OngoingChargeBps,
+		Status:           domain.FundStatusOpen,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	if err := fs.fundRepo.Create(ctx, fund); err != nil {
+		return nil, err
+	}
+
+	return fund, nil
+}
+
+// UpdateFund changes the details of an existing fund
+func (fs *fundService) UpdateFund(ctx context.Context, id string, details domain.FundDetails, expectedVersion int64) (*domain.Fund, error) {
+	fund, err := fs.fundRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(fund.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	details.Name = strings.TrimSpace(details.Name)
+	if err := fs.validateFund(ctx, id, details); err != nil {
+		return nil, err
+	}
+
+	fund.Name = details.Name
+	fund.Description = details.Description
+	fund.RiskLevel = details.RiskLevel
+	fund.AssetClass = details.AssetClass
+	fund.OngoingChargeBps = details.OngoingChargeBps
+	fund.UpdatedAt = time.Now()
+
+	if err := fs.fundRepo.Update(ctx, fund); err != nil {
+		return nil, err
+	}
+
+	return fund, nil
+}
+
+// SoftCloseFund closes a fund to new money while letting existing holders deal
+func (fs *fundService) SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return fs.changeStatus(ctx, id, domain.FundStatusSoftClosed, expectedVersion)
+}
+
+// SuspendFund temporarily stops dealing in a fund
+func (fs *fundService) SuspendFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return fs.changeStatus(ctx, id, domain.FundStatusSuspended, expectedVersion)
+}
+
+// CloseFund permanently closes a fund
+func (fs *fundService) CloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return fs.changeStatus(ctx, id, domain.FundStatusClosed, expectedVersion)
 }
 
-// ListFunds lists all available funds
-func (fs *fundService) ListFunds() ([]*domain.Fund, error) {
-	return fs.fundRepo.GetAll()
+// ReopenFund reopens a suspended or closed fund
+func (fs *fundService) ReopenFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return fs.changeStatus(ctx, id, domain.FundStatusOpen, expectedVersion)
+}
+
+// changeStatus moves a fund to a new status if the transition is allowed
+func (fs *fundService) changeStatus(ctx context.Context, id string, status domain.FundStatus, expectedVersion int64) (*domain.Fund, error) {
+	fund, err := fs.fundRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(fund.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, next := range fundStatusTransitions[fund.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s to %s", domain.ErrInvalidFundStatusChange, fund.Status, status)
+	}
+
+	fund.Status = status
+	fund.UpdatedAt = time.Now()
+
+	if err := fs.fundRepo.Update(ctx, fund); err != nil {
+		return nil, err
+	}
+
+	return fund, nil
+}
+
+// validateFund checks the fund details and that no other fund has the same name
+func (fs *fundService) validateFund(ctx context.Context, id string, details domain.FundDetails) error {
+	if details.Name == "" {
+		return errors.New("fund name is required")
+	}
+	if len(details.Name) > maxFundNameLength {
+		return fmt.Errorf("fund name must be at most %d characters", maxFundNameLength)
+	}
+	if len(details.Description) > maxFundDescriptionLength {
+		return fmt.Errorf("fund description must be at most %d characters", maxFundDescriptionLength)
+	}
+	if !details.RiskLevel.Valid() {
+		return fmt.Errorf("invalid risk level %q", details.RiskLevel)
+	}
+	if !details.AssetClass.Valid() {
+		return fmt.Errorf("invalid asset class %q", details.AssetClass)
+	}
+	if details.OngoingChargeBps < 0 || details.OngoingChargeBps > maxFundOngoingChargeBps {
+		return fmt.Errorf("ongoing charge must be between 0 and %d basis points", maxFundOngoingChargeBps)
+	}
+
+	funds, err := fs.fundRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, fund := range funds {
+		if fund.ID != id && strings.EqualFold(fund.Name, details.Name) {
+			return fmt.Errorf("a fund named %q already exists", fund.Name)
+		}
+	}
+
+	return nil
 }
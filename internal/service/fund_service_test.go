@@ -0,0 +1,178 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFundAdministration(t *testing.T) {
+	ctx := context.Background()
+	fundService := service.NewFundService(repository.NewInMemoryFundRepository())
+
+	t.Run("Create and update a fund", func(t *testing.T) {
+		fund, err := fundService.CreateFund(ctx, domain.FundDetails{
+			Name:             "Global Property Fund",
+			Description:      "Listed property across developed markets",
+			RiskLevel:        domain.RiskLevelMedium,
+			AssetClass:       domain.AssetClassProperty,
+			OngoingChargeBps: 40,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.FundStatusOpen, fund.Status)
+
+		fund, err = fundService.UpdateFund(ctx, fund.ID, domain.FundDetails{
+			Name:             "Global Property Fund",
+			Description:      "Listed property worldwide",
+			RiskLevel:        domain.RiskLevelHigh,
+			AssetClass:       domain.AssetClassProperty,
+			OngoingChargeBps: 40,
+		}, fund.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RiskLevelHigh, fund.RiskLevel)
+		assert.Equal(t, "Listed property worldwide", fund.Description)
+		assert.Equal(t, int64(2), fund.Version)
+	})
+
+	t.Run("Stale versions are rejected", func(t *testing.T) {
+		fund, err := fundService.GetFund(ctx, "fund-3")
+		assert.NoError(t, err)
+		stale := fund.Version
+
+		_, err = fundService.SoftCloseFund(ctx, fund.ID, stale)
+		assert.NoError(t, err)
+
+		_, err = fundService.ReopenFund(ctx, fund.ID, stale)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+		_, err = fundService.UpdateFund(ctx, fund.ID, domain.FundDetails{Name: fund.Name, RiskLevel: fund.RiskLevel, AssetClass: fund.AssetClass}, stale)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		fund, err = fundService.ReopenFund(ctx, fund.ID, stale+1)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.FundStatusOpen, fund.Status)
+	})
+
+	t.Run("Invalid fund details are rejected", func(t *testing.T) {
+		valid := domain.FundDetails{Name: "Cash Fund", RiskLevel: domain.RiskLevelLow, AssetClass: domain.AssetClassCash, OngoingChargeBps: 10}
+
+		noName := valid
+		noName.Name = " "
+		_, err := fundService.CreateFund(ctx, noName)
+		assert.Error(t, err)
+
+		unknownRisk := valid
+		unknownRisk.RiskLevel = domain.RiskLevel("extreme")
+		_, err = fundService.CreateFund(ctx, unknownRisk)
+		assert.Error(t, err)
+
+		unknownAssetClass := valid
+		unknownAssetClass.AssetClass = domain.AssetClass("crypto")
+		_, err = fundService.CreateFund(ctx, unknownAssetClass)
+		assert.Error(t, err)
+
+		duplicate := valid
+		duplicate.Name = "bond fund"
+		_, err = fundService.CreateFund(ctx, duplicate)
+		assert.Error(t, err)
+	})
+
+	t.Run("Fund status lifecycle", func(t *testing.T) {
+		fund, err := fundService.SuspendFund(ctx, "fund-2", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.FundStatusSuspended, fund.Status)
+
+		_, err = fundService.SuspendFund(ctx, "fund-2", 0)
+		assert.ErrorIs(t, err, domain.ErrInvalidFundStatusChange)
+
+		fund, err = fundService.CloseFund(ctx, "fund-2", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.FundStatusClosed, fund.Status)
+
+		fund, err = fundService.ReopenFund(ctx, "fund-2", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.FundStatusOpen, fund.Status)
+	})
+}
+
+func TestListFundsByStatus(t *testing.T) {
+	ctx := context.Background()
+	fundService := service.NewFundService(repository.NewInMemoryFundRepository())
+
+	_, err := fundService.SuspendFund(ctx, "fund-1", 0)
+	assert.NoError(t, err)
+	_, err = fundService.SoftCloseFund(ctx, "fund-2", 0)
+	assert.NoError(t, err)
+
+	t.Run("Only open funds are listed by default", func(t *testing.T) {
+		funds, total, err := fundService.ListFunds(ctx, domain.FundFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, funds, 1)
+		assert.Equal(t, 1, total)
+		assert.Equal(t, "fund-3", funds[0].ID)
+	})
+
+	t.Run("Funds can be listed by status", func(t *testing.T) {
+		funds, _, err := fundService.ListFunds(ctx, domain.FundFilter{Statuses: []domain.FundStatus{domain.FundStatusSuspended, domain.FundStatusSoftClosed}})
+		assert.NoError(t, err)
+		assert.Len(t, funds, 2)
+	})
+}
+
+func TestSearchFunds(t *testing.T) {
+	ctx := context.Background()
+	fundService := service.NewFundService(repository.NewInMemoryFundRepository())
+
+	fundIDs := func(funds []*domain.Fund) []string {
+		ids := make([]string, 0, len(funds))
+		for _, fund := range funds {
+			ids = append(ids, fund.ID)
+		}
+		return ids
+	}
+
+	t.Run("Sorted by name by default", func(t *testing.T) {
+		funds, total, err := fundService.ListFunds(ctx, domain.FundFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"fund-2", "fund-3", "fund-1"}, fundIDs(funds))
+	})
+
+	t.Run("Text search matches name and description", func(t *testing.T) {
+		funds, _, err := fundService.ListFunds(ctx, domain.FundFilter{Query: "BONDS"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fund-2", "fund-3"}, fundIDs(funds))
+	})
+
+	t.Run("Filter by risk level, asset class and charges", func(t *testing.T) {
+		funds, _, err := fundService.ListFunds(ctx, domain.FundFilter{RiskLevels: []domain.RiskLevel{domain.RiskLevelHigh, domain.RiskLevelMedium}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fund-2", "fund-1"}, fundIDs(funds))
+
+		funds, _, err = fundService.ListFunds(ctx, domain.FundFilter{AssetClasses: []domain.AssetClass{domain.AssetClassFixedIncome}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fund-3"}, fundIDs(funds))
+
+		funds, _, err = fundService.ListFunds(ctx, domain.FundFilter{MaxOngoingChargeBps: 25})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fund-3", "fund-1"}, fundIDs(funds))
+	})
+
+	t.Run("Sort descending and paginate", func(t *testing.T) {
+		funds, total, err := fundService.ListFunds(ctx, domain.FundFilter{SortBy: domain.FundSortByOngoingCharge, SortDesc: true, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []string{"fund-2", "fund-1"}, fundIDs(funds))
+
+		funds, _, err = fundService.ListFunds(ctx, domain.FundFilter{SortBy: domain.FundSortByOngoingCharge, SortDesc: true, Limit: 2, Offset: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"fund-3"}, fundIDs(funds))
+	})
+
+	t.Run("Invalid sort field is rejected", func(t *testing.T) {
+		_, _, err := fundService.ListFunds(ctx, domain.FundFilter{SortBy: "popularity"})
+		assert.Error(t, err)
+	})
+}
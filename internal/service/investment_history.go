@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"time"
+)
+
+// investmentHistory makes every change to an investment by appending an event
+// to its history, then saving the state the event produces and posting the
+// resulting ledger journals, so an investment can always be rebuilt from its
+// events with domain.ReplayInvestment and its cash and units traced in the ledger.
+// The event log is the source of truth: appending the event is what makes the
+// change, and fails with domain.ErrConflict if the investment has moved on.
+//
+// Each change is one unit of work with the ledger: the journals are checked
+// before the event is appended, so a change the ledger would refuse, such as
+// selling more units than the customer holds, is never made, and no other
+// journal is posted until the change and its journals are recorded. Once the
+// event is appended, the investment and its journals are saved even if the
+// caller has gone away, so a cancelled request cannot leave them out.
+type investmentHistory struct {
+	investmentRepo domain.InvestmentRepository
+	eventRepo      domain.InvestmentEventRepository
+	ledger         domain.LedgerService
+}
+
+// create records the created event of a new pending investment and saves it.
+// It can be retried: if an earlier attempt recorded the event but did not save
+// the investment, the recorded event is kept and the investment saved from it.
+func (h investmentHistory) create(ctx context.Context, investment *domain.Investment) error {
+	event := &domain.InvestmentEvent{
+		ID:               uuid.New().String(),
+		InvestmentID:     investment.ID,
+		Sequence:         1,
+		Type:             domain.InvestmentEventCreated,
+		CustomerID:       investment.CustomerID,
+		FundID:           investment.FundID,
+		Amount:           investment.Amount,
+		InvestmentType:   investment.Type,
+		SwitchID:         investment.SwitchID,
+		RiskAcknowledged: investment.RiskAcknowledged,
+		OccurredAt:       investment.CreatedAt,
+	}
+	journals := journalsFor("", investment, event)
+
+	// An earlier attempt's event was posted to the ledger along with it
+	recorded := false
+	if events, err := h.eventRepo.GetByInvestmentID(ctx, investment.ID); err == nil && len(events) == 1 {
+		event, journals, recorded = events[0], nil, true
+	}
+
+	return h.commit(ctx, journals, func(ctx context.Context) error {
+		if recorded {
+			return nil
+		}
+		return h.eventRepo.Append(ctx, event)
+	}, func(ctx context.Context) error {
+		investment.Version = event.Sequence
+		return h.investmentRepo.Create(ctx, investment)
+	})
+}
+
+// record appends the event as the one after the version of the investment the
+// caller loaded, then applies it to the investment and saves it. The append
+// fails with domain.ErrConflict if the investment has moved on, in which case
+// nothing is changed.
+func (h investmentHistory) record(ctx context.Context, investment *domain.Investment, event *domain.InvestmentEvent) error {
+	event.ID = uuid.New().String()
+	event.InvestmentID = investment.ID
+	event.Sequence = investment.Version + 1
+	event.OccurredAt = time.Now()
+
+	changed := *investment
+	changed.Apply(event)
+	journals := journalsFor(investment.Status, &changed, event)
+
+	return h.commit(ctx, journals, func(ctx context.Context) error {
+		return h.eventRepo.Append(ctx, event)
+	}, func(ctx context.Context) error {
+		*investment = changed
+		return h.investmentRepo.Update(ctx, investment)
+	})
+}
+
+// commit makes a change as one unit of work with the ledger: once the ledger
+// has accepted the journals, appendEvent records the change, then save stores
+// the investment and the journals are posted. The event is the change, so its
+// journals are posted even if the investment cannot be saved, in which case
+// the error is returned and the investment can be rebuilt from its events.
+func (h investmentHistory) commit(ctx context.Context, journals []*domain.Journal, appendEvent, save func(ctx context.Context) error) error {
+	var saveErr error
+	err := h.ledger.PostWith(ctx, journals, func(ctx context.Context) error {
+		if err := appendEvent(ctx); err != nil {
+			return err
+		}
+		saveErr = save(context.WithoutCancel(ctx))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// journalsFor works out the ledger journals for an investment event, given the
+// status the investment had before the event.
+//
+// Subscriptions bring cash into client money when created. Processing deals at
+// the investment's price: buys spend the customer's cash on units, switch sell
+// legs turn units back into cash. Cancelling a processed subscription sells its
+// units back before refunding the cash, and withdrawing sells the units and pays
+// the cash out. Switch legs move no cash until they are processed.
+func journalsFor(previous domain.InvestmentStatus, investment *domain.Investment, event *domain.InvestmentEvent) []*domain.Journal {
+	journal := func(journalType domain.JournalType, postings ...[]domain.Posting) *domain.Journal {
+		j := &domain.Journal{
+			ID:         uuid.New().String(),
+			Type:       journalType,
+			CustomerID: investment.CustomerID,
+			Reference:  investment.ID,
+			PostedAt:   event.OccurredAt,
+		}
+		for _, p := range postings {
+			j.Postings = append(j.Postings, p...)
+		}
+		return j
+	}
+	buy := func() *domain.Journal {
+		return journal(domain.JournalTypeDealing,
+			cashOut(investment.CustomerID, investment.Amount),
+			unitsIssued(investment.CustomerID, investment.FundID, investment.Units))
+	}
+	sell := func() *domain.Journal {
+		return journal(domain.JournalTypeDealing,
+			unitsCancelled(investment.CustomerID, investment.FundID, investment.Units),
+			cashIn(investment.CustomerID, investment.Amount))
+	}
+
+	switch event.Type {
+	case domain.InvestmentEventCreated:
+		if investment.Type == domain.InvestmentTypeSubscription {
+			return []*domain.Journal{journal(domain.JournalTypeSubscription, cashIn(investment.CustomerID, investment.Amount))}
+		}
+	case domain.InvestmentEventProcessed:
+		if investment.Type == domain.InvestmentTypeSwitchOut {
+			return []*domain.Journal{sell()}
+		}
+		return []*domain.Journal{buy()}
+	case domain.InvestmentEventCancelled:
+		if investment.Type != domain.InvestmentTypeSubscription {
+			return nil
+		}
+		refund := journal(domain.JournalTypeRefund, cashOut(investment.CustomerID, investment.Amount))
+		if previous == domain.InvestmentStatusProcessed {
+			return []*domain.Journal{sell(), refund}
+		}
+		return []*domain.Journal{refund}
+	case domain.InvestmentEventWithdrawn:
+		return []*domain.Journal{sell(), journal(domain.JournalTypeWithdrawal, cashOut(investment.CustomerID, investment.Amount))}
+	}
+
+	return nil
+}
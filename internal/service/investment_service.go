@@ -1,35 +1,58 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
 	"time"
 )
 
+// Page sizes when listing a customer's investments
+const (
+	defaultInvestmentPageSize = 50
+	maxInvestmentPageSize     = 100
+)
+
+// unitsScale is the number of units recorded per whole unit (thousandths)
+const unitsScale = 1000
+
 type investmentService struct {
 	investmentRepo domain.InvestmentRepository
+	eventRepo      domain.InvestmentEventRepository
 	customerRepo   domain.CustomerRepository
 	fundRepo       domain.FundRepository
+	history        investmentHistory
+	rules          domain.AllowanceRules
+	// customers makes each customer's subscriptions one at a time, so two cannot
+	// both fit in the allowance left and together go over it
+	customers stripedMutex
 }
 
 // NewInvestmentService creates a new instance of investment service
 func NewInvestmentService(
 	ir domain.InvestmentRepository,
+	er domain.InvestmentEventRepository,
 	cr domain.CustomerRepository,
 	fr domain.FundRepository,
+	ls domain.LedgerService,
+	rules domain.AllowanceRules,
 ) domain.InvestmentService {
 	return &investmentService{
 		investmentRepo: ir,
+		eventRepo:      er,
 		customerRepo:   cr,
 		fundRepo:       fr,
+		history:        investmentHistory{investmentRepo: ir, eventRepo: er, ledger: ls},
+		rules:          rules,
 	}
 }
 
 // CreateInvestment creates a new investment
-func (is *investmentService) CreateInvestment(customerID, fundID string, amount int64) (*domain.Investment, error) {
+func (is *investmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
 	// Check if customer exists
-	customer, err := is.customerRepo.GetByID(customerID)
+	customer, err := is.customerRepo.GetByID(ctx, customerID)
 	if err != nil {
 		return nil, err
 	}
@@ -38,38 +61,54 @@ func (is *investmentService) CreateInvestment(customerID, fundID string, amount
 	}
 
 	// Check if fund exists
-	fund, err := is.fundRepo.GetByID(fundID)
+	fund, err := is.fundRepo.GetByID(ctx, fundID)
 	if err != nil {
 		return nil, err
 	}
 	if fund == nil {
 		return nil, errors.New("fund not found")
 	}
+	if err := checkFundAcceptsMoney(fund); err != nil {
+		return nil, err
+	}
+
+	// Funds riskier than the customer's tolerance need explicit acknowledgement
+	riskWarning := exceedsRiskTolerance(customer, fund)
+	if riskWarning && !riskAcknowledged {
+		return nil, domain.ErrRiskNotAcknowledged
+	}
 
 	// Validate amount
 	if amount <= 0 {
 		return nil, errors.New("investment amount must be positive")
 	}
 
-	// ISA annual limit check - this would be more comprehensive in a real implementation
-	const ISA_ANNUAL_LIMIT_IN_PENCE = 2000000 // £20,000 in pence
-	if amount > ISA_ANNUAL_LIMIT_IN_PENCE {
-		return nil, errors.New("investment exceeds ISA annual limit of £20,000")
+	// ISA annual limit check against everything subscribed so far this tax
+	// year, held until the subscription is saved
+	defer is.customers.lock(customerID)()
+	existing, err := is.investmentRepo.GetByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if usedAllowance(existing, is.rules, time.Now())+amount > is.rules.AnnualLimit {
+		return nil, is.rules.Exceeded()
 	}
 
 	// Create investment
 	investment := &domain.Investment{
-		ID:         uuid.New().String(),
-		CustomerID: customerID,
-		FundID:     fundID,
-		Amount:     amount,
-		Status:     domain.InvestmentStatusPending,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ID:               uuid.New().String(),
+		CustomerID:       customerID,
+		FundID:           fundID,
+		Amount:           amount,
+		Type:             domain.InvestmentTypeSubscription,
+		RiskAcknowledged: riskWarning,
+		Status:           domain.InvestmentStatusPending,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 
 	// Save investment
-	err = is.investmentRepo.Create(investment)
+	err = is.history.create(ctx, investment)
 	if err != nil {
 		return nil, err
 	}
@@ -78,11 +117,253 @@ func (is *investmentService) CreateInvestment(customerID, fundID string, amount
 }
 
 // GetInvestment gets an investment by ID
-func (is *investmentService) GetInvestment(id string) (*domain.Investment, error) {
-	return is.investmentRepo.GetByID(id)
+func (is *investmentService) GetInvestment(ctx context.Context, id string) (*domain.Investment, error) {
+	return is.investmentRepo.GetByID(ctx, id)
 }
 
 // GetCustomerInvestments gets all investments for a customer
-func (is *investmentService) GetCustomerInvestments(customerID string) ([]*domain.Investment, error) {
-	return is.investmentRepo.GetByCustomerID(customerID)
+func (is *investmentService) GetCustomerInvestments(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	return is.investmentRepo.GetByCustomerID(ctx, customerID)
+}
+
+// CancelInvestment cancels a pending or processed investment. Switch legs
+// cannot be cancelled on their own as that would unbalance the switch.
+func (is *investmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	investment, err := is.getForChange(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case investment.Status == domain.InvestmentStatusCancelled:
+		return nil, fmt.Errorf("%w: it is already cancelled", domain.ErrInvestmentNotCancellable)
+	case investment.Status == domain.InvestmentStatusWithdrawn:
+		return nil, fmt.Errorf("%w: it has been withdrawn", domain.ErrInvestmentNotCancellable)
+	case investment.SwitchID != "":
+		return nil, fmt.Errorf("%w: switch legs cannot be cancelled individually", domain.ErrInvestmentNotCancellable)
+	}
+
+	if err := is.history.record(ctx, investment, &domain.InvestmentEvent{Type: domain.InvestmentEventCancelled}); err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// PriceInvestment prices a pending investment, working out the units it buys
+func (is *investmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	if unitPrice <= 0 {
+		return nil, errors.New("unit price must be positive")
+	}
+
+	investment, err := is.getForChange(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if investment.Status != domain.InvestmentStatusPending {
+		return nil, fmt.Errorf("%w: only pending investments can be priced", domain.ErrInvalidInvestmentStatusChange)
+	}
+	if investment.UnitPrice != 0 {
+		return nil, fmt.Errorf("%w: it has already been priced", domain.ErrInvalidInvestmentStatusChange)
+	}
+
+	units := investment.Amount * unitsScale / unitPrice
+	if units == 0 {
+		return nil, errors.New("unit price is too high for the investment to buy any units")
+	}
+
+	event := &domain.InvestmentEvent{
+		Type:      domain.InvestmentEventPriced,
+		UnitPrice: unitPrice,
+		Units:     units,
+	}
+	if err := is.history.record(ctx, investment, event); err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// ProcessInvestment settles a pending investment once it has been priced
+func (is *investmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	investment, err := is.getForChange(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if investment.Status != domain.InvestmentStatusPending {
+		return nil, fmt.Errorf("%w: only pending investments can be processed", domain.ErrInvalidInvestmentStatusChange)
+	}
+	if investment.UnitPrice == 0 {
+		return nil, fmt.Errorf("%w: it has not been priced", domain.ErrInvalidInvestmentStatusChange)
+	}
+
+	if err := is.history.record(ctx, investment, &domain.InvestmentEvent{Type: domain.InvestmentEventProcessed}); err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// WithdrawInvestment withdraws a processed holding. Withdrawals do not give back
+// ISA allowance, and the customer must still hold the amount in the fund.
+func (is *investmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	investment, err := is.getForChange(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if investment.Status != domain.InvestmentStatusProcessed {
+		return nil, fmt.Errorf("%w: only processed investments can be withdrawn", domain.ErrInvalidInvestmentStatusChange)
+	}
+	if investment.Type == domain.InvestmentTypeSwitchOut {
+		return nil, fmt.Errorf("%w: switch sell legs hold nothing to withdraw", domain.ErrInvalidInvestmentStatusChange)
+	}
+
+	investments, err := is.investmentRepo.GetByCustomerID(ctx, investment.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if holdingsByFund(investments)[investment.FundID] < investment.Amount {
+		return nil, domain.ErrInsufficientHoldings
+	}
+
+	if err := is.history.record(ctx, investment, &domain.InvestmentEvent{Type: domain.InvestmentEventWithdrawn}); err != nil {
+		return nil, err
+	}
+
+	return investment, nil
+}
+
+// GetInvestmentEvents gets the events recorded for an investment, oldest first
+func (is *investmentService) GetInvestmentEvents(ctx context.Context, id string) ([]*domain.InvestmentEvent, error) {
+	return is.eventRepo.GetByInvestmentID(ctx, id)
+}
+
+// getForChange loads an investment that is about to change, checking the caller saw its current version
+func (is *investmentService) getForChange(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	investment, err := is.investmentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(investment.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	return investment, nil
+}
+
+// ListCustomerInvestments gets one page of a customer's investments, oldest first
+func (is *investmentService) ListCustomerInvestments(ctx context.Context, query domain.InvestmentQuery) (*domain.InvestmentPage, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultInvestmentPageSize
+	}
+	if query.Limit < 0 || query.Limit > maxInvestmentPageSize {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxInvestmentPageSize)
+	}
+	if !query.CreatedFrom.IsZero() && !query.CreatedTo.IsZero() && !query.CreatedFrom.Before(query.CreatedTo) {
+		return nil, errors.New("date range start must be before its end")
+	}
+
+	filter := domain.InvestmentFilter{
+		CustomerID:  query.CustomerID,
+		Statuses:    query.Statuses,
+		FundID:      query.FundID,
+		CreatedFrom: query.CreatedFrom,
+		CreatedTo:   query.CreatedTo,
+		// Fetch one extra investment to find out whether there is another page
+		Limit: query.Limit + 1,
+	}
+	if query.Cursor != "" {
+		cursor, err := domain.DecodeInvestmentCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = &cursor
+	}
+
+	investments, err := is.investmentRepo.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &domain.InvestmentPage{Investments: investments}
+	if len(investments) > query.Limit {
+		page.Investments = investments[:query.Limit]
+		last := page.Investments[query.Limit-1]
+		page.NextCursor = domain.InvestmentCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	return page, nil
+}
+
+// checkVersion fails with ErrConflict when the caller expected a different version.
+// An expected version of 0 means the caller did not ask for the check.
+func checkVersion(current, expected int64) error {
+	if expected != 0 && current != expected {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+// usedAllowance sums the subscriptions made in the tax year containing now.
+// Switch legs and cancelled investments do not consume allowance; withdrawn ones still do.
+func usedAllowance(investments []*domain.Investment, rules domain.AllowanceRules, now time.Time) int64 {
+	start := rules.TaxYearStart(now)
+
+	var used int64
+	for _, investment := range investments {
+		if investment.Type != domain.InvestmentTypeSubscription ||
+			investment.Status == domain.InvestmentStatusCancelled ||
+			investment.CreatedAt.Before(start) {
+			continue
+		}
+		used += investment.Amount
+	}
+
+	return used
+}
+
+// checkFundAcceptsMoney rejects new money into any fund that is not open.
+// Suspended funds return ErrFundSuspended so callers can queue and retry.
+func checkFundAcceptsMoney(fund *domain.Fund) error {
+	switch fund.Status {
+	case domain.FundStatusOpen:
+		return nil
+	case domain.FundStatusSuspended:
+		return domain.ErrFundSuspended
+	default:
+		return domain.ErrFundNotOpen
+	}
+}
+
+// exceedsRiskTolerance reports whether the fund is riskier than the customer's
+// tolerance. Customers who have not completed the risk questionnaire are not gated.
+func exceedsRiskTolerance(customer *domain.Customer, fund *domain.Fund) bool {
+	return customer.RiskTolerance != "" && fund.RiskLevel.Exceeds(customer.RiskTolerance)
+}
+
+// holdingsByFund calculates the value held in each fund from a customer's
+// investments, netting off switches and ignoring cancelled and withdrawn investments
+func holdingsByFund(investments []*domain.Investment) map[string]int64 {
+	holdings := make(map[string]int64)
+	for _, investment := range investments {
+		if investment.Status == domain.InvestmentStatusCancelled || investment.Status == domain.InvestmentStatusWithdrawn {
+			continue
+		}
+		switch investment.Type {
+		case domain.InvestmentTypeSwitchOut:
+			holdings[investment.FundID] -= investment.Amount
+		default:
+			holdings[investment.FundID] += investment.Amount
+		}
+	}
+
+	return holdings
+}
+
+// OldestUnpricedAge is how long the oldest pending subscription has been waiting
+// for its unit price at now, or zero when none is waiting
+func OldestUnpricedAge(ctx context.Context, investments domain.InvestmentRepository, now time.Time) (time.Duration, error) {
+	oldest, err := investments.OldestUnpriced(ctx)
+	if err != nil || oldest == nil {
+		return 0, err
+	}
+	return now.Sub(oldest.CreatedAt), nil
 }
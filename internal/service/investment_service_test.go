@@ -1,11 +1,19 @@
 package service_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
 	"github.com/grokkos/go-isa-retail-service/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // Mock InvestmentRepository
@@ -13,7 +21,7 @@ type mockInvestmentRepository struct {
 	mock.Mock
 }
 
-func (m *mockInvestmentRepository) GetByID(id string) (*domain.Investment, error) {
+func (m *mockInvestmentRepository) GetByID(ctx context.Context, id string) (*domain.Investment, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -21,7 +29,7 @@ func (m *mockInvestmentRepository) GetByID(id string) (*domain.Investment, error
 	return args.Get(0).(*domain.Investment), args.Error(1)
 }
 
-func (m *mockInvestmentRepository) GetByCustomerID(customerID string) ([]*domain.Investment, error) {
+func (m *mockInvestmentRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Investment, error) {
 	args := m.Called(customerID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -29,12 +37,36 @@ func (m *mockInvestmentRepository) GetByCustomerID(customerID string) ([]*domain
 	return args.Get(0).([]*domain.Investment), args.Error(1)
 }
 
-func (m *mockInvestmentRepository) Create(investment *domain.Investment) error {
+func (m *mockInvestmentRepository) GetByFundID(ctx context.Context, fundID string) ([]*domain.Investment, error) {
+	args := m.Called(fundID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentRepository) OldestUnpriced(ctx context.Context) (*domain.Investment, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentRepository) Find(ctx context.Context, filter domain.InvestmentFilter) ([]*domain.Investment, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentRepository) Create(ctx context.Context, investment *domain.Investment) error {
 	args := m.Called(investment)
 	return args.Error(0)
 }
 
-func (m *mockInvestmentRepository) Update(investment *domain.Investment) error {
+func (m *mockInvestmentRepository) Update(ctx context.Context, investment *domain.Investment) error {
 	args := m.Called(investment)
 	return args.Error(0)
 }
@@ -44,7 +76,7 @@ type mockCustomerRepository struct {
 	mock.Mock
 }
 
-func (m *mockCustomerRepository) GetByID(id string) (*domain.Customer, error) {
+func (m *mockCustomerRepository) GetByID(ctx context.Context, id string) (*domain.Customer, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -52,12 +84,12 @@ func (m *mockCustomerRepository) GetByID(id string) (*domain.Customer, error) {
 	return args.Get(0).(*domain.Customer), args.Error(1)
 }
 
-func (m *mockCustomerRepository) Create(customer *domain.Customer) error {
+func (m *mockCustomerRepository) Create(ctx context.Context, customer *domain.Customer) error {
 	args := m.Called(customer)
 	return args.Error(0)
 }
 
-func (m *mockCustomerRepository) Update(customer *domain.Customer) error {
+func (m *mockCustomerRepository) Update(ctx context.Context, customer *domain.Customer) error {
 	args := m.Called(customer)
 	return args.Error(0)
 }
@@ -67,7 +99,7 @@ type mockFundRepository struct {
 	mock.Mock
 }
 
-func (m *mockFundRepository) GetByID(id string) (*domain.Fund, error) {
+func (m *mockFundRepository) GetByID(ctx context.Context, id string) (*domain.Fund, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -75,7 +107,7 @@ func (m *mockFundRepository) GetByID(id string) (*domain.Fund, error) {
 	return args.Get(0).(*domain.Fund), args.Error(1)
 }
 
-func (m *mockFundRepository) GetAll() ([]*domain.Fund, error) {
+func (m *mockFundRepository) GetAll(ctx context.Context) ([]*domain.Fund, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -83,7 +115,26 @@ func (m *mockFundRepository) GetAll() ([]*domain.Fund, error) {
 	return args.Get(0).([]*domain.Fund), args.Error(1)
 }
 
+func (m *mockFundRepository) Find(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*domain.Fund), args.Int(1), args.Error(2)
+}
+
+func (m *mockFundRepository) Create(ctx context.Context, fund *domain.Fund) error {
+	args := m.Called(fund)
+	return args.Error(0)
+}
+
+func (m *mockFundRepository) Update(ctx context.Context, fund *domain.Fund) error {
+	args := m.Called(fund)
+	return args.Error(0)
+}
+
 func TestInvestmentValidation(t *testing.T) {
+	ctx := context.Background()
 	// Create separate mocks for each repository type
 	mockInvestRepo := new(mockInvestmentRepository)
 	mockCustomerRepo := new(mockCustomerRepository)
@@ -92,22 +143,26 @@ func TestInvestmentValidation(t *testing.T) {
 	// Set up the investment service
 	investmentService := service.NewInvestmentService(
 		mockInvestRepo,
+		repository.NewInMemoryInvestmentEventRepository(),
 		mockCustomerRepo,
 		mockFundRepo,
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
 	)
 
 	// Set up test data
 	mockCustomer := &domain.Customer{ID: "customer-1", Name: "Test Customer"}
-	mockFund := &domain.Fund{ID: "fund-1", Name: "Test Fund"}
+	mockFund := &domain.Fund{ID: "fund-1", Name: "Test Fund", Status: domain.FundStatusOpen}
 
 	// Configure mocks to return our test data
 	mockCustomerRepo.On("GetByID", "customer-1").Return(mockCustomer, nil)
 	mockFundRepo.On("GetByID", "fund-1").Return(mockFund, nil)
+	mockInvestRepo.On("GetByCustomerID", "customer-1").Return([]*domain.Investment{}, nil)
 	mockInvestRepo.On("Create", mock.AnythingOfType("*domain.Investment")).Return(nil)
 
 	// Test case 1: Successful investment within ISA limit
 	t.Run("Valid investment within ISA limit", func(t *testing.T) {
-		investment, err := investmentService.CreateInvestment("customer-1", "fund-1", 1500000) // £15,000
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1500000, false) // £15,000
 		assert.NoError(t, err)
 		assert.NotNil(t, investment)
 		assert.Equal(t, int64(1500000), investment.Amount)
@@ -115,9 +170,389 @@ func TestInvestmentValidation(t *testing.T) {
 
 	// Test case 2: Investment exceeding ISA limit
 	t.Run("Investment exceeding ISA limit", func(t *testing.T) {
-		investment, err := investmentService.CreateInvestment("customer-1", "fund-1", 2500000) // £25,000
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 2500000, false) // £25,000
 		assert.Error(t, err)
 		assert.Nil(t, investment)
 		assert.Contains(t, err.Error(), "exceeds ISA annual limit")
 	})
 }
+
+func TestInvestmentAllowanceIsCumulative(t *testing.T) {
+	ctx := context.Background()
+	mockInvestRepo := new(mockInvestmentRepository)
+	mockCustomerRepo := new(mockCustomerRepository)
+	mockFundRepo := new(mockFundRepository)
+
+	investmentService := service.NewInvestmentService(mockInvestRepo, repository.NewInMemoryInvestmentEventRepository(), mockCustomerRepo, mockFundRepo, newLedgerService(), domain.DefaultAllowanceRules)
+
+	// Customer has already subscribed £15,000 this tax year and switched £5,000 between funds
+	existing := []*domain.Investment{
+		{ID: "inv-1", CustomerID: "customer-1", FundID: "fund-1", Amount: 1500000, Type: domain.InvestmentTypeSubscription, Status: domain.InvestmentStatusProcessed, CreatedAt: time.Now()},
+		{ID: "inv-2", CustomerID: "customer-1", FundID: "fund-1", Amount: 500000, Type: domain.InvestmentTypeSwitchOut, Status: domain.InvestmentStatusPending, CreatedAt: time.Now()},
+		{ID: "inv-3", CustomerID: "customer-1", FundID: "fund-3", Amount: 500000, Type: domain.InvestmentTypeSwitchIn, Status: domain.InvestmentStatusPending, CreatedAt: time.Now()},
+	}
+
+	mockCustomerRepo.On("GetByID", "customer-1").Return(&domain.Customer{ID: "customer-1"}, nil)
+	mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", Status: domain.FundStatusOpen}, nil)
+	mockInvestRepo.On("GetByCustomerID", "customer-1").Return(existing, nil)
+	mockInvestRepo.On("Create", mock.AnythingOfType("*domain.Investment")).Return(nil)
+
+	t.Run("Switches do not consume allowance", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 500000, false) // £5,000
+		assert.NoError(t, err)
+		assert.Equal(t, domain.InvestmentTypeSubscription, investment.Type)
+	})
+
+	t.Run("Remaining allowance cannot be exceeded", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 500001, false)
+		assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+		assert.Nil(t, investment)
+	})
+}
+
+// slowInvestmentRepository takes a while to read a customer's investments, so
+// concurrent subscriptions overlap between the allowance check and the save
+type slowInvestmentRepository struct {
+	domain.InvestmentRepository
+}
+
+func (r *slowInvestmentRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	investments, err := r.InvestmentRepository.GetByCustomerID(ctx, customerID)
+	time.Sleep(10 * time.Millisecond)
+	return investments, err
+}
+
+func TestConcurrentSubscriptionsCannotExceedAllowance(t *testing.T) {
+	ctx := context.Background()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	investmentService := service.NewInvestmentService(
+		&slowInvestmentRepository{InvestmentRepository: investmentRepo},
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	)
+
+	// Ten £3,000 subscriptions at once, when only six fit in the £20,000 allowance
+	var wg sync.WaitGroup
+	var created atomic.Int64
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 300000, false)
+			if err == nil {
+				created.Add(1)
+			} else {
+				assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(6), created.Load())
+	investments, err := investmentRepo.GetByCustomerID(ctx, "customer-1")
+	require.NoError(t, err)
+	var subscribed int64
+	for _, investment := range investments {
+		subscribed += investment.Amount
+	}
+	assert.LessOrEqual(t, subscribed, domain.DefaultAllowanceRules.AnnualLimit)
+}
+
+func TestInvestmentAllowanceRules(t *testing.T) {
+	ctx := context.Background()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	// A £1,000 allowance in a tax year starting on 1 January
+	rules := domain.AllowanceRules{AnnualLimit: 100000, TaxYearStartMonth: time.January, TaxYearStartDay: 1}
+	investmentService := service.NewInvestmentService(investmentRepo, repository.NewInMemoryInvestmentEventRepository(), repository.NewInMemoryCustomerRepository(), repository.NewInMemoryFundRepository(), newLedgerService(), rules)
+
+	// Subscriptions made before this tax year started do not count
+	lastYear := rules.TaxYearStart(time.Now()).Add(-time.Hour)
+	assert.NoError(t, investmentRepo.Create(ctx, &domain.Investment{ID: "inv-old", CustomerID: "customer-1", FundID: "fund-1", Amount: 100000, Type: domain.InvestmentTypeSubscription, Status: domain.InvestmentStatusProcessed, CreatedAt: lastYear}))
+
+	_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	assert.NoError(t, err)
+
+	_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1, false)
+	assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+	assert.EqualError(t, err, "investment exceeds ISA annual limit of £1,000")
+}
+
+func TestInvestmentRiskSuitability(t *testing.T) {
+	ctx := context.Background()
+	mockInvestRepo := new(mockInvestmentRepository)
+	mockCustomerRepo := new(mockCustomerRepository)
+	mockFundRepo := new(mockFundRepository)
+
+	investmentService := service.NewInvestmentService(mockInvestRepo, repository.NewInMemoryInvestmentEventRepository(), mockCustomerRepo, mockFundRepo, newLedgerService(), domain.DefaultAllowanceRules)
+
+	// A cautious customer choosing a high risk fund
+	mockCustomerRepo.On("GetByID", "customer-1").Return(&domain.Customer{ID: "customer-1", RiskTolerance: domain.RiskLevelLow}, nil)
+	mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", RiskLevel: domain.RiskLevelHigh, Status: domain.FundStatusOpen}, nil)
+	mockFundRepo.On("GetByID", "fund-3").Return(&domain.Fund{ID: "fund-3", RiskLevel: domain.RiskLevelLow, Status: domain.FundStatusOpen}, nil)
+	mockInvestRepo.On("GetByCustomerID", "customer-1").Return([]*domain.Investment{}, nil)
+	mockInvestRepo.On("Create", mock.AnythingOfType("*domain.Investment")).Return(nil)
+
+	t.Run("Fund riskier than tolerance requires acknowledgement", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+		assert.ErrorIs(t, err, domain.ErrRiskNotAcknowledged)
+		assert.Nil(t, investment)
+	})
+
+	t.Run("Acknowledged risk is recorded on the investment", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, true)
+		assert.NoError(t, err)
+		assert.True(t, investment.RiskAcknowledged)
+	})
+
+	t.Run("Fund within tolerance needs no acknowledgement", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-3", 100000, false)
+		assert.NoError(t, err)
+		assert.False(t, investment.RiskAcknowledged)
+	})
+}
+
+func TestInvestmentFundStatus(t *testing.T) {
+	ctx := context.Background()
+	mockInvestRepo := new(mockInvestmentRepository)
+	mockCustomerRepo := new(mockCustomerRepository)
+	mockFundRepo := new(mockFundRepository)
+
+	investmentService := service.NewInvestmentService(mockInvestRepo, repository.NewInMemoryInvestmentEventRepository(), mockCustomerRepo, mockFundRepo, newLedgerService(), domain.DefaultAllowanceRules)
+
+	mockCustomerRepo.On("GetByID", "customer-1").Return(&domain.Customer{ID: "customer-1"}, nil)
+	mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", Status: domain.FundStatusSoftClosed}, nil)
+	mockFundRepo.On("GetByID", "fund-2").Return(&domain.Fund{ID: "fund-2", Status: domain.FundStatusSuspended}, nil)
+	mockFundRepo.On("GetByID", "fund-3").Return(&domain.Fund{ID: "fund-3", Status: domain.FundStatusClosed}, nil)
+
+	t.Run("Soft-closed funds reject new money", func(t *testing.T) {
+		_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+		assert.ErrorIs(t, err, domain.ErrFundNotOpen)
+	})
+
+	t.Run("Suspended funds are gated", func(t *testing.T) {
+		_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-2", 100000, false)
+		assert.ErrorIs(t, err, domain.ErrFundSuspended)
+	})
+
+	t.Run("Closed funds reject new money", func(t *testing.T) {
+		_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-3", 100000, false)
+		assert.ErrorIs(t, err, domain.ErrFundNotOpen)
+	})
+
+	mockInvestRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestListCustomerInvestments(t *testing.T) {
+	ctx := context.Background()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	investmentService := service.NewInvestmentService(investmentRepo, repository.NewInMemoryInvestmentEventRepository(), new(mockCustomerRepository), new(mockFundRepository), newLedgerService(), domain.DefaultAllowanceRules)
+
+	// Five investments a day apart, plus one for another customer
+	start := time.Date(2025, time.May, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		status := domain.InvestmentStatusPending
+		if i%2 == 0 {
+			status = domain.InvestmentStatusProcessed
+		}
+		assert.NoError(t, investmentRepo.Create(ctx, &domain.Investment{
+			ID:         fmt.Sprintf("inv-%d", i),
+			CustomerID: "customer-1",
+			FundID:     fmt.Sprintf("fund-%d", i%2+1),
+			Amount:     10000,
+			Type:       domain.InvestmentTypeSubscription,
+			Status:     status,
+			CreatedAt:  start.AddDate(0, 0, i),
+		}))
+	}
+	assert.NoError(t, investmentRepo.Create(ctx, &domain.Investment{ID: "inv-other", CustomerID: "customer-2", CreatedAt: start}))
+
+	ids := func(investments []*domain.Investment) []string {
+		result := make([]string, 0, len(investments))
+		for _, investment := range investments {
+			result = append(result, investment.ID)
+		}
+		return result
+	}
+
+	t.Run("Pages through investments oldest first", func(t *testing.T) {
+		page, err := investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{CustomerID: "customer-1", Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-0", "inv-1"}, ids(page.Investments))
+		assert.NotEmpty(t, page.NextCursor)
+
+		page, err = investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{CustomerID: "customer-1", Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-2", "inv-3"}, ids(page.Investments))
+
+		page, err = investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{CustomerID: "customer-1", Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-4"}, ids(page.Investments))
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Filters by status, fund and date range", func(t *testing.T) {
+		page, err := investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{
+			CustomerID: "customer-1",
+			Statuses:   []domain.InvestmentStatus{domain.InvestmentStatusProcessed},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-0", "inv-2", "inv-4"}, ids(page.Investments))
+
+		page, err = investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{CustomerID: "customer-1", FundID: "fund-2"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-1", "inv-3"}, ids(page.Investments))
+
+		page, err = investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{
+			CustomerID:  "customer-1",
+			CreatedFrom: start.AddDate(0, 0, 1),
+			CreatedTo:   start.AddDate(0, 0, 3),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"inv-1", "inv-2"}, ids(page.Investments))
+	})
+
+	t.Run("Invalid cursor is rejected", func(t *testing.T) {
+		_, err := investmentService.ListCustomerInvestments(ctx, domain.InvestmentQuery{CustomerID: "customer-1", Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+	})
+}
+
+func TestInvestmentEventHistory(t *testing.T) {
+	ctx := context.Background()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	eventRepo := repository.NewInMemoryInvestmentEventRepository()
+	investmentService := service.NewInvestmentService(investmentRepo, eventRepo, repository.NewInMemoryCustomerRepository(), repository.NewInMemoryFundRepository(), newLedgerService(), domain.DefaultAllowanceRules)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	assert.NoError(t, err)
+
+	t.Run("Processing needs a price first", func(t *testing.T) {
+		_, err := investmentService.ProcessInvestment(ctx, investment.ID, investment.Version)
+		assert.ErrorIs(t, err, domain.ErrInvalidInvestmentStatusChange)
+	})
+
+	t.Run("Each change is recorded as an event", func(t *testing.T) {
+		priced, err := investmentService.PriceInvestment(ctx, investment.ID, 125, investment.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(800000), priced.Units) // £1,000 at £1.25 buys 800 units
+
+		_, err = investmentService.WithdrawInvestment(ctx, investment.ID, priced.Version)
+		assert.ErrorIs(t, err, domain.ErrInvalidInvestmentStatusChange)
+
+		processed, err := investmentService.ProcessInvestment(ctx, investment.ID, priced.Version)
+		assert.NoError(t, err)
+		_, err = investmentService.WithdrawInvestment(ctx, investment.ID, processed.Version)
+		assert.NoError(t, err)
+
+		events, err := investmentService.GetInvestmentEvents(ctx, investment.ID)
+		assert.NoError(t, err)
+		types := make([]domain.InvestmentEventType, 0, len(events))
+		for i, event := range events {
+			assert.Equal(t, int64(i+1), event.Sequence)
+			types = append(types, event.Type)
+		}
+		assert.Equal(t, []domain.InvestmentEventType{
+			domain.InvestmentEventCreated,
+			domain.InvestmentEventPriced,
+			domain.InvestmentEventProcessed,
+			domain.InvestmentEventWithdrawn,
+		}, types)
+	})
+
+	t.Run("Replaying the events rebuilds the current state", func(t *testing.T) {
+		events, _ := eventRepo.GetByInvestmentID(ctx, investment.ID)
+		replayed, err := domain.ReplayInvestment(events)
+		assert.NoError(t, err)
+
+		stored, _ := investmentRepo.GetByID(ctx, investment.ID)
+		assert.Equal(t, stored, replayed)
+		assert.Equal(t, domain.InvestmentStatusWithdrawn, replayed.Status)
+	})
+
+	t.Run("Withdrawn investments cannot be cancelled and still use allowance", func(t *testing.T) {
+		_, err := investmentService.CancelInvestment(ctx, investment.ID, 0)
+		assert.ErrorIs(t, err, domain.ErrInvestmentNotCancellable)
+
+		_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1950000, false)
+		assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+	})
+}
+
+// failingEventRepository refuses to append events once failing is set
+type failingEventRepository struct {
+	domain.InvestmentEventRepository
+	failing bool
+}
+
+func (r *failingEventRepository) Append(ctx context.Context, event *domain.InvestmentEvent) error {
+	if r.failing {
+		return errors.New("event store unavailable")
+	}
+	return r.InvestmentEventRepository.Append(ctx, event)
+}
+
+func TestInvestmentChangesAreRecordedBeforeSaving(t *testing.T) {
+	ctx := context.Background()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	eventRepo := &failingEventRepository{InvestmentEventRepository: repository.NewInMemoryInvestmentEventRepository()}
+	investmentService := service.NewInvestmentService(investmentRepo, eventRepo, repository.NewInMemoryCustomerRepository(), repository.NewInMemoryFundRepository(), newLedgerService(), domain.DefaultAllowanceRules)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	require.NoError(t, err)
+
+	t.Run("A change whose event cannot be appended is not saved", func(t *testing.T) {
+		eventRepo.failing = true
+		defer func() { eventRepo.failing = false }()
+
+		_, err := investmentService.PriceInvestment(ctx, investment.ID, 125, investment.Version)
+		assert.Error(t, err)
+
+		stored, err := investmentRepo.GetByID(ctx, investment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, investment.Version, stored.Version)
+		assert.Zero(t, stored.UnitPrice)
+	})
+
+	t.Run("An event that is not next in the sequence is refused", func(t *testing.T) {
+		err := eventRepo.Append(ctx, &domain.InvestmentEvent{InvestmentID: investment.ID, Sequence: investment.Version, Type: domain.InvestmentEventCancelled})
+		assert.ErrorIs(t, err, domain.ErrConflict)
+
+		events, err := eventRepo.GetByInvestmentID(ctx, investment.ID)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+}
+
+func TestOldestUnpricedAge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	investments := repository.NewInMemoryInvestmentRepository()
+
+	pending := func(id string, age time.Duration, unitPrice int64) {
+		require.NoError(t, investments.Create(ctx, &domain.Investment{
+			ID:         id,
+			CustomerID: "customer-1",
+			FundID:     "fund-1",
+			Amount:     10000,
+			Status:     domain.InvestmentStatusPending,
+			UnitPrice:  unitPrice,
+			CreatedAt:  now.Add(-age),
+		}))
+	}
+
+	age, err := service.OldestUnpricedAge(ctx, investments, now)
+	assert.NoError(t, err)
+	assert.Zero(t, age)
+
+	// Priced subscriptions are no longer waiting
+	pending("inv-1", time.Hour, 0)
+	pending("inv-2", 48*time.Hour, 250)
+	pending("inv-3", 25*time.Hour, 0)
+
+	age, err = service.OldestUnpricedAge(ctx, investments, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 25*time.Hour, age)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+	"time"
+)
+
+type ledgerService struct {
+	// mutex serialises postings, so nothing can spend cash or units between
+	// them being checked and the journals that spend them being recorded
+	mutex        sync.Mutex
+	ledgerRepo   domain.LedgerRepository
+	customerRepo domain.CustomerRepository
+}
+
+// NewLedgerService creates a new instance of ledger service
+func NewLedgerService(lr domain.LedgerRepository, cr domain.CustomerRepository) domain.LedgerService {
+	return &ledgerService{
+		ledgerRepo:   lr,
+		customerRepo: cr,
+	}
+}
+
+// Post validates and records a journal, filling in its ID and posting time when unset
+func (ls *ledgerService) Post(ctx context.Context, journal *domain.Journal) error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	if err := prepare(journal); err != nil {
+		return err
+	}
+	return ls.ledgerRepo.Create(ctx, journal)
+}
+
+// PostWith checks the journals leave their customers in credit, makes the change
+// with apply and records the journals, all while holding the posting lock.
+// Once the change is made the journals are recorded even if the caller has
+// gone away, so a cancelled request cannot leave them out.
+func (ls *ledgerService) PostWith(ctx context.Context, journals []*domain.Journal, apply func(ctx context.Context) error) error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	balances := make(map[string]*domain.CustomerBalances)
+	for _, journal := range journals {
+		if err := prepare(journal); err != nil {
+			return err
+		}
+		if balances[journal.CustomerID] == nil {
+			existing, err := ls.ledgerRepo.GetByCustomerID(ctx, journal.CustomerID)
+			if err != nil {
+				return err
+			}
+			balances[journal.CustomerID] = domain.NewCustomerBalances(journal.CustomerID, existing, time.Now())
+		}
+		balances[journal.CustomerID].Add(journal)
+	}
+	for _, b := range balances {
+		if err := b.CheckInCredit(); err != nil {
+			return err
+		}
+	}
+
+	if err := apply(ctx); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	for _, journal := range journals {
+		if err := ls.ledgerRepo.Create(ctx, journal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare validates a journal, filling in its ID and posting time when unset
+func prepare(journal *domain.Journal) error {
+	if err := journal.Validate(); err != nil {
+		return err
+	}
+
+	if journal.ID == "" {
+		journal.ID = uuid.New().String()
+	}
+	if journal.PostedAt.IsZero() {
+		journal.PostedAt = time.Now()
+	}
+	return nil
+}
+
+// Balance sums the postings to an account in an asset up to and including at
+func (ls *ledgerService) Balance(ctx context.Context, account domain.LedgerAccount, asset domain.LedgerAsset, at time.Time) (int64, error) {
+	journals, err := ls.ledgerRepo.GetByAccount(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance int64
+	for _, journal := range journals {
+		if journal.PostedAt.After(at) {
+			continue
+		}
+		for _, posting := range journal.Postings {
+			if posting.Account == account && posting.Asset == asset {
+				balance += posting.Amount
+			}
+		}
+	}
+
+	return balance, nil
+}
+
+// GetCustomerBalances gets a customer's cash and units as they stood at the given time
+func (ls *ledgerService) GetCustomerBalances(ctx context.Context, customerID string, at time.Time) (*domain.CustomerBalances, error) {
+	if _, err := ls.customerRepo.GetByID(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	journals, err := ls.ledgerRepo.GetByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewCustomerBalances(customerID, journals, at), nil
+}
+
+// GetCustomerJournals gets every journal posted for a customer, oldest first
+func (ls *ledgerService) GetCustomerJournals(ctx context.Context, customerID string) ([]*domain.Journal, error) {
+	if _, err := ls.customerRepo.GetByID(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	return ls.ledgerRepo.GetByCustomerID(ctx, customerID)
+}
+
+// RecordFee takes a fee from the customer's cash and pays it out of client
+// money. Cash reserved for subscriptions waiting to be dealt cannot be spent on
+// fees, and holding the posting lock stops dealing spending the cash between
+// the check and the fee being recorded.
+func (ls *ledgerService) RecordFee(ctx context.Context, customerID string, amount int64) (*domain.Journal, error) {
+	if amount <= 0 {
+		return nil, errors.New("fee amount must be positive")
+	}
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	balances, err := ls.GetCustomerBalances(ctx, customerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if balances.Available() < amount {
+		return nil, domain.ErrInsufficientCash
+	}
+
+	journal := &domain.Journal{
+		Type:       domain.JournalTypeFee,
+		CustomerID: customerID,
+		Postings:   cashOut(customerID, amount),
+	}
+	if err := prepare(journal); err != nil {
+		return nil, err
+	}
+	if err := ls.ledgerRepo.Create(ctx, journal); err != nil {
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+// RecordDividend pays a dividend from a fund into the customer's cash
+func (ls *ledgerService) RecordDividend(ctx context.Context, customerID, fundID string, amount int64) (*domain.Journal, error) {
+	if amount <= 0 {
+		return nil, errors.New("dividend amount must be positive")
+	}
+
+	balances, err := ls.GetCustomerBalances(ctx, customerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if balances.Units[fundID] <= 0 {
+		return nil, errors.New("customer holds no units in the fund")
+	}
+
+	journal := &domain.Journal{
+		Type:       domain.JournalTypeDividend,
+		CustomerID: customerID,
+		Reference:  fundID,
+		Postings:   cashIn(customerID, amount),
+	}
+	if err := ls.Post(ctx, journal); err != nil {
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+// cashIn posts cash received into client money and owed to the customer
+func cashIn(customerID string, amount int64) []domain.Posting {
+	return []domain.Posting{
+		{Account: domain.ClientMoneyAccount, Asset: domain.LedgerAssetGBP, Amount: amount},
+		{Account: domain.CustomerCashAccount(customerID), Asset: domain.LedgerAssetGBP, Amount: -amount},
+	}
+}
+
+// cashOut posts cash leaving client money from the customer's cash
+func cashOut(customerID string, amount int64) []domain.Posting {
+	return []domain.Posting{
+		{Account: domain.CustomerCashAccount(customerID), Asset: domain.LedgerAssetGBP, Amount: amount},
+		{Account: domain.ClientMoneyAccount, Asset: domain.LedgerAssetGBP, Amount: -amount},
+	}
+}
+
+// unitsIssued posts units the fund issues to the customer
+func unitsIssued(customerID, fundID string, units int64) []domain.Posting {
+	return []domain.Posting{
+		{Account: domain.CustomerUnitsAccount(customerID, fundID), Asset: domain.FundUnits(fundID), Amount: units},
+		{Account: domain.FundAccount(fundID), Asset: domain.FundUnits(fundID), Amount: -units},
+	}
+}
+
+// unitsCancelled posts units the customer sells back to the fund
+func unitsCancelled(customerID, fundID string, units int64) []domain.Posting {
+	return []domain.Posting{
+		{Account: domain.FundAccount(fundID), Asset: domain.FundUnits(fundID), Amount: units},
+		{Account: domain.CustomerUnitsAccount(customerID, fundID), Asset: domain.FundUnits(fundID), Amount: -units},
+	}
+}
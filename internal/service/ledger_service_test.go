@@ -0,0 +1,260 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newLedgerService() domain.LedgerService {
+	return service.NewLedgerService(repository.NewInMemoryLedgerRepository(), repository.NewInMemoryCustomerRepository())
+}
+
+func TestLedgerRefusesUnbalancedJournals(t *testing.T) {
+	ctx := context.Background()
+	ledgerService := newLedgerService()
+
+	err := ledgerService.Post(ctx, &domain.Journal{
+		Type:       domain.JournalTypeFee,
+		CustomerID: "customer-1",
+		Postings: []domain.Posting{
+			{Account: domain.CustomerCashAccount("customer-1"), Asset: domain.LedgerAssetGBP, Amount: 500},
+			{Account: domain.ClientMoneyAccount, Asset: domain.LedgerAssetGBP, Amount: -499},
+		},
+	})
+	assert.ErrorIs(t, err, domain.ErrUnbalancedJournal)
+
+	// Cash and units are different assets, so one cannot balance the other
+	err = ledgerService.Post(ctx, &domain.Journal{
+		Type:       domain.JournalTypeDealing,
+		CustomerID: "customer-1",
+		Postings: []domain.Posting{
+			{Account: domain.CustomerCashAccount("customer-1"), Asset: domain.LedgerAssetGBP, Amount: 500},
+			{Account: domain.FundAccount("fund-1"), Asset: domain.FundUnits("fund-1"), Amount: -500},
+		},
+	})
+	assert.ErrorIs(t, err, domain.ErrUnbalancedJournal)
+
+	journals, _ := ledgerService.GetCustomerJournals(ctx, "customer-1")
+	assert.Empty(t, journals)
+}
+
+func TestInvestmentLifecyclePostsToLedger(t *testing.T) {
+	ctx := context.Background()
+	ledgerService := newLedgerService()
+	investmentService := service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		ledgerService,
+		domain.DefaultAllowanceRules,
+	)
+
+	balance := func(account domain.LedgerAccount, asset domain.LedgerAsset) int64 {
+		b, err := ledgerService.Balance(ctx, account, asset, time.Now())
+		assert.NoError(t, err)
+		return b
+	}
+
+	// £1,000 subscribed, priced at £2.50 and processed
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	assert.NoError(t, err)
+	afterSubscription := time.Now()
+	investment, err = investmentService.PriceInvestment(ctx, investment.ID, 250, investment.Version)
+	assert.NoError(t, err)
+	investment, err = investmentService.ProcessInvestment(ctx, investment.ID, investment.Version)
+	assert.NoError(t, err)
+
+	t.Run("Dealing turns the customer's cash into units", func(t *testing.T) {
+		balances, err := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), balances.Cash)
+		assert.Equal(t, int64(400000), balances.Units["fund-1"])
+		assert.Equal(t, int64(-400000), balance(domain.FundAccount("fund-1"), domain.FundUnits("fund-1")))
+	})
+
+	t.Run("Balances can be derived at an earlier time", func(t *testing.T) {
+		balances, err := ledgerService.GetCustomerBalances(ctx, "customer-1", afterSubscription)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(100000), balances.Cash)
+		assert.Empty(t, balances.Units)
+	})
+
+	t.Run("Dividends and fees move the customer's cash", func(t *testing.T) {
+		_, err := ledgerService.RecordFee(ctx, "customer-1", 1)
+		assert.ErrorIs(t, err, domain.ErrInsufficientCash)
+
+		_, err = ledgerService.RecordDividend(ctx, "customer-1", "fund-1", 2000)
+		assert.NoError(t, err)
+		_, err = ledgerService.RecordFee(ctx, "customer-1", 500)
+		assert.NoError(t, err)
+
+		balances, _ := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+		assert.Equal(t, int64(1500), balances.Cash)
+		assert.Equal(t, int64(1500), balance(domain.ClientMoneyAccount, domain.LedgerAssetGBP))
+
+		_, err = ledgerService.RecordDividend(ctx, "customer-1", "fund-2", 2000)
+		assert.Error(t, err)
+	})
+
+	t.Run("Withdrawing sells the units and pays the cash out", func(t *testing.T) {
+		_, err := investmentService.WithdrawInvestment(ctx, investment.ID, investment.Version)
+		assert.NoError(t, err)
+
+		balances, _ := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+		assert.Equal(t, int64(1500), balances.Cash)
+		assert.Equal(t, int64(0), balances.Units["fund-1"])
+		assert.Equal(t, int64(0), balance(domain.FundAccount("fund-1"), domain.FundUnits("fund-1")))
+
+		journals, _ := ledgerService.GetCustomerJournals(ctx, "customer-1")
+		types := make([]domain.JournalType, 0, len(journals))
+		for _, journal := range journals {
+			assert.NoError(t, journal.Validate())
+			types = append(types, journal.Type)
+		}
+		assert.Equal(t, []domain.JournalType{
+			domain.JournalTypeSubscription,
+			domain.JournalTypeDealing,
+			domain.JournalTypeDividend,
+			domain.JournalTypeFee,
+			domain.JournalTypeDealing,
+			domain.JournalTypeWithdrawal,
+		}, types)
+	})
+}
+
+func TestLedgerRefusesChangesThatOverdraw(t *testing.T) {
+	ctx := context.Background()
+	ledgerService := newLedgerService()
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	eventRepo := repository.NewInMemoryInvestmentEventRepository()
+	customerRepo := repository.NewInMemoryCustomerRepository()
+	fundRepo := repository.NewInMemoryFundRepository()
+	investmentService := service.NewInvestmentService(investmentRepo, eventRepo, customerRepo, fundRepo, ledgerService, domain.DefaultAllowanceRules)
+	switchService := service.NewSwitchService(repository.NewInMemorySwitchRepository(), investmentRepo, eventRepo, customerRepo, fundRepo, ledgerService)
+
+	// £1,000 buys 800 units at £1.25
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	require.NoError(t, err)
+	investment, err = investmentService.PriceInvestment(ctx, investment.ID, 125, investment.Version)
+	require.NoError(t, err)
+	_, err = investmentService.ProcessInvestment(ctx, investment.ID, investment.Version)
+	require.NoError(t, err)
+
+	t.Run("A switch buy leg cannot spend cash its sell leg has not brought in", func(t *testing.T) {
+		sw, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-2", 50000, false)
+		require.NoError(t, err)
+
+		buy, err := investmentService.GetInvestment(ctx, sw.BuyInvestmentID)
+		require.NoError(t, err)
+		buy, err = investmentService.PriceInvestment(ctx, buy.ID, 100, buy.Version)
+		require.NoError(t, err)
+		_, err = investmentService.ProcessInvestment(ctx, buy.ID, buy.Version)
+		assert.ErrorIs(t, err, domain.ErrInsufficientCash)
+	})
+
+	t.Run("A switch sell leg cannot cancel more units than are held", func(t *testing.T) {
+		sw, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-2", 50000, false)
+		require.NoError(t, err)
+
+		// At 50p the £500 sell leg would cancel 1,000 units
+		sell, err := investmentService.GetInvestment(ctx, sw.SellInvestmentID)
+		require.NoError(t, err)
+		sell, err = investmentService.PriceInvestment(ctx, sell.ID, 50, sell.Version)
+		require.NoError(t, err)
+		_, err = investmentService.ProcessInvestment(ctx, sell.ID, sell.Version)
+		assert.ErrorIs(t, err, domain.ErrInsufficientHoldings)
+
+		// Nothing was changed: the leg is still pending with no processed event or journal
+		stored, err := investmentService.GetInvestment(ctx, sell.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.InvestmentStatusPending, stored.Status)
+		assert.Equal(t, sell.Version, stored.Version)
+		events, err := investmentService.GetInvestmentEvents(ctx, sell.ID)
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+
+		balances, err := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(800000), balances.Units["fund-1"])
+	})
+
+	t.Run("Journals that do not balance are refused before the change is made", func(t *testing.T) {
+		applied := false
+		err := ledgerService.PostWith(ctx, []*domain.Journal{{
+			Type:       domain.JournalTypeDealing,
+			CustomerID: "customer-1",
+			Postings:   []domain.Posting{{Account: domain.CustomerCashAccount("customer-1"), Asset: domain.LedgerAssetGBP, Amount: 1}},
+		}}, func(context.Context) error {
+			applied = true
+			return nil
+		})
+		assert.ErrorIs(t, err, domain.ErrUnbalancedJournal)
+		assert.False(t, applied)
+	})
+}
+
+func TestFeesCannotSpendCashOfPendingSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	ledgerService := newLedgerService()
+	investmentService := service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		ledgerService,
+		domain.DefaultAllowanceRules,
+	)
+
+	// £1,000 subscribed and waiting to be dealt
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+	require.NoError(t, err)
+
+	_, err = ledgerService.RecordFee(ctx, "customer-1", 50000)
+	assert.ErrorIs(t, err, domain.ErrInsufficientCash)
+
+	balances, err := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000), balances.Cash)
+	assert.Equal(t, int64(100000), balances.Reserved)
+	assert.Equal(t, int64(0), balances.Available())
+
+	// Cancelling refunds the whole subscription, leaving nothing owed either way
+	_, err = investmentService.CancelInvestment(ctx, investment.ID, investment.Version)
+	require.NoError(t, err)
+
+	balances, err = ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balances.Cash)
+	assert.Equal(t, int64(0), balances.Reserved)
+
+	t.Run("Cash not reserved can still be spent on fees", func(t *testing.T) {
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+		require.NoError(t, err)
+		investment, err = investmentService.PriceInvestment(ctx, investment.ID, 125, investment.Version)
+		require.NoError(t, err)
+		_, err = investmentService.ProcessInvestment(ctx, investment.ID, investment.Version)
+		require.NoError(t, err)
+		_, err = ledgerService.RecordDividend(ctx, "customer-1", "fund-1", 2000)
+		require.NoError(t, err)
+
+		// A second subscription's cash is reserved, the dividend's is not
+		_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 100000, false)
+		require.NoError(t, err)
+		_, err = ledgerService.RecordFee(ctx, "customer-1", 2001)
+		assert.ErrorIs(t, err, domain.ErrInsufficientCash)
+		_, err = ledgerService.RecordFee(ctx, "customer-1", 2000)
+		assert.NoError(t, err)
+
+		balances, err := ledgerService.GetCustomerBalances(ctx, "customer-1", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(100000), balances.Cash)
+		assert.Equal(t, int64(100000), balances.Reserved)
+	})
+}
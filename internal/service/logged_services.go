@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"log/slog"
+)
+
+// The logged services wrap the business services and log the key business
+// events they make, or fail to make, with the IDs needed to follow a customer's
+// money through the logs. The logger adds the request ID from the context.
+// Only IDs, amounts and statuses are logged, never whole entities, so personal
+// data such as names and email addresses stays out of the logs. Reads pass
+// straight through to the wrapped service.
+
+// logEvent logs a business event, or its failure at warning level
+func logEvent(ctx context.Context, logger *slog.Logger, event string, err error, attrs ...any) {
+	if err != nil {
+		logger.WarnContext(ctx, event+" failed", append(attrs, "error", err)...)
+		return
+	}
+	logger.InfoContext(ctx, event, attrs...)
+}
+
+type loggedInvestmentService struct {
+	domain.InvestmentService
+	logger *slog.Logger
+}
+
+// NewLoggedInvestmentService wraps an investment service so its changes are logged
+func NewLoggedInvestmentService(is domain.InvestmentService, logger *slog.Logger) domain.InvestmentService {
+	return &loggedInvestmentService{InvestmentService: is, logger: logger}
+}
+
+// CreateInvestment creates an investment and logs it
+func (s *loggedInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	investment, err := s.InvestmentService.CreateInvestment(ctx, customerID, fundID, amount, riskAcknowledged)
+	if err != nil {
+		logEvent(ctx, s.logger, "investment.created", err, "customer_id", customerID, "fund_id", fundID, "amount", amount)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "investment.created", nil, investmentAttrs(investment)...)
+	return investment, nil
+}
+
+// CancelInvestment cancels an investment and logs it
+func (s *loggedInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.cancelled", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.CancelInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// PriceInvestment prices an investment and logs it
+func (s *loggedInvestmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.priced", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.PriceInvestment(ctx, id, unitPrice, expectedVersion)
+	})
+}
+
+// ProcessInvestment processes an investment and logs it
+func (s *loggedInvestmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.processed", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.ProcessInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// WithdrawInvestment withdraws an investment and logs it
+func (s *loggedInvestmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "investment.withdrawn", id, func() (*domain.Investment, error) {
+		return s.InvestmentService.WithdrawInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing investment and logs it
+func (s *loggedInvestmentService) change(ctx context.Context, event, id string, change func() (*domain.Investment, error)) (*domain.Investment, error) {
+	investment, err := change()
+	if err != nil {
+		logEvent(ctx, s.logger, event, err, "investment_id", id)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, event, nil, investmentAttrs(investment)...)
+	return investment, nil
+}
+
+// investmentAttrs are the attributes an investment is logged with
+func investmentAttrs(investment *domain.Investment) []any {
+	return []any{
+		"investment_id", investment.ID,
+		"customer_id", investment.CustomerID,
+		"fund_id", investment.FundID,
+		"amount", investment.Amount,
+		"status", investment.Status,
+	}
+}
+
+type loggedSwitchService struct {
+	domain.SwitchService
+	logger *slog.Logger
+}
+
+// NewLoggedSwitchService wraps a switch service so its switches are logged
+func NewLoggedSwitchService(ss domain.SwitchService, logger *slog.Logger) domain.SwitchService {
+	return &loggedSwitchService{SwitchService: ss, logger: logger}
+}
+
+// SwitchFunds switches between funds and logs it
+func (s *loggedSwitchService) SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*domain.Switch, error) {
+	sw, err := s.SwitchService.SwitchFunds(ctx, customerID, fromFundID, toFundID, amount, riskAcknowledged)
+	attrs := []any{"customer_id", customerID, "from_fund_id", fromFundID, "to_fund_id", toFundID, "amount", amount}
+	if err != nil {
+		logEvent(ctx, s.logger, "switch.created", err, attrs...)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "switch.created", nil, append(attrs,
+		"switch_id", sw.ID,
+		"sell_investment_id", sw.SellInvestmentID,
+		"buy_investment_id", sw.BuyInvestmentID,
+		"status", sw.Status,
+	)...)
+	return sw, nil
+}
+
+type loggedPlanService struct {
+	domain.PlanService
+	logger *slog.Logger
+}
+
+// NewLoggedPlanService wraps a plan service so its changes are logged. The
+// investments made when plans run are logged by the investment service.
+func NewLoggedPlanService(ps domain.PlanService, logger *slog.Logger) domain.PlanService {
+	return &loggedPlanService{PlanService: ps, logger: logger}
+}
+
+// CreatePlan creates a plan and logs it
+func (s *loggedPlanService) CreatePlan(ctx context.Context, customerID string, allocations []domain.PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*domain.Plan, error) {
+	plan, err := s.PlanService.CreatePlan(ctx, customerID, allocations, dayOfMonth, riskAcknowledged)
+	if err != nil {
+		logEvent(ctx, s.logger, "plan.created", err, "customer_id", customerID, "fund_ids", planFundIDs(allocations))
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "plan.created", nil, planAttrs(plan)...)
+	return plan, nil
+}
+
+// UpdatePlan updates a plan and logs it
+func (s *loggedPlanService) UpdatePlan(ctx context.Context, id string, allocations []domain.PlanAllocation, dayOfMonth int, status domain.PlanStatus, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "plan.updated", id, func() (*domain.Plan, error) {
+		return s.PlanService.UpdatePlan(ctx, id, allocations, dayOfMonth, status, expectedVersion)
+	})
+}
+
+// CancelPlan cancels a plan and logs it
+func (s *loggedPlanService) CancelPlan(ctx context.Context, id string, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "plan.cancelled", id, func() (*domain.Plan, error) {
+		return s.PlanService.CancelPlan(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing plan and logs it
+func (s *loggedPlanService) change(ctx context.Context, event, id string, change func() (*domain.Plan, error)) (*domain.Plan, error) {
+	plan, err := change()
+	if err != nil {
+		logEvent(ctx, s.logger, event, err, "plan_id", id)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, event, nil, planAttrs(plan)...)
+	return plan, nil
+}
+
+// planAttrs are the attributes a plan is logged with
+func planAttrs(plan *domain.Plan) []any {
+	return []any{
+		"plan_id", plan.ID,
+		"customer_id", plan.CustomerID,
+		"fund_ids", planFundIDs(plan.Allocations),
+		"amount", plan.Amount,
+		"status", plan.Status,
+	}
+}
+
+type loggedFundService struct {
+	domain.FundService
+	logger *slog.Logger
+}
+
+// NewLoggedFundService wraps a fund service so changes to the catalogue are logged
+func NewLoggedFundService(fs domain.FundService, logger *slog.Logger) domain.FundService {
+	return &loggedFundService{FundService: fs, logger: logger}
+}
+
+// CreateFund creates a fund and logs it
+func (s *loggedFundService) CreateFund(ctx context.Context, details domain.FundDetails) (*domain.Fund, error) {
+	fund, err := s.FundService.CreateFund(ctx, details)
+	if err != nil {
+		logEvent(ctx, s.logger, "fund.created", err)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "fund.created", nil, "fund_id", fund.ID, "status", fund.Status)
+	return fund, nil
+}
+
+// UpdateFund updates a fund and logs it
+func (s *loggedFundService) UpdateFund(ctx context.Context, id string, details domain.FundDetails, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.updated", id, func() (*domain.Fund, error) {
+		return s.FundService.UpdateFund(ctx, id, details, expectedVersion)
+	})
+}
+
+// SoftCloseFund soft-closes a fund and logs it
+func (s *loggedFundService) SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.soft_closed", id, func() (*domain.Fund, error) {
+		return s.FundService.SoftCloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// SuspendFund suspends a fund and logs it
+func (s *loggedFundService) SuspendFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.suspended", id, func() (*domain.Fund, error) {
+		return s.FundService.SuspendFund(ctx, id, expectedVersion)
+	})
+}
+
+// CloseFund closes a fund and logs it
+func (s *loggedFundService) CloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.closed", id, func() (*domain.Fund, error) {
+		return s.FundService.CloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// ReopenFund reopens a fund and logs it
+func (s *loggedFundService) ReopenFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "fund.reopened", id, func() (*domain.Fund, error) {
+		return s.FundService.ReopenFund(ctx, id, expectedVersion)
+	})
+}
+
+// change makes a change to an existing fund and logs it
+func (s *loggedFundService) change(ctx context.Context, event, id string, change func() (*domain.Fund, error)) (*domain.Fund, error) {
+	fund, err := change()
+	if err != nil {
+		logEvent(ctx, s.logger, event, err, "fund_id", id)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, event, nil, "fund_id", fund.ID, "status", fund.Status)
+	return fund, nil
+}
+
+type loggedCustomerService struct {
+	domain.CustomerService
+	logger *slog.Logger
+}
+
+// NewLoggedCustomerService wraps a customer service so its changes are logged,
+// without the customer's personal details
+func NewLoggedCustomerService(cs domain.CustomerService, logger *slog.Logger) domain.CustomerService {
+	return &loggedCustomerService{CustomerService: cs, logger: logger}
+}
+
+// SubmitRiskQuestionnaire submits a risk questionnaire and logs it
+func (s *loggedCustomerService) SubmitRiskQuestionnaire(ctx context.Context, customerID string, answers map[string]int) (*domain.Customer, error) {
+	customer, err := s.CustomerService.SubmitRiskQuestionnaire(ctx, customerID, answers)
+	logEvent(ctx, s.logger, "customer.risk_profiled", err, "customer_id", customerID)
+	return customer, err
+}
+
+// UpdateCustomerDetails updates a customer's details and logs that they changed
+func (s *loggedCustomerService) UpdateCustomerDetails(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64) (*domain.Customer, error) {
+	customer, err := s.CustomerService.UpdateCustomerDetails(ctx, customerID, details, expectedVersion)
+	logEvent(ctx, s.logger, "customer.updated", err, "customer_id", customerID)
+	return customer, err
+}
+
+type loggedLedgerService struct {
+	domain.LedgerService
+	logger *slog.Logger
+}
+
+// NewLoggedLedgerService wraps a ledger service so fees and dividends are logged.
+// Journals posted for investments are logged as the investment changes.
+func NewLoggedLedgerService(ls domain.LedgerService, logger *slog.Logger) domain.LedgerService {
+	return &loggedLedgerService{LedgerService: ls, logger: logger}
+}
+
+// RecordFee records a fee and logs it
+func (s *loggedLedgerService) RecordFee(ctx context.Context, customerID string, amount int64) (*domain.Journal, error) {
+	journal, err := s.LedgerService.RecordFee(ctx, customerID, amount)
+	s.logJournal(ctx, "ledger.fee_recorded", journal, err, "customer_id", customerID, "amount", amount)
+	return journal, err
+}
+
+// RecordDividend records a dividend and logs it
+func (s *loggedLedgerService) RecordDividend(ctx context.Context, customerID, fundID string, amount int64) (*domain.Journal, error) {
+	journal, err := s.LedgerService.RecordDividend(ctx, customerID, fundID, amount)
+	s.logJournal(ctx, "ledger.dividend_recorded", journal, err, "customer_id", customerID, "fund_id", fundID, "amount", amount)
+	return journal, err
+}
+
+// logJournal logs a journal recorded for an event, with its ID once it is posted
+func (s *loggedLedgerService) logJournal(ctx context.Context, event string, journal *domain.Journal, err error, attrs ...any) {
+	if err == nil {
+		attrs = append(attrs, "journal_id", journal.ID)
+	}
+	logEvent(ctx, s.logger, event, err, attrs...)
+}
+
+type loggedApprovalService struct {
+	domain.ApprovalService
+	logger *slog.Logger
+}
+
+// NewLoggedApprovalService wraps an approval service so requests and decisions are logged
+func NewLoggedApprovalService(as domain.ApprovalService, logger *slog.Logger) domain.ApprovalService {
+	return &loggedApprovalService{ApprovalService: as, logger: logger}
+}
+
+// RequestInvestmentCancellation requests a cancellation and logs it
+func (s *loggedApprovalService) RequestInvestmentCancellation(ctx context.Context, investmentID string, expectedVersion int64, reason string) (*domain.Approval, error) {
+	return s.change(ctx, "approval.requested", "", func() (*domain.Approval, error) {
+		return s.ApprovalService.RequestInvestmentCancellation(ctx, investmentID, expectedVersion, reason)
+	}, "investment_id", investmentID)
+}
+
+// RequestCustomerUpdate requests a change of customer details and logs it
+func (s *loggedApprovalService) RequestCustomerUpdate(ctx context.Context, customerID string, details domain.CustomerDetails, expectedVersion int64, reason string) (*domain.Approval, error) {
+	return s.change(ctx, "approval.requested", "", func() (*domain.Approval, error) {
+		return s.ApprovalService.RequestCustomerUpdate(ctx, customerID, details, expectedVersion, reason)
+	}, "customer_id", customerID)
+}
+
+// Approve approves a request and logs the decision
+func (s *loggedApprovalService) Approve(ctx context.Context, id string) (*domain.Approval, error) {
+	return s.change(ctx, "approval.approved", id, func() (*domain.Approval, error) {
+		return s.ApprovalService.Approve(ctx, id)
+	})
+}
+
+// Reject rejects a request and logs the decision
+func (s *loggedApprovalService) Reject(ctx context.Context, id, note string) (*domain.Approval, error) {
+	return s.change(ctx, "approval.rejected", id, func() (*domain.Approval, error) {
+		return s.ApprovalService.Reject(ctx, id, note)
+	})
+}
+
+// change requests or decides an approval and logs it, with the attributes it
+// was asked for if it fails
+func (s *loggedApprovalService) change(ctx context.Context, event, id string, change func() (*domain.Approval, error), attrs ...any) (*domain.Approval, error) {
+	approval, err := change()
+	if err != nil {
+		if id != "" {
+			attrs = append(attrs, "approval_id", id)
+		}
+		logEvent(ctx, s.logger, event, err, attrs...)
+		return nil, err
+	}
+	attrs = []any{
+		"approval_id", approval.ID,
+		"action", approval.Action,
+		"entity_id", approval.EntityID,
+		"customer_id", approval.CustomerID,
+		"status", approval.Status,
+	}
+	if approval.Status == domain.ApprovalStatusFailed {
+		// Approved, but the change could not be made
+		attrs = append(attrs, "error", approval.Error)
+		s.logger.WarnContext(ctx, event, attrs...)
+		return approval, nil
+	}
+	logEvent(ctx, s.logger, event, nil, attrs...)
+	return approval, nil
+}
+
+type loggedAPIKeyService struct {
+	domain.APIKeyService
+	logger *slog.Logger
+}
+
+// NewLoggedAPIKeyService wraps an API key service so keys being issued, rotated
+// and revoked are logged, without their secrets
+func NewLoggedAPIKeyService(ks domain.APIKeyService, logger *slog.Logger) domain.APIKeyService {
+	return &loggedAPIKeyService{APIKeyService: ks, logger: logger}
+}
+
+// IssueAPIKey issues a key and logs it
+func (s *loggedAPIKeyService) IssueAPIKey(ctx context.Context, partnerID string, settings domain.APIKeySettings) (*domain.IssuedAPIKey, error) {
+	issued, err := s.APIKeyService.IssueAPIKey(ctx, partnerID, settings)
+	if err != nil {
+		logEvent(ctx, s.logger, "api_key.issued", err, "partner_id", partnerID)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "api_key.issued", nil, "partner_id", partnerID, "api_key_id", issued.ID)
+	return issued, nil
+}
+
+// RotateAPIKey rotates a key and logs it
+func (s *loggedAPIKeyService) RotateAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.IssuedAPIKey, error) {
+	issued, err := s.APIKeyService.RotateAPIKey(ctx, id, expectedVersion)
+	if err != nil {
+		logEvent(ctx, s.logger, "api_key.rotated", err, "api_key_id", id)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "api_key.rotated", nil, "partner_id", issued.PartnerID, "api_key_id", id, "replaced_by", issued.ID)
+	return issued, nil
+}
+
+// RevokeAPIKey revokes a key and logs it
+func (s *loggedAPIKeyService) RevokeAPIKey(ctx context.Context, id string, expectedVersion int64) (*domain.APIKey, error) {
+	key, err := s.APIKeyService.RevokeAPIKey(ctx, id, expectedVersion)
+	if err != nil {
+		logEvent(ctx, s.logger, "api_key.revoked", err, "api_key_id", id)
+		return nil, err
+	}
+	logEvent(ctx, s.logger, "api_key.revoked", nil, "partner_id", key.PartnerID, "api_key_id", id)
+	return key, nil
+}
@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/logging"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logRecords decodes the JSON log records written to buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggedServices(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+
+	t.Run("Investment events carry the request, customer and investment IDs", func(t *testing.T) {
+		buf.Reset()
+		investmentService := service.NewLoggedInvestmentService(service.NewInvestmentService(
+			repository.NewInMemoryInvestmentRepository(),
+			repository.NewInMemoryInvestmentEventRepository(),
+			repository.NewInMemoryCustomerRepository(),
+			repository.NewInMemoryFundRepository(),
+			newLedgerService(),
+			domain.DefaultAllowanceRules,
+		), logger)
+
+		investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 10000, false)
+		require.NoError(t, err)
+		_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 2000001, false)
+		assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+
+		records := logRecords(t, &buf)
+		require.Len(t, records, 2)
+		assert.Equal(t, "investment.created", records[0]["msg"])
+		assert.Equal(t, "INFO", records[0]["level"])
+		assert.Equal(t, "req-1", records[0]["request_id"])
+		assert.Equal(t, "customer-1", records[0]["customer_id"])
+		assert.Equal(t, investment.ID, records[0]["investment_id"])
+
+		assert.Equal(t, "investment.created failed", records[1]["msg"])
+		assert.Equal(t, "WARN", records[1]["level"])
+		assert.Equal(t, "customer-1", records[1]["customer_id"])
+		assert.NotEmpty(t, records[1]["error"])
+	})
+
+	t.Run("Customer details are never logged", func(t *testing.T) {
+		buf.Reset()
+		customerService := service.NewLoggedCustomerService(service.NewCustomerService(repository.NewInMemoryCustomerRepository()), logger)
+
+		_, err := customerService.UpdateCustomerDetails(ctx, "customer-1", domain.CustomerDetails{Name: "Jane Smith", Email: "jane.smith@example.com"}, 0)
+		require.NoError(t, err)
+		_, err = customerService.UpdateCustomerDetails(ctx, "customer-1", domain.CustomerDetails{Name: "Jane Smith", Email: "Jane <jane@example.com>"}, 0)
+		assert.Error(t, err)
+
+		assert.Len(t, logRecords(t, &buf), 2)
+		assert.NotContains(t, buf.String(), "Jane")
+		assert.NotContains(t, buf.String(), "example.com")
+	})
+}
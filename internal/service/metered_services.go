@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+)
+
+// rejectionReasons label the subscriptions refused for a business rule in the
+// isa_investments_rejected_total metric. Anything else is counted as "other".
+var rejectionReasons = []struct {
+	err    error
+	reason string
+}{
+	{domain.ErrAllowanceExceeded, "allowance_exceeded"},
+	{domain.ErrFundNotOpen, "fund_not_open"},
+	{domain.ErrFundSuspended, "fund_suspended"},
+	{domain.ErrRiskNotAcknowledged, "risk_not_acknowledged"},
+}
+
+// rejectionReason is the reason a subscription was refused with err
+func rejectionReason(err error) string {
+	for _, r := range rejectionReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "other"
+}
+
+type meteredInvestmentService struct {
+	domain.InvestmentService
+	metrics *metrics.Metrics
+}
+
+// NewMeteredInvestmentService wraps an investment service so the subscriptions
+// it creates and refuses are counted
+func NewMeteredInvestmentService(is domain.InvestmentService, m *metrics.Metrics) domain.InvestmentService {
+	return &meteredInvestmentService{InvestmentService: is, metrics: m}
+}
+
+// CreateInvestment creates an investment and counts it, or the reason it was refused
+func (s *meteredInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	investment, err := s.InvestmentService.CreateInvestment(ctx, customerID, fundID, amount, riskAcknowledged)
+	if err != nil {
+		s.metrics.InvestmentRejected(rejectionReason(err))
+		return nil, err
+	}
+	s.metrics.InvestmentCreated(investment.FundID, investment.Amount)
+	return investment, nil
+}
@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/metrics"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// scrape returns the metrics in the Prometheus exposition format
+func scrape(m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestMeteredInvestmentService(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.New()
+	investmentService := service.NewMeteredInvestmentService(service.NewInvestmentService(
+		repository.NewMeteredInvestmentRepository(repository.NewInMemoryInvestmentRepository(), m),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	), m)
+
+	_, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1500000, false)
+	assert.NoError(t, err)
+	_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 250000, false)
+	assert.NoError(t, err)
+	_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 500000, false)
+	assert.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+	_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", -1, false)
+	assert.Error(t, err)
+
+	exposition := scrape(m)
+	assert.Contains(t, exposition, `isa_investments_created_total{fund_id="fund-1"} 2`)
+	assert.Contains(t, exposition, `isa_subscribed_pence_total{fund_id="fund-1"} 1.75e+06`)
+	assert.Contains(t, exposition, `isa_investments_rejected_total{reason="allowance_exceeded"} 1`)
+	assert.Contains(t, exposition, `isa_investments_rejected_total{reason="other"} 1`)
+	assert.Contains(t, exposition, `repository_operation_duration_seconds_count{operation="create",repository="investment"} 2`)
+}
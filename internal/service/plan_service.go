@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"slices"
+	"time"
+)
+
+// maxPlanDayOfMonth keeps collection dates valid in every month
+const maxPlanDayOfMonth = 28
+
+// maxPlanSaveAttempts limits how often the outcome of a collection is saved
+// again when the plan keeps being changed at the same time
+const maxPlanSaveAttempts = 3
+
+type planService struct {
+	planRepo          domain.PlanRepository
+	customerRepo      domain.CustomerRepository
+	fundRepo          domain.FundRepository
+	investmentService domain.InvestmentService
+	rules             domain.AllowanceRules
+}
+
+// NewPlanService creates a new instance of plan service
+func NewPlanService(
+	pr domain.PlanRepository,
+	cr domain.CustomerRepository,
+	fr domain.FundRepository,
+	is domain.InvestmentService,
+	rules domain.AllowanceRules,
+) domain.PlanService {
+	return &planService{
+		planRepo:          pr,
+		customerRepo:      cr,
+		fundRepo:          fr,
+		investmentService: is,
+		rules:             rules,
+	}
+}
+
+// CreatePlan creates a new regular contribution plan investing in each fund of
+// its allocations
+func (ps *planService) CreatePlan(ctx context.Context, customerID string, allocations []domain.PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*domain.Plan, error) {
+	// Check if customer and funds exist
+	customer, err := ps.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	riskWarning := false
+	for _, allocation := range allocations {
+		fund, err := ps.fundRepo.GetByID(ctx, allocation.FundID)
+		if err != nil {
+			return nil, err
+		}
+		if fund.Status != domain.FundStatusOpen {
+			return nil, domain.ErrFundNotOpen
+		}
+		riskWarning = riskWarning || exceedsRiskTolerance(customer, fund)
+	}
+
+	// The acknowledgement given when setting up the plan covers every collection
+	if riskWarning && !riskAcknowledged {
+		return nil, domain.ErrRiskNotAcknowledged
+	}
+
+	amount, err := validatePlan(allocations, dayOfMonth, ps.rules)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	plan := &domain.Plan{
+		ID:               uuid.New().String(),
+		CustomerID:       customerID,
+		Allocations:      slices.Clone(allocations),
+		Amount:           amount,
+		DayOfMonth:       dayOfMonth,
+		Status:           domain.PlanStatusActive,
+		NextRunAt:        nextPlanRun(dayOfMonth, now),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		RiskAcknowledged: riskWarning,
+	}
+
+	if err := ps.planRepo.Create(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// GetPlan gets a plan by ID
+func (ps *planService) GetPlan(ctx context.Context, id string) (*domain.Plan, error) {
+	return ps.planRepo.GetByID(ctx, id)
+}
+
+// GetCustomerPlans gets all plans for a customer
+func (ps *planService) GetCustomerPlans(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	return ps.planRepo.GetByCustomerID(ctx, customerID)
+}
+
+// UpdatePlan changes the amounts invested in a plan's funds, its collection day
+// or its status. Resuming a paused plan schedules its next collection from today.
+func (ps *planService) UpdatePlan(ctx context.Context, id string, allocations []domain.PlanAllocation, dayOfMonth int, status domain.PlanStatus, expectedVersion int64) (*domain.Plan, error) {
+	plan, err := ps.planRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(plan.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+	if plan.Status == domain.PlanStatusCancelled {
+		return nil, errors.New("plan has been cancelled")
+	}
+	if status != domain.PlanStatusActive && status != domain.PlanStatusPaused {
+		return nil, errors.New("plan status must be active or paused")
+	}
+	amount, err := validatePlan(allocations, dayOfMonth, ps.rules)
+	if err != nil {
+		return nil, err
+	}
+	if !sameFunds(plan.Allocations, allocations) {
+		return nil, errors.New("plan funds cannot be changed; cancel the plan and set up a new one")
+	}
+
+	now := time.Now()
+	if dayOfMonth != plan.DayOfMonth || (status == domain.PlanStatusActive && plan.Status == domain.PlanStatusPaused) {
+		plan.NextRunAt = nextPlanRun(dayOfMonth, now)
+	}
+	if status == domain.PlanStatusActive {
+		plan.PauseReason = ""
+	}
+
+	plan.Allocations = slices.Clone(allocations)
+	plan.Amount = amount
+	plan.DayOfMonth = dayOfMonth
+	plan.Status = status
+	plan.UpdatedAt = now
+
+	if err := ps.planRepo.Update(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// CancelPlan stops all future collections for a plan
+func (ps *planService) CancelPlan(ctx context.Context, id string, expectedVersion int64) (*domain.Plan, error) {
+	plan, err := ps.planRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(plan.Version, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	plan.Status = domain.PlanStatusCancelled
+	plan.UpdatedAt = time.Now()
+
+	if err := ps.planRepo.Update(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// RunDuePlans creates an investment in each fund of every active plan due at
+// the given time, collecting each plan in full or not at all. Plans that would
+// take the customer over their ISA allowance, that have become unsuitable for
+// the customer's risk tolerance or with a fund that has closed are paused.
+// Collections into a suspended fund stay due and are retried once dealing resumes.
+// A plan changed since it was found due is left for the next run to see afresh.
+// When ctx is cancelled the run stops, leaving the plans not yet collected due.
+func (ps *planService) RunDuePlans(ctx context.Context, at time.Time) error {
+	plans, err := ps.planRepo.GetDue(ctx, at)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, due := range plans {
+		// The plan may have been cancelled, paused or changed since the due
+		// plans were read, so only collect it as it was when found due
+		plan, err := ps.planRepo.GetByID(ctx, due.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				errs = append(errs, ctx.Err())
+				break
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if plan.Status != domain.PlanStatusActive || plan.Version != due.Version {
+			continue
+		}
+
+		dueAt := plan.NextRunAt
+		investmentIDs, err := ps.collect(ctx, plan)
+		if err != nil && ctx.Err() != nil {
+			// Stopped: leave this and the remaining plans due for the next run
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		// collected moves the plan on to its next collection after this run,
+		// unless it has already been rescheduled past it. Collections that also
+		// fell due while the scheduler was not running are counted as missed
+		// rather than made now.
+		collected := func(plan *domain.Plan) {
+			if plan.NextRunAt.After(at) {
+				return
+			}
+			plan.NextRunAt = dueAt.AddDate(0, 1, 0)
+			for !plan.NextRunAt.After(at) {
+				plan.NextRunAt = plan.NextRunAt.AddDate(0, 1, 0)
+				plan.MissedRuns++
+			}
+		}
+		var outcome func(plan *domain.Plan)
+		switch {
+		case errors.Is(err, domain.ErrFundSuspended):
+			// Queue the collection until dealing resumes by leaving it due
+			outcome = func(plan *domain.Plan) {
+				plan.LastError = err.Error()
+			}
+		case errors.Is(err, domain.ErrAllowanceExceeded), errors.Is(err, domain.ErrRiskNotAcknowledged), errors.Is(err, domain.ErrFundNotOpen):
+			outcome = func(plan *domain.Plan) {
+				if plan.Status == domain.PlanStatusActive {
+					plan.Status = domain.PlanStatusPaused
+					plan.PauseReason = err.Error()
+				}
+			}
+		case err != nil:
+			// Skip this collection rather than retrying it on every tick
+			outcome = func(plan *domain.Plan) {
+				plan.LastError = err.Error()
+				collected(plan)
+			}
+		default:
+			outcome = func(plan *domain.Plan) {
+				runAt := at
+				plan.LastRunAt = &runAt
+				plan.LastInvestmentIDs = investmentIDs
+				plan.LastError = ""
+				collected(plan)
+			}
+		}
+
+		// The outcome of the collection is saved even if we are stopping, so a
+		// collection that was made is not made again on the next run
+		if err := ps.saveOutcome(context.WithoutCancel(ctx), plan, outcome); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// collect invests each of a plan's allocations, returning the IDs of the
+// investments made. When an allocation cannot be invested, those already made
+// for the collection are cancelled, so a collection is never made in part.
+func (ps *planService) collect(ctx context.Context, plan *domain.Plan) ([]string, error) {
+	investments := make([]*domain.Investment, 0, len(plan.Allocations))
+	for _, allocation := range plan.Allocations {
+		investment, err := ps.investmentService.CreateInvestment(ctx, plan.CustomerID, allocation.FundID, allocation.Amount, plan.RiskAcknowledged)
+		if err != nil {
+			return nil, errors.Join(err, ps.cancelCollected(context.WithoutCancel(ctx), investments))
+		}
+		investments = append(investments, investment)
+	}
+
+	ids := make([]string, 0, len(investments))
+	for _, investment := range investments {
+		ids = append(ids, investment.ID)
+	}
+	return ids, nil
+}
+
+// cancelCollected cancels the investments made for a collection that could not be completed
+func (ps *planService) cancelCollected(ctx context.Context, investments []*domain.Investment) error {
+	var errs []error
+	for _, investment := range investments {
+		if _, err := ps.investmentService.CancelInvestment(ctx, investment.ID, investment.Version); err != nil {
+			errs = append(errs, fmt.Errorf("cancelling investment %s of a part collection: %w", investment.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// saveOutcome applies the outcome of a collection to the plan and saves it. If
+// the plan was changed while it was being collected, such as by its customer
+// updating it, the plan is read again and the outcome applied to the new
+// version, so the change is kept and the collection is not made again.
+func (ps *planService) saveOutcome(ctx context.Context, plan *domain.Plan, outcome func(plan *domain.Plan)) error {
+	for attempt := 1; ; attempt++ {
+		outcome(plan)
+		plan.UpdatedAt = time.Now()
+
+		err := ps.planRepo.Update(ctx, plan)
+		if !errors.Is(err, domain.ErrConflict) || attempt == maxPlanSaveAttempts {
+			return err
+		}
+		if plan, err = ps.planRepo.GetByID(ctx, plan.ID); err != nil {
+			return err
+		}
+	}
+}
+
+// validatePlan checks the allocations and collection day of a plan, returning
+// the total of the allocations
+func validatePlan(allocations []domain.PlanAllocation, dayOfMonth int, rules domain.AllowanceRules) (int64, error) {
+	if len(allocations) == 0 {
+		return 0, errors.New("plan must invest in at least one fund")
+	}
+
+	var total int64
+	funds := make(map[string]bool, len(allocations))
+	for _, allocation := range allocations {
+		if allocation.Amount <= 0 {
+			return 0, errors.New("plan amount must be positive")
+		}
+		if funds[allocation.FundID] {
+			return 0, fmt.Errorf("plan invests in fund %s more than once", allocation.FundID)
+		}
+		funds[allocation.FundID] = true
+		if allocation.Amount > rules.AnnualLimit-total {
+			return 0, rules.Exceeded()
+		}
+		total += allocation.Amount
+	}
+
+	if dayOfMonth < 1 || dayOfMonth > maxPlanDayOfMonth {
+		return 0, errors.New("plan day of month must be between 1 and 28")
+	}
+	return total, nil
+}
+
+// planFundIDs lists the funds allocations invest in
+func planFundIDs(allocations []domain.PlanAllocation) []string {
+	ids := make([]string, 0, len(allocations))
+	for _, allocation := range allocations {
+		ids = append(ids, allocation.FundID)
+	}
+	return ids
+}
+
+// sameFunds reports whether two sets of allocations, each naming a fund at
+// most once, invest in the same funds
+func sameFunds(a, b []domain.PlanAllocation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, allocation := range a {
+		if !slices.ContainsFunc(b, func(other domain.PlanAllocation) bool { return other.FundID == allocation.FundID }) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextPlanRun returns the next collection date on dayOfMonth, today included
+func nextPlanRun(dayOfMonth int, from time.Time) time.Time {
+	next := time.Date(from.Year(), from.Month(), dayOfMonth, 0, 0, 0, 0, from.Location())
+	today := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	if next.Before(today) {
+		next = next.AddDate(0, 1, 0)
+	}
+	return next
+}
@@ -0,0 +1,338 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+// Mock InvestmentService
+type mockInvestmentService struct {
+	mock.Mock
+}
+
+func (m *mockInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	args := m.Called(customerID, fundID, amount, riskAcknowledged)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) GetInvestment(ctx context.Context, id string) (*domain.Investment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) GetCustomerInvestments(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	args := m.Called(customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) ListCustomerInvestments(ctx context.Context, query domain.InvestmentQuery) (*domain.InvestmentPage, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.InvestmentPage), args.Error(1)
+}
+
+func (m *mockInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	args := m.Called(id, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	args := m.Called(id, unitPrice, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	args := m.Called(id, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	args := m.Called(id, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Investment), args.Error(1)
+}
+
+func (m *mockInvestmentService) GetInvestmentEvents(ctx context.Context, id string) ([]*domain.InvestmentEvent, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.InvestmentEvent), args.Error(1)
+}
+
+// changingPlanRepository calls changed once the due plans have been read, as a
+// concurrent change to them would
+type changingPlanRepository struct {
+	domain.PlanRepository
+	changed func()
+}
+
+func (r *changingPlanRepository) GetDue(ctx context.Context, at time.Time) ([]*domain.Plan, error) {
+	plans, err := r.PlanRepository.GetDue(ctx, at)
+	r.changed()
+	return plans, err
+}
+
+func TestRunDuePlans(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(mockCustomerRepository)
+	mockFundRepo := new(mockFundRepository)
+	mockCustomerRepo.On("GetByID", mock.Anything).Return(&domain.Customer{ID: "customer-1"}, nil)
+	mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", Status: domain.FundStatusOpen}, nil)
+	mockFundRepo.On("GetByID", "fund-2").Return(&domain.Fund{ID: "fund-2", Status: domain.FundStatusOpen}, nil)
+
+	newPlan := func(planService domain.PlanService, customerID string) *domain.Plan {
+		plan, err := planService.CreatePlan(ctx, customerID, []domain.PlanAllocation{{FundID: "fund-1", Amount: 25000}}, 1, false) // £250 on the 1st
+		assert.NoError(t, err)
+		return plan
+	}
+
+	t.Run("Due plan creates an investment and moves to next month", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		dueAt := plan.NextRunAt
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Return(&domain.Investment{ID: "inv-1"}, nil)
+
+		assert.NoError(t, planService.RunDuePlans(ctx, dueAt))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusActive, plan.Status)
+		assert.Equal(t, []string{"inv-1"}, plan.LastInvestmentIDs)
+		assert.Equal(t, dueAt.AddDate(0, 1, 0), plan.NextRunAt)
+	})
+
+	t.Run("Plan overdue after downtime is collected once and moves to a future date", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		dueAt := plan.NextRunAt
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Return(&domain.Investment{ID: "inv-1"}, nil)
+
+		// The scheduler comes back a few days after the fourth collection fell due
+		at := dueAt.AddDate(0, 3, 4)
+		assert.NoError(t, planService.RunDuePlans(ctx, at))
+		assert.NoError(t, planService.RunDuePlans(ctx, at))
+		mockInvestService.AssertNumberOfCalls(t, "CreateInvestment", 1)
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, dueAt.AddDate(0, 4, 0), plan.NextRunAt)
+		assert.Equal(t, 3, plan.MissedRuns)
+	})
+
+	t.Run("Plan changed while it is collected is not collected again", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		dueAt := plan.NextRunAt
+
+		// The customer changes the amount while the investment is being made
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Run(func(mock.Arguments) {
+				_, err := planService.UpdatePlan(ctx, plan.ID, []domain.PlanAllocation{{FundID: "fund-1", Amount: 30000}}, plan.DayOfMonth, domain.PlanStatusActive, plan.Version)
+				assert.NoError(t, err)
+			}).
+			Return(&domain.Investment{ID: "inv-1"}, nil).Once()
+
+		assert.NoError(t, planService.RunDuePlans(ctx, dueAt))
+		assert.NoError(t, planService.RunDuePlans(ctx, dueAt))
+		mockInvestService.AssertNumberOfCalls(t, "CreateInvestment", 1)
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, int64(30000), plan.Amount)
+		assert.Equal(t, []string{"inv-1"}, plan.LastInvestmentIDs)
+		assert.Equal(t, dueAt.AddDate(0, 1, 0), plan.NextRunAt)
+	})
+
+	t.Run("Plan cancelled after it is found due is not collected", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planRepo := &changingPlanRepository{PlanRepository: repository.NewInMemoryPlanRepository()}
+		planService := service.NewPlanService(planRepo, mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		planRepo.changed = func() {
+			_, err := planService.CancelPlan(ctx, plan.ID, plan.Version)
+			assert.NoError(t, err)
+		}
+
+		assert.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt))
+		mockInvestService.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusCancelled, plan.Status)
+		assert.Empty(t, plan.LastInvestmentIDs)
+	})
+
+	t.Run("Plan that would exceed allowance is paused", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Return(nil, domain.ErrAllowanceExceeded)
+
+		assert.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusPaused, plan.Status)
+		assert.NotEmpty(t, plan.PauseReason)
+	})
+
+	t.Run("Plans not yet due are left alone", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+
+		assert.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt.Add(-time.Second)))
+		mockInvestService.AssertNotCalled(t, "CreateInvestment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Collections into a suspended fund stay queued", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		dueAt := plan.NextRunAt
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Return(nil, domain.ErrFundSuspended)
+
+		assert.NoError(t, planService.RunDuePlans(ctx, dueAt))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusActive, plan.Status)
+		assert.Equal(t, dueAt, plan.NextRunAt)
+	})
+
+	t.Run("Other failures skip the collection", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := newPlan(planService, "customer-1")
+		dueAt := plan.NextRunAt
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(25000), false).
+			Return(nil, errors.New("fund not found"))
+
+		assert.NoError(t, planService.RunDuePlans(ctx, dueAt))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusActive, plan.Status)
+		assert.Equal(t, "fund not found", plan.LastError)
+		assert.Equal(t, dueAt.AddDate(0, 1, 0), plan.NextRunAt)
+	})
+	splitPlan := func(planService domain.PlanService) *domain.Plan {
+		plan, err := planService.CreatePlan(ctx, "customer-1", []domain.PlanAllocation{
+			{FundID: "fund-1", Amount: 15000},
+			{FundID: "fund-2", Amount: 10000},
+		}, 1, false)
+		assert.NoError(t, err)
+		return plan
+	}
+
+	t.Run("Plan split across funds invests in each", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := splitPlan(planService)
+		assert.Equal(t, int64(25000), plan.Amount)
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(15000), false).
+			Return(&domain.Investment{ID: "inv-1"}, nil)
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-2", int64(10000), false).
+			Return(&domain.Investment{ID: "inv-2"}, nil)
+
+		assert.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, []string{"inv-1", "inv-2"}, plan.LastInvestmentIDs)
+	})
+
+	t.Run("Plan split across funds is not collected in part", func(t *testing.T) {
+		mockInvestService := new(mockInvestmentService)
+		planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, mockInvestService, domain.DefaultAllowanceRules)
+		plan := splitPlan(planService)
+
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-1", int64(15000), false).
+			Return(&domain.Investment{ID: "inv-1", Version: 1}, nil)
+		mockInvestService.On("CreateInvestment", "customer-1", "fund-2", int64(10000), false).
+			Return(nil, domain.ErrAllowanceExceeded)
+		mockInvestService.On("CancelInvestment", "inv-1", int64(1)).
+			Return(&domain.Investment{ID: "inv-1", Status: domain.InvestmentStatusCancelled}, nil)
+
+		assert.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt))
+		mockInvestService.AssertCalled(t, "CancelInvestment", "inv-1", int64(1))
+
+		plan, _ = planService.GetPlan(ctx, plan.ID)
+		assert.Equal(t, domain.PlanStatusPaused, plan.Status)
+		assert.Empty(t, plan.LastInvestmentIDs)
+	})
+}
+
+func TestPlanAllocations(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(mockCustomerRepository)
+	mockFundRepo := new(mockFundRepository)
+	mockCustomerRepo.On("GetByID", mock.Anything).Return(&domain.Customer{ID: "customer-1"}, nil)
+	mockFundRepo.On("GetByID", mock.Anything).Return(&domain.Fund{Status: domain.FundStatusOpen}, nil)
+	planService := service.NewPlanService(repository.NewInMemoryPlanRepository(), mockCustomerRepo, mockFundRepo, new(mockInvestmentService), domain.DefaultAllowanceRules)
+
+	t.Run("Invalid allocations are refused", func(t *testing.T) {
+		for name, allocations := range map[string][]domain.PlanAllocation{
+			"none":            nil,
+			"zero amount":     {{FundID: "fund-1", Amount: 0}},
+			"same fund twice": {{FundID: "fund-1", Amount: 100}, {FundID: "fund-1", Amount: 100}},
+			"over allowance":  {{FundID: "fund-1", Amount: 1500000}, {FundID: "fund-2", Amount: 600000}},
+		} {
+			_, err := planService.CreatePlan(ctx, "customer-1", allocations, 1, false)
+			assert.Error(t, err, name)
+		}
+	})
+
+	plan, err := planService.CreatePlan(ctx, "customer-1", []domain.PlanAllocation{
+		{FundID: "fund-1", Amount: 15000},
+		{FundID: "fund-2", Amount: 10000},
+	}, 1, false)
+	assert.NoError(t, err)
+
+	t.Run("Amounts can be changed but funds cannot", func(t *testing.T) {
+		_, err := planService.UpdatePlan(ctx, plan.ID, []domain.PlanAllocation{{FundID: "fund-1", Amount: 25000}}, 1, domain.PlanStatusActive, plan.Version)
+		assert.ErrorContains(t, err, "plan funds cannot be changed")
+
+		updated, err := planService.UpdatePlan(ctx, plan.ID, []domain.PlanAllocation{
+			{FundID: "fund-2", Amount: 20000},
+			{FundID: "fund-1", Amount: 5000},
+		}, 1, domain.PlanStatusActive, plan.Version)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(25000), updated.Amount)
+	})
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"math"
+	"sort"
+)
+
+// defaultHorizonYears is assumed when the customer does not give an investment horizon
+const defaultHorizonYears = 5
+
+// modelAllocations splits a portfolio across risk levels for each target risk
+var modelAllocations = map[domain.RiskLevel]map[domain.RiskLevel]float64{
+	domain.RiskLevelLow:    {domain.RiskLevelLow: 100},
+	domain.RiskLevelMedium: {domain.RiskLevelLow: 40, domain.RiskLevelMedium: 60},
+	domain.RiskLevelHigh:   {domain.RiskLevelLow: 10, domain.RiskLevelMedium: 30, domain.RiskLevelHigh: 60},
+}
+
+type recommendationService struct {
+	customerRepo   domain.CustomerRepository
+	investmentRepo domain.InvestmentRepository
+	fundService    domain.FundService
+}
+
+// NewRecommendationService creates a new instance of recommendation service
+func NewRecommendationService(
+	cr domain.CustomerRepository,
+	ir domain.InvestmentRepository,
+	fs domain.FundService,
+) domain.RecommendationService {
+	return &recommendationService{
+		customerRepo:   cr,
+		investmentRepo: ir,
+		fundService:    fs,
+	}
+}
+
+// GetRecommendations ranks the funds suitable for a customer and suggests how to
+// split money between them, based on their risk tolerance, investment horizon and
+// what they already hold
+func (rs *recommendationService) GetRecommendations(ctx context.Context, customerID string, horizonYears int) (*domain.Recommendations, error) {
+	customer, err := rs.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if customer.RiskTolerance == "" {
+		return nil, domain.ErrRiskProfileRequired
+	}
+
+	if horizonYears < 0 {
+		return nil, errors.New("investment horizon cannot be negative")
+	}
+	if horizonYears == 0 {
+		horizonYears = defaultHorizonYears
+	}
+	target := targetRisk(customer.RiskTolerance, horizonYears)
+
+	funds, _, err := rs.fundService.ListFunds(ctx, domain.FundFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	investments, err := rs.investmentRepo.GetByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	holdings := holdingsByFund(investments)
+	var totalHeld int64
+	for _, amount := range holdings {
+		totalHeld += amount
+	}
+
+	var recommendations []domain.FundRecommendation
+	for _, fund := range funds {
+		// Never suggest funds riskier than the customer's horizon allows
+		if fund.RiskLevel.Exceeds(target) {
+			continue
+		}
+
+		rec := domain.FundRecommendation{
+			FundID:         fund.ID,
+			FundName:       fund.Name,
+			RiskLevel:      fund.RiskLevel,
+			Score:          100 - 25*(target.Rank()-fund.RiskLevel.Rank()),
+			CurrentHolding: holdings[fund.ID],
+		}
+
+		if fund.RiskLevel == target {
+			rec.Reasons = append(rec.Reasons, fmt.Sprintf("Its %s risk level matches your target risk", fund.RiskLevel))
+		} else {
+			rec.Reasons = append(rec.Reasons, fmt.Sprintf("Its %s risk level is below your %s target, cushioning your portfolio against falls", fund.RiskLevel, target))
+		}
+		if target != customer.RiskTolerance {
+			rec.Reasons = append(rec.Reasons, fmt.Sprintf("Your %d year horizon limits suggestions to %s risk funds or below, even though your tolerance is %s", horizonYears, target, customer.RiskTolerance))
+		}
+
+		switch held := holdings[fund.ID]; {
+		case held <= 0:
+			rec.Score += 5
+			rec.Reasons = append(rec.Reasons, "You do not hold this fund yet, so it would diversify your ISA")
+		case totalHeld > 0 && held*2 >= totalHeld:
+			rec.Score -= 10
+			rec.Reasons = append(rec.Reasons, fmt.Sprintf("It already makes up %d%% of your ISA, so adding more would concentrate your holdings", held*100/totalHeld))
+		default:
+			rec.Reasons = append(rec.Reasons, fmt.Sprintf("You already hold £%.2f in this fund", float64(held)/100.0))
+		}
+
+		recommendations = append(recommendations, rec)
+	}
+
+	allocate(recommendations, target)
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.AllocationPercent != b.AllocationPercent {
+			return a.AllocationPercent > b.AllocationPercent
+		}
+		return a.FundName < b.FundName
+	})
+
+	return &domain.Recommendations{
+		CustomerID:    customer.ID,
+		RiskScore:     customer.RiskScore,
+		RiskTolerance: customer.RiskTolerance,
+		HorizonYears:  horizonYears,
+		TargetRisk:    target,
+		Funds:         recommendations,
+	}, nil
+}
+
+// targetRisk caps the customer's risk tolerance by their investment horizon,
+// since money needed soon has less time to recover from falls
+func targetRisk(tolerance domain.RiskLevel, horizonYears int) domain.RiskLevel {
+	switch {
+	case horizonYears < 3 && tolerance.Exceeds(domain.RiskLevelLow):
+		return domain.RiskLevelLow
+	case horizonYears < 5 && tolerance.Exceeds(domain.RiskLevelMedium):
+		return domain.RiskLevelMedium
+	default:
+		return tolerance
+	}
+}
+
+// allocate sets a suggested allocation percentage on each recommendation using the
+// model portfolio for the target risk, split evenly between funds at each risk level.
+// Percentages always add up to 100.
+func allocate(recommendations []domain.FundRecommendation, target domain.RiskLevel) {
+	if len(recommendations) == 0 {
+		return
+	}
+
+	// Only weight risk levels that have a fund to hold them
+	fundsPerLevel := make(map[domain.RiskLevel]int)
+	for _, rec := range recommendations {
+		fundsPerLevel[rec.RiskLevel]++
+	}
+	var totalWeight float64
+	for level := range fundsPerLevel {
+		totalWeight += modelAllocations[target][level]
+	}
+	if totalWeight == 0 {
+		return
+	}
+
+	// Round down, then hand out the remaining points by largest remainder
+	remainders := make([]float64, len(recommendations))
+	allocated := 0
+	for i := range recommendations {
+		level := recommendations[i].RiskLevel
+		share := modelAllocations[target][level] / totalWeight * 100 / float64(fundsPerLevel[level])
+		recommendations[i].AllocationPercent = int(math.Floor(share))
+		remainders[i] = share - math.Floor(share)
+		allocated += recommendations[i].AllocationPercent
+	}
+
+	order := make([]int, len(recommendations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := 0; allocated < 100; i++ {
+		recommendations[order[i%len(order)]].AllocationPercent++
+		allocated++
+	}
+
+	for i := range recommendations {
+		if recommendations[i].AllocationPercent > 0 {
+			recommendations[i].Reasons = append(recommendations[i].Reasons,
+				fmt.Sprintf("Suggested %d%% allocation as part of a %s risk portfolio", recommendations[i].AllocationPercent, target))
+		}
+	}
+}
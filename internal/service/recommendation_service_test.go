@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetRecommendations(t *testing.T) {
+	ctx := context.Background()
+	fundService := service.NewFundService(repository.NewInMemoryFundRepository())
+
+	newService := func(customer *domain.Customer, held []*domain.Investment) domain.RecommendationService {
+		mockCustomerRepo := new(mockCustomerRepository)
+		mockInvestRepo := new(mockInvestmentRepository)
+		mockCustomerRepo.On("GetByID", customer.ID).Return(customer, nil)
+		mockInvestRepo.On("GetByCustomerID", customer.ID).Return(held, nil)
+		return service.NewRecommendationService(mockCustomerRepo, mockInvestRepo, fundService)
+	}
+
+	totalAllocation := func(recs *domain.Recommendations) int {
+		total := 0
+		for _, fund := range recs.Funds {
+			total += fund.AllocationPercent
+		}
+		return total
+	}
+
+	t.Run("High tolerance with long horizon ranks equities first", func(t *testing.T) {
+		recService := newService(&domain.Customer{ID: "customer-1", RiskScore: 11, RiskTolerance: domain.RiskLevelHigh}, nil)
+
+		recs, err := recService.GetRecommendations(ctx, "customer-1", 15)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RiskLevelHigh, recs.TargetRisk)
+		assert.Len(t, recs.Funds, 3)
+		assert.Equal(t, "fund-1", recs.Funds[0].FundID)
+		assert.Equal(t, 60, recs.Funds[0].AllocationPercent)
+		assert.Equal(t, 100, totalAllocation(recs))
+		assert.NotEmpty(t, recs.Funds[0].Reasons)
+	})
+
+	t.Run("Short horizon caps the target risk", func(t *testing.T) {
+		recService := newService(&domain.Customer{ID: "customer-1", RiskScore: 11, RiskTolerance: domain.RiskLevelHigh}, nil)
+
+		recs, err := recService.GetRecommendations(ctx, "customer-1", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.RiskLevelLow, recs.TargetRisk)
+		assert.Len(t, recs.Funds, 1)
+		assert.Equal(t, "fund-3", recs.Funds[0].FundID)
+		assert.Equal(t, 100, recs.Funds[0].AllocationPercent)
+	})
+
+	t.Run("Concentrated holdings lower a fund's score", func(t *testing.T) {
+		held := []*domain.Investment{
+			{ID: "inv-1", CustomerID: "customer-1", FundID: "fund-2", Amount: 1000000, Type: domain.InvestmentTypeSubscription, Status: domain.InvestmentStatusProcessed},
+		}
+		recService := newService(&domain.Customer{ID: "customer-1", RiskScore: 6, RiskTolerance: domain.RiskLevelMedium}, held)
+
+		recs, err := recService.GetRecommendations(ctx, "customer-1", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, recs.HorizonYears)
+		assert.Len(t, recs.Funds, 2)
+		assert.Equal(t, "fund-2", recs.Funds[0].FundID)
+		assert.Equal(t, 90, recs.Funds[0].Score)
+		assert.Equal(t, int64(1000000), recs.Funds[0].CurrentHolding)
+		assert.Equal(t, 100, totalAllocation(recs))
+	})
+
+	t.Run("Customers without a risk profile are rejected", func(t *testing.T) {
+		recService := newService(&domain.Customer{ID: "customer-1"}, nil)
+
+		recs, err := recService.GetRecommendations(ctx, "customer-1", 10)
+		assert.ErrorIs(t, err, domain.ErrRiskProfileRequired)
+		assert.Nil(t, recs)
+	})
+}
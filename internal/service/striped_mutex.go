@@ -0,0 +1,22 @@
+package service
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// stripedMutex locks by key, such as an entity ID, sharing a fixed number of
+// mutexes between keys so it never grows. Keys that share a mutex wait for
+// each other, which is harmless as long as nothing holds two keys at once.
+type stripedMutex struct {
+	mutexes [64]sync.Mutex
+}
+
+// lock locks the key until the returned function is called
+func (m *stripedMutex) lock(key string) (unlock func()) {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	mutex := &m.mutexes[hash.Sum32()%uint32(len(m.mutexes))]
+	mutex.Lock()
+	return mutex.Unlock
+}
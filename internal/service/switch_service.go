@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"sync"
+	"time"
+)
+
+// maxBuyLegAttempts is how many times the buy leg of a switch is attempted
+// before the sell leg is rolled back
+const maxBuyLegAttempts = 3
+
+type switchService struct {
+	// mutex serialises switches so two concurrent requests cannot sell the same holding twice
+	mutex          sync.Mutex
+	switchRepo     domain.SwitchRepository
+	investmentRepo domain.InvestmentRepository
+	customerRepo   domain.CustomerRepository
+	fundRepo       domain.FundRepository
+	history        investmentHistory
+}
+
+// NewSwitchService creates a new instance of switch service
+func NewSwitchService(
+	sr domain.SwitchRepository,
+	ir domain.InvestmentRepository,
+	er domain.InvestmentEventRepository,
+	cr domain.CustomerRepository,
+	fr domain.FundRepository,
+	ls domain.LedgerService,
+) domain.SwitchService {
+	return &switchService{
+		switchRepo:     sr,
+		investmentRepo: ir,
+		customerRepo:   cr,
+		fundRepo:       fr,
+		history:        investmentHistory{investmentRepo: ir, eventRepo: er, ledger: ls},
+	}
+}
+
+// SwitchFunds moves amount from one fund to another as a linked sell and buy.
+// If the buy leg cannot be recorded the sell leg is cancelled so the customer's
+// holdings are left unchanged.
+func (ss *switchService) SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*domain.Switch, error) {
+	if amount <= 0 {
+		return nil, errors.New("switch amount must be positive")
+	}
+	if fromFundID == toFundID {
+		return nil, domain.ErrSameFundSwitch
+	}
+
+	// Check if customer and both funds exist
+	customer, err := ss.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	fromFund, err := ss.fundRepo.GetByID(ctx, fromFundID)
+	if err != nil {
+		return nil, err
+	}
+	toFund, err := ss.fundRepo.GetByID(ctx, toFundID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Existing holders can leave soft-closed or closed funds, but gated funds cannot be sold
+	if fromFund.Status == domain.FundStatusSuspended {
+		return nil, domain.ErrFundSuspended
+	}
+	if err := checkFundAcceptsMoney(toFund); err != nil {
+		return nil, err
+	}
+
+	// Switching into a fund riskier than the customer's tolerance needs explicit acknowledgement
+	riskWarning := exceedsRiskTolerance(customer, toFund)
+	if riskWarning && !riskAcknowledged {
+		return nil, domain.ErrRiskNotAcknowledged
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	// Check the customer holds enough in the fund being sold
+	investments, err := ss.investmentRepo.GetByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if holdingsByFund(investments)[fromFundID] < amount {
+		return nil, domain.ErrInsufficientHoldings
+	}
+
+	now := time.Now()
+	sw := &domain.Switch{
+		ID:               uuid.New().String(),
+		CustomerID:       customerID,
+		FromFundID:       fromFundID,
+		ToFundID:         toFundID,
+		Amount:           amount,
+		Status:           domain.SwitchStatusPending,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		RiskAcknowledged: riskWarning,
+	}
+	if err := ss.switchRepo.Create(ctx, sw); err != nil {
+		return nil, err
+	}
+
+	// Once stored, the switch is seen through to completed or failed even if the caller goes away
+	ctx = context.WithoutCancel(ctx)
+
+	// Sell leg
+	sell := ss.newLeg(sw, fromFundID, domain.InvestmentTypeSwitchOut)
+	if err := ss.history.create(ctx, sell); err != nil {
+		return nil, ss.fail(ctx, sw, err)
+	}
+	sw.SellInvestmentID = sell.ID
+
+	// Buy leg, retried before giving up and rolling back the sell leg
+	buy := ss.newLeg(sw, toFundID, domain.InvestmentTypeSwitchIn)
+	for attempt := 1; attempt <= maxBuyLegAttempts; attempt++ {
+		if err = ss.history.create(ctx, buy); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		// A buy leg whose created event was recorded but which could not be
+		// saved is cancelled in its history too, so replaying it matches the switch
+		if buy.Version > 0 {
+			_ = ss.history.record(ctx, buy, &domain.InvestmentEvent{Type: domain.InvestmentEventCancelled})
+		}
+		if rollbackErr := ss.history.record(ctx, sell, &domain.InvestmentEvent{Type: domain.InvestmentEventCancelled}); rollbackErr != nil {
+			return nil, ss.fail(ctx, sw, rollbackErr)
+		}
+		return nil, ss.fail(ctx, sw, err)
+	}
+	sw.BuyInvestmentID = buy.ID
+
+	sw.Status = domain.SwitchStatusCompleted
+	sw.UpdatedAt = time.Now()
+	if err := ss.switchRepo.Update(ctx, sw); err != nil {
+		return nil, err
+	}
+
+	return sw, nil
+}
+
+// GetSwitch gets a switch by ID
+func (ss *switchService) GetSwitch(ctx context.Context, id string) (*domain.Switch, error) {
+	return ss.switchRepo.GetByID(ctx, id)
+}
+
+// newLeg builds one side of a switch
+func (ss *switchService) newLeg(sw *domain.Switch, fundID string, legType domain.InvestmentType) *domain.Investment {
+	return &domain.Investment{
+		ID:               uuid.New().String(),
+		CustomerID:       sw.CustomerID,
+		FundID:           fundID,
+		Amount:           sw.Amount,
+		Type:             legType,
+		SwitchID:         sw.ID,
+		Status:           domain.InvestmentStatusPending,
+		RiskAcknowledged: legType == domain.InvestmentTypeSwitchIn && sw.RiskAcknowledged,
+		CreatedAt:        sw.CreatedAt,
+		UpdatedAt:        sw.CreatedAt,
+	}
+}
+
+// fail marks the switch as failed and returns the original error
+func (ss *switchService) fail(ctx context.Context, sw *domain.Switch, cause error) error {
+	sw.Status = domain.SwitchStatusFailed
+	sw.UpdatedAt = time.Now()
+	_ = ss.switchRepo.Update(ctx, sw)
+	return cause
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestSwitchFunds(t *testing.T) {
+	ctx := context.Background()
+	held := []*domain.Investment{
+		{ID: "inv-1", CustomerID: "customer-1", FundID: "fund-1", Amount: 1000000, Type: domain.InvestmentTypeSubscription, Status: domain.InvestmentStatusProcessed, CreatedAt: time.Now()},
+	}
+
+	newService := func(investRepo *mockInvestmentRepository) domain.SwitchService {
+		mockCustomerRepo := new(mockCustomerRepository)
+		mockFundRepo := new(mockFundRepository)
+		mockCustomerRepo.On("GetByID", "customer-1").Return(&domain.Customer{ID: "customer-1"}, nil)
+		mockFundRepo.On("GetByID", "fund-1").Return(&domain.Fund{ID: "fund-1", Status: domain.FundStatusOpen}, nil)
+		mockFundRepo.On("GetByID", "fund-3").Return(&domain.Fund{ID: "fund-3", Status: domain.FundStatusOpen}, nil)
+		investRepo.On("GetByCustomerID", "customer-1").Return(held, nil)
+
+		return service.NewSwitchService(repository.NewInMemorySwitchRepository(), investRepo, repository.NewInMemoryInvestmentEventRepository(), mockCustomerRepo, mockFundRepo, newLedgerService())
+	}
+
+	t.Run("Switch creates linked sell and buy legs", func(t *testing.T) {
+		mockInvestRepo := new(mockInvestmentRepository)
+		mockInvestRepo.On("Create", mock.AnythingOfType("*domain.Investment")).Return(nil)
+		switchService := newService(mockInvestRepo)
+
+		sw, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-3", 400000, false)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SwitchStatusCompleted, sw.Status)
+		assert.NotEmpty(t, sw.SellInvestmentID)
+		assert.NotEmpty(t, sw.BuyInvestmentID)
+
+		sell := mockInvestRepo.Calls[1].Arguments.Get(0).(*domain.Investment)
+		buy := mockInvestRepo.Calls[2].Arguments.Get(0).(*domain.Investment)
+		assert.Equal(t, domain.InvestmentTypeSwitchOut, sell.Type)
+		assert.Equal(t, domain.InvestmentTypeSwitchIn, buy.Type)
+		assert.Equal(t, sw.ID, sell.SwitchID)
+		assert.Equal(t, sw.ID, buy.SwitchID)
+	})
+
+	t.Run("Switch exceeding holdings is rejected", func(t *testing.T) {
+		switchService := newService(new(mockInvestmentRepository))
+
+		sw, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-3", 1000001, false)
+		assert.ErrorIs(t, err, domain.ErrInsufficientHoldings)
+		assert.Nil(t, sw)
+	})
+
+	t.Run("Failed buy leg rolls back sell leg", func(t *testing.T) {
+		mockInvestRepo := new(mockInvestmentRepository)
+		mockInvestRepo.On("Create", mock.MatchedBy(func(i *domain.Investment) bool {
+			return i.Type == domain.InvestmentTypeSwitchOut
+		})).Return(nil)
+		mockInvestRepo.On("Create", mock.MatchedBy(func(i *domain.Investment) bool {
+			return i.Type == domain.InvestmentTypeSwitchIn
+		})).Return(errors.New("storage unavailable"))
+		mockInvestRepo.On("Update", mock.AnythingOfType("*domain.Investment")).Return(nil)
+		switchService := newService(mockInvestRepo)
+
+		sw, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-3", 400000, false)
+		assert.Error(t, err)
+		assert.Nil(t, sw)
+
+		mockInvestRepo.AssertNumberOfCalls(t, "Create", 4) // sell leg plus three buy attempts
+		rolledBack := mockInvestRepo.Calls[len(mockInvestRepo.Calls)-1].Arguments.Get(0).(*domain.Investment)
+		assert.Equal(t, domain.InvestmentTypeSwitchOut, rolledBack.Type)
+		assert.Equal(t, domain.InvestmentStatusCancelled, rolledBack.Status)
+	})
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// The traced services wrap the investment, fund, switch and plan services and
+// record a span for each call, named after the service and method, such as
+// InvestmentService.CreateInvestment. The spans carry the IDs of the
+// investment, fund and customer concerned, and record the error of failed calls.
+
+type tracedInvestmentService struct {
+	next   domain.InvestmentService
+	tracer trace.Tracer
+}
+
+// NewTracedInvestmentService wraps an investment service so each call records
+// a span with the investment, fund and customer it concerns and its outcome
+func NewTracedInvestmentService(is domain.InvestmentService, tp trace.TracerProvider) domain.InvestmentService {
+	return &tracedInvestmentService{next: is, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+// investmentAttributes describe the investment a call returned
+func investmentAttributes(investment *domain.Investment) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("investment.id", investment.ID),
+		attribute.String("investment.status", string(investment.Status)),
+		attribute.String("fund.id", investment.FundID),
+		attribute.String("customer.id", investment.CustomerID),
+	}
+}
+
+// CreateInvestment traces CreateInvestment on the wrapped service
+func (s *tracedInvestmentService) CreateInvestment(ctx context.Context, customerID, fundID string, amount int64, riskAcknowledged bool) (*domain.Investment, error) {
+	ctx, span := s.tracer.Start(ctx, "InvestmentService.CreateInvestment", trace.WithAttributes(
+		attribute.String("customer.id", customerID),
+		attribute.String("fund.id", fundID),
+		attribute.Int64("investment.amount", amount),
+		attribute.Bool("investment.risk_acknowledged", riskAcknowledged),
+	))
+	investment, err := s.next.CreateInvestment(ctx, customerID, fundID, amount, riskAcknowledged)
+	if err == nil {
+		span.SetAttributes(investmentAttributes(investment)...)
+	}
+	tracing.End(span, err)
+	return investment, err
+}
+
+// GetInvestment traces GetInvestment on the wrapped service
+func (s *tracedInvestmentService) GetInvestment(ctx context.Context, id string) (*domain.Investment, error) {
+	ctx, span := s.tracer.Start(ctx, "InvestmentService.GetInvestment", trace.WithAttributes(attribute.String("investment.id", id)))
+	investment, err := s.next.GetInvestment(ctx, id)
+	if err == nil {
+		span.SetAttributes(investmentAttributes(investment)...)
+	}
+	tracing.End(span, err)
+	return investment, err
+}
+
+// GetCustomerInvestments traces GetCustomerInvestments on the wrapped service
+func (s *tracedInvestmentService) GetCustomerInvestments(ctx context.Context, customerID string) ([]*domain.Investment, error) {
+	ctx, span := s.tracer.Start(ctx, "InvestmentService.GetCustomerInvestments", trace.WithAttributes(attribute.String("customer.id", customerID)))
+	investments, err := s.next.GetCustomerInvestments(ctx, customerID)
+	span.SetAttributes(attribute.Int("investments.returned", len(investments)))
+	tracing.End(span, err)
+	return investments, err
+}
+
+// ListCustomerInvestments traces ListCustomerInvestments on the wrapped service
+func (s *tracedInvestmentService) ListCustomerInvestments(ctx context.Context, query domain.InvestmentQuery) (*domain.InvestmentPage, error) {
+	ctx, span := s.tracer.Start(ctx, "InvestmentService.ListCustomerInvestments", trace.WithAttributes(
+		attribute.String("customer.id", query.CustomerID),
+		attribute.String("fund.id", query.FundID),
+		attribute.Int("page.limit", query.Limit),
+	))
+	page, err := s.next.ListCustomerInvestments(ctx, query)
+	if err == nil {
+		span.SetAttributes(attribute.Int("investments.returned", len(page.Investments)))
+	}
+	tracing.End(span, err)
+	return page, err
+}
+
+// CancelInvestment traces CancelInvestment on the wrapped service
+func (s *tracedInvestmentService) CancelInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "InvestmentService.CancelInvestment", id, func(ctx context.Context) (*domain.Investment, error) {
+		return s.next.CancelInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// PriceInvestment traces PriceInvestment on the wrapped service
+func (s *tracedInvestmentService) PriceInvestment(ctx context.Context, id string, unitPrice int64, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "InvestmentService.PriceInvestment", id, func(ctx context.Context) (*domain.Investment, error) {
+		return s.next.PriceInvestment(ctx, id, unitPrice, expectedVersion)
+	}, attribute.Int64("investment.unit_price", unitPrice))
+}
+
+// ProcessInvestment traces ProcessInvestment on the wrapped service
+func (s *tracedInvestmentService) ProcessInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "InvestmentService.ProcessInvestment", id, func(ctx context.Context) (*domain.Investment, error) {
+		return s.next.ProcessInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// WithdrawInvestment traces WithdrawInvestment on the wrapped service
+func (s *tracedInvestmentService) WithdrawInvestment(ctx context.Context, id string, expectedVersion int64) (*domain.Investment, error) {
+	return s.change(ctx, "InvestmentService.WithdrawInvestment", id, func(ctx context.Context) (*domain.Investment, error) {
+		return s.next.WithdrawInvestment(ctx, id, expectedVersion)
+	})
+}
+
+// change traces a change to an investment
+func (s *tracedInvestmentService) change(ctx context.Context, name, id string, fn func(context.Context) (*domain.Investment, error), attrs ...attribute.KeyValue) (*domain.Investment, error) {
+	attrs = append(attrs, attribute.String("investment.id", id))
+	ctx, span := s.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	investment, err := fn(ctx)
+	if err == nil {
+		span.SetAttributes(investmentAttributes(investment)...)
+	}
+	tracing.End(span, err)
+	return investment, err
+}
+
+// GetInvestmentEvents traces GetInvestmentEvents on the wrapped service
+func (s *tracedInvestmentService) GetInvestmentEvents(ctx context.Context, id string) ([]*domain.InvestmentEvent, error) {
+	ctx, span := s.tracer.Start(ctx, "InvestmentService.GetInvestmentEvents", trace.WithAttributes(attribute.String("investment.id", id)))
+	events, err := s.next.GetInvestmentEvents(ctx, id)
+	tracing.End(span, err)
+	return events, err
+}
+
+type tracedFundService struct {
+	next   domain.FundService
+	tracer trace.Tracer
+}
+
+// NewTracedFundService wraps a fund service so each call records a span with
+// the fund it concerns and its outcome
+func NewTracedFundService(fs domain.FundService, tp trace.TracerProvider) domain.FundService {
+	return &tracedFundService{next: fs, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+// fundAttributes describe the fund a call returned
+func fundAttributes(fund *domain.Fund) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("fund.id", fund.ID),
+		attribute.String("fund.status", string(fund.Status)),
+	}
+}
+
+// GetFund traces GetFund on the wrapped service
+func (s *tracedFundService) GetFund(ctx context.Context, id string) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.GetFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.GetFund(ctx, id)
+	})
+}
+
+// ListFunds traces ListFunds on the wrapped service
+func (s *tracedFundService) ListFunds(ctx context.Context, filter domain.FundFilter) ([]*domain.Fund, int, error) {
+	ctx, span := s.tracer.Start(ctx, "FundService.ListFunds")
+	funds, total, err := s.next.ListFunds(ctx, filter)
+	span.SetAttributes(attribute.Int("funds.returned", len(funds)), attribute.Int("funds.total", total))
+	tracing.End(span, err)
+	return funds, total, err
+}
+
+// CreateFund traces CreateFund on the wrapped service
+func (s *tracedFundService) CreateFund(ctx context.Context, details domain.FundDetails) (*domain.Fund, error) {
+	ctx, span := s.tracer.Start(ctx, "FundService.CreateFund")
+	fund, err := s.next.CreateFund(ctx, details)
+	if err == nil {
+		span.SetAttributes(fundAttributes(fund)...)
+	}
+	tracing.End(span, err)
+	return fund, err
+}
+
+// UpdateFund traces UpdateFund on the wrapped service
+func (s *tracedFundService) UpdateFund(ctx context.Context, id string, details domain.FundDetails, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.UpdateFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.UpdateFund(ctx, id, details, expectedVersion)
+	})
+}
+
+// SoftCloseFund traces SoftCloseFund on the wrapped service
+func (s *tracedFundService) SoftCloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.SoftCloseFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.SoftCloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// SuspendFund traces SuspendFund on the wrapped service
+func (s *tracedFundService) SuspendFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.SuspendFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.SuspendFund(ctx, id, expectedVersion)
+	})
+}
+
+// CloseFund traces CloseFund on the wrapped service
+func (s *tracedFundService) CloseFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.CloseFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.CloseFund(ctx, id, expectedVersion)
+	})
+}
+
+// ReopenFund traces ReopenFund on the wrapped service
+func (s *tracedFundService) ReopenFund(ctx context.Context, id string, expectedVersion int64) (*domain.Fund, error) {
+	return s.change(ctx, "FundService.ReopenFund", id, func(ctx context.Context) (*domain.Fund, error) {
+		return s.next.ReopenFund(ctx, id, expectedVersion)
+	})
+}
+
+// change traces a call concerning one fund
+func (s *tracedFundService) change(ctx context.Context, name, id string, fn func(context.Context) (*domain.Fund, error)) (*domain.Fund, error) {
+	ctx, span := s.tracer.Start(ctx, name, trace.WithAttributes(attribute.String("fund.id", id)))
+	fund, err := fn(ctx)
+	if err == nil {
+		span.SetAttributes(fundAttributes(fund)...)
+	}
+	tracing.End(span, err)
+	return fund, err
+}
+
+type tracedSwitchService struct {
+	next   domain.SwitchService
+	tracer trace.Tracer
+}
+
+// NewTracedSwitchService wraps a switch service so each call records a span
+// with the switch and funds it concerns and its outcome
+func NewTracedSwitchService(ss domain.SwitchService, tp trace.TracerProvider) domain.SwitchService {
+	return &tracedSwitchService{next: ss, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+// switchAttributes describe the switch a call returned
+func switchAttributes(sw *domain.Switch) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("switch.id", sw.ID),
+		attribute.String("switch.status", string(sw.Status)),
+		attribute.String("customer.id", sw.CustomerID),
+		attribute.String("switch.from_fund_id", sw.FromFundID),
+		attribute.String("switch.to_fund_id", sw.ToFundID),
+	}
+}
+
+// SwitchFunds traces SwitchFunds on the wrapped service
+func (s *tracedSwitchService) SwitchFunds(ctx context.Context, customerID, fromFundID, toFundID string, amount int64, riskAcknowledged bool) (*domain.Switch, error) {
+	ctx, span := s.tracer.Start(ctx, "SwitchService.SwitchFunds", trace.WithAttributes(
+		attribute.String("customer.id", customerID),
+		attribute.String("switch.from_fund_id", fromFundID),
+		attribute.String("switch.to_fund_id", toFundID),
+		attribute.Int64("switch.amount", amount),
+	))
+	sw, err := s.next.SwitchFunds(ctx, customerID, fromFundID, toFundID, amount, riskAcknowledged)
+	if err == nil {
+		span.SetAttributes(switchAttributes(sw)...)
+	}
+	tracing.End(span, err)
+	return sw, err
+}
+
+// GetSwitch traces GetSwitch on the wrapped service
+func (s *tracedSwitchService) GetSwitch(ctx context.Context, id string) (*domain.Switch, error) {
+	ctx, span := s.tracer.Start(ctx, "SwitchService.GetSwitch", trace.WithAttributes(attribute.String("switch.id", id)))
+	sw, err := s.next.GetSwitch(ctx, id)
+	if err == nil {
+		span.SetAttributes(switchAttributes(sw)...)
+	}
+	tracing.End(span, err)
+	return sw, err
+}
+
+type tracedPlanService struct {
+	next   domain.PlanService
+	tracer trace.Tracer
+}
+
+// NewTracedPlanService wraps a plan service so each call records a span with
+// the plan, fund and customer it concerns and its outcome
+func NewTracedPlanService(ps domain.PlanService, tp trace.TracerProvider) domain.PlanService {
+	return &tracedPlanService{next: ps, tracer: tp.Tracer(tracing.InstrumentationName)}
+}
+
+// planAttributes describe the plan a call returned
+func planAttributes(plan *domain.Plan) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("plan.id", plan.ID),
+		attribute.String("plan.status", string(plan.Status)),
+		attribute.StringSlice("fund.ids", planFundIDs(plan.Allocations)),
+		attribute.String("customer.id", plan.CustomerID),
+	}
+}
+
+// CreatePlan traces CreatePlan on the wrapped service
+func (s *tracedPlanService) CreatePlan(ctx context.Context, customerID string, allocations []domain.PlanAllocation, dayOfMonth int, riskAcknowledged bool) (*domain.Plan, error) {
+	ctx, span := s.tracer.Start(ctx, "PlanService.CreatePlan", trace.WithAttributes(
+		attribute.String("customer.id", customerID),
+		attribute.StringSlice("fund.ids", planFundIDs(allocations)),
+		attribute.Int("plan.day_of_month", dayOfMonth),
+	))
+	plan, err := s.next.CreatePlan(ctx, customerID, allocations, dayOfMonth, riskAcknowledged)
+	if err == nil {
+		span.SetAttributes(planAttributes(plan)...)
+	}
+	tracing.End(span, err)
+	return plan, err
+}
+
+// GetPlan traces GetPlan on the wrapped service
+func (s *tracedPlanService) GetPlan(ctx context.Context, id string) (*domain.Plan, error) {
+	return s.change(ctx, "PlanService.GetPlan", id, func(ctx context.Context) (*domain.Plan, error) {
+		return s.next.GetPlan(ctx, id)
+	})
+}
+
+// GetCustomerPlans traces GetCustomerPlans on the wrapped service
+func (s *tracedPlanService) GetCustomerPlans(ctx context.Context, customerID string) ([]*domain.Plan, error) {
+	ctx, span := s.tracer.Start(ctx, "PlanService.GetCustomerPlans", trace.WithAttributes(attribute.String("customer.id", customerID)))
+	plans, err := s.next.GetCustomerPlans(ctx, customerID)
+	span.SetAttributes(attribute.Int("plans.returned", len(plans)))
+	tracing.End(span, err)
+	return plans, err
+}
+
+// UpdatePlan traces UpdatePlan on the wrapped service
+func (s *tracedPlanService) UpdatePlan(ctx context.Context, id string, allocations []domain.PlanAllocation, dayOfMonth int, status domain.PlanStatus, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "PlanService.UpdatePlan", id, func(ctx context.Context) (*domain.Plan, error) {
+		return s.next.UpdatePlan(ctx, id, allocations, dayOfMonth, status, expectedVersion)
+	})
+}
+
+// CancelPlan traces CancelPlan on the wrapped service
+func (s *tracedPlanService) CancelPlan(ctx context.Context, id string, expectedVersion int64) (*domain.Plan, error) {
+	return s.change(ctx, "PlanService.CancelPlan", id, func(ctx context.Context) (*domain.Plan, error) {
+		return s.next.CancelPlan(ctx, id, expectedVersion)
+	})
+}
+
+// RunDuePlans traces RunDuePlans on the wrapped service. The investments the
+// run creates are traced as its children.
+func (s *tracedPlanService) RunDuePlans(ctx context.Context, at time.Time) error {
+	ctx, span := s.tracer.Start(ctx, "PlanService.RunDuePlans", trace.WithAttributes(attribute.String("plan.run_at", at.Format(time.RFC3339))))
+	err := s.next.RunDuePlans(ctx, at)
+	tracing.End(span, err)
+	return err
+}
+
+// change traces a call concerning one plan
+func (s *tracedPlanService) change(ctx context.Context, name, id string, fn func(context.Context) (*domain.Plan, error)) (*domain.Plan, error) {
+	ctx, span := s.tracer.Start(ctx, name, trace.WithAttributes(attribute.String("plan.id", id)))
+	plan, err := fn(ctx)
+	if err == nil {
+		span.SetAttributes(planAttributes(plan)...)
+	}
+	tracing.End(span, err)
+	return plan, err
+}
@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"github.com/grokkos/go-isa-retail-service/internal/domain"
+	"github.com/grokkos/go-isa-retail-service/internal/repository"
+	"github.com/grokkos/go-isa-retail-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// spanAttributes returns a span's attributes by key
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracedInvestmentService(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	investmentService := service.NewTracedInvestmentService(service.NewInvestmentService(
+		repository.NewTracedInvestmentRepository(repository.NewInMemoryInvestmentRepository(), tp),
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	), tp)
+
+	investment, err := investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1500000, false)
+	require.NoError(t, err)
+	_, err = investmentService.CreateInvestment(ctx, "customer-1", "fund-1", 1000000, false)
+	require.ErrorIs(t, err, domain.ErrAllowanceExceeded)
+
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	require.Len(t, spans["InvestmentService.CreateInvestment"], 2)
+
+	created := spans["InvestmentService.CreateInvestment"][0]
+	attrs := spanAttributes(created)
+	assert.Equal(t, investment.ID, attrs["investment.id"].AsString())
+	assert.Equal(t, "fund-1", attrs["fund.id"].AsString())
+	assert.Equal(t, "customer-1", attrs["customer.id"].AsString())
+	assert.Equal(t, int64(1500000), attrs["investment.amount"].AsInt64())
+	assert.Equal(t, "ok", attrs["outcome"].AsString())
+	assert.Equal(t, codes.Unset, created.Status().Code)
+
+	rejected := spans["InvestmentService.CreateInvestment"][1]
+	assert.Equal(t, "error", spanAttributes(rejected)["outcome"].AsString())
+	assert.Equal(t, codes.Error, rejected.Status().Code)
+	assert.Equal(t, err.Error(), rejected.Status().Description)
+
+	// Repository operations are children of the service call that made them
+	require.Len(t, spans["InvestmentRepository.Create"], 1)
+	repoSpan := spans["InvestmentRepository.Create"][0]
+	assert.Equal(t, created.SpanContext().SpanID(), repoSpan.Parent().SpanID())
+	assert.Equal(t, created.SpanContext().TraceID(), repoSpan.SpanContext().TraceID())
+	assert.Equal(t, "investment", spanAttributes(repoSpan)["repository"].AsString())
+	assert.Equal(t, "create", spanAttributes(repoSpan)["operation"].AsString())
+}
+
+func TestTracedFundService(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	fundService := service.NewTracedFundService(service.NewFundService(repository.NewInMemoryFundRepository()), tp)
+
+	fund, err := fundService.GetFund(ctx, "fund-1")
+	require.NoError(t, err)
+	_, err = fundService.SuspendFund(ctx, "fund-1", fund.Version+1)
+	require.ErrorIs(t, err, domain.ErrConflict)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "FundService.GetFund", spans[0].Name())
+	assert.Equal(t, "fund-1", spanAttributes(spans[0])["fund.id"].AsString())
+	assert.Equal(t, string(domain.FundStatusOpen), spanAttributes(spans[0])["fund.status"].AsString())
+	assert.Equal(t, "FundService.SuspendFund", spans[1].Name())
+	assert.Equal(t, "fund-1", spanAttributes(spans[1])["fund.id"].AsString())
+	assert.Equal(t, "error", spanAttributes(spans[1])["outcome"].AsString())
+}
+
+func TestTracedSwitchService(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	investmentRepo := repository.NewInMemoryInvestmentRepository()
+	switchService := service.NewTracedSwitchService(service.NewSwitchService(
+		repository.NewInMemorySwitchRepository(),
+		investmentRepo,
+		repository.NewInMemoryInvestmentEventRepository(),
+		repository.NewInMemoryCustomerRepository(),
+		repository.NewInMemoryFundRepository(),
+		newLedgerService(),
+	), tp)
+
+	_, err := switchService.SwitchFunds(ctx, "customer-1", "fund-1", "fund-2", 50000, false)
+	require.Error(t, err)
+	_, err = switchService.GetSwitch(ctx, "switch-1")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "SwitchService.SwitchFunds", spans[0].Name())
+	attrs := spanAttributes(spans[0])
+	assert.Equal(t, "customer-1", attrs["customer.id"].AsString())
+	assert.Equal(t, "fund-1", attrs["switch.from_fund_id"].AsString())
+	assert.Equal(t, "fund-2", attrs["switch.to_fund_id"].AsString())
+	assert.Equal(t, "error", attrs["outcome"].AsString())
+	assert.Equal(t, "SwitchService.GetSwitch", spans[1].Name())
+	assert.Equal(t, "switch-1", spanAttributes(spans[1])["switch.id"].AsString())
+}
+
+func TestTracedPlanService(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	customerRepo := repository.NewInMemoryCustomerRepository()
+	fundRepo := repository.NewInMemoryFundRepository()
+	investmentService := service.NewTracedInvestmentService(service.NewInvestmentService(
+		repository.NewInMemoryInvestmentRepository(),
+		repository.NewInMemoryInvestmentEventRepository(),
+		customerRepo,
+		fundRepo,
+		newLedgerService(),
+		domain.DefaultAllowanceRules,
+	), tp)
+	planService := service.NewTracedPlanService(service.NewPlanService(
+		repository.NewInMemoryPlanRepository(), customerRepo, fundRepo, investmentService, domain.DefaultAllowanceRules,
+	), tp)
+
+	plan, err := planService.CreatePlan(ctx, "customer-1", []domain.PlanAllocation{{FundID: "fund-1", Amount: 25000}}, 1, false)
+	require.NoError(t, err)
+	require.NoError(t, planService.RunDuePlans(ctx, plan.NextRunAt))
+
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	require.Len(t, spans["PlanService.CreatePlan"], 1)
+	attrs := spanAttributes(spans["PlanService.CreatePlan"][0])
+	assert.Equal(t, plan.ID, attrs["plan.id"].AsString())
+	assert.Equal(t, []string{"fund-1"}, attrs["fund.ids"].AsStringSlice())
+	assert.Equal(t, "ok", attrs["outcome"].AsString())
+
+	// The investments a run creates are children of the run
+	require.Len(t, spans["PlanService.RunDuePlans"], 1)
+	require.Len(t, spans["InvestmentService.CreateInvestment"], 1)
+	run := spans["PlanService.RunDuePlans"][0]
+	assert.Equal(t, run.SpanContext().SpanID(), spans["InvestmentService.CreateInvestment"][0].Parent().SpanID())
+}